@@ -0,0 +1,222 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Axis-aligned bounding box
+type AABB struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+// EmptyAABB returns box containing nothing, which could be extended.
+func EmptyAABB() AABB {
+	inf := float32(math.Inf(1))
+	return AABB{
+		Min: mgl32.Vec3{inf, inf, inf},
+		Max: mgl32.Vec3{-inf, -inf, -inf},
+	}
+}
+
+// IsEmpty reports whether box contains nothing.
+func (b AABB) IsEmpty() bool {
+	return b.Min[0] > b.Max[0] || b.Min[1] > b.Max[1] || b.Min[2] > b.Max[2]
+}
+
+// Center returns center of box.
+func (b AABB) Center() mgl32.Vec3 {
+	return b.Min.Add(b.Max).Mul(0.5)
+}
+
+// Size returns lengths of edges of box.
+func (b AABB) Size() mgl32.Vec3 {
+	return b.Max.Sub(b.Min)
+}
+
+// Extend returns box containing both b and point p.
+func (b AABB) Extend(p mgl32.Vec3) AABB {
+	for i := 0; i < 3; i++ {
+		b.Min[i] = float32(math.Min(float64(b.Min[i]), float64(p[i])))
+		b.Max[i] = float32(math.Max(float64(b.Max[i]), float64(p[i])))
+	}
+	return b
+}
+
+// Union returns box containing both b and o.
+func (b AABB) Union(o AABB) AABB {
+	if o.IsEmpty() {
+		return b
+	}
+	return b.Extend(o.Min).Extend(o.Max)
+}
+
+// Contains reports whether point p is inside box.
+func (b AABB) Contains(p mgl32.Vec3) bool {
+	return p[0] >= b.Min[0] && p[0] <= b.Max[0] &&
+		p[1] >= b.Min[1] && p[1] <= b.Max[1] &&
+		p[2] >= b.Min[2] && p[2] <= b.Max[2]
+}
+
+// Corners returns the 8 corners of box.
+func (b AABB) Corners() [8]mgl32.Vec3 {
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		for j := 0; j < 3; j++ {
+			if i&(1<<j) != 0 {
+				corners[i][j] = b.Max[j]
+			} else {
+				corners[i][j] = b.Min[j]
+			}
+		}
+	}
+	return corners
+}
+
+// Transform returns box containing b transformed by affine matrix m.
+func (b AABB) Transform(m mgl32.Mat4) AABB {
+	if b.IsEmpty() {
+		return b
+	}
+
+	// Arvo's method, which avoids transforming all corners
+	var r AABB
+	for i := 0; i < 3; i++ {
+		r.Min[i] = m.At(i, 3)
+		r.Max[i] = m.At(i, 3)
+		for j := 0; j < 3; j++ {
+			e := m.At(i, j) * b.Min[j]
+			f := m.At(i, j) * b.Max[j]
+			if e < f {
+				r.Min[i] += e
+				r.Max[i] += f
+			} else {
+				r.Min[i] += f
+				r.Max[i] += e
+			}
+		}
+	}
+	return r
+}
+
+// Bounding sphere
+type Sphere struct {
+	Center mgl32.Vec3
+	Radius float32
+}
+
+// Transform returns sphere containing s transformed by affine matrix m.
+func (s Sphere) Transform(m mgl32.Mat4) Sphere {
+	scale := float32(math.Max(float64(m.Col(0).Vec3().Len()),
+		math.Max(float64(m.Col(1).Vec3().Len()), float64(m.Col(2).Vec3().Len()))))
+	return Sphere{
+		Center: mgl32.TransformCoordinate(s.Center, m),
+		Radius: s.Radius * scale,
+	}
+}
+
+// Bounds returns bounding box of vertex positions.
+func (d *MeshData) Bounds() AABB {
+	b := EmptyAABB()
+	for _, p := range d.Positions {
+		b = b.Extend(p)
+	}
+	return b
+}
+
+// BoundingSphere returns bounding sphere of vertex positions, which is
+// computed by Ritter's algorithm and slightly bigger than optimal one.
+func (d *MeshData) BoundingSphere() Sphere {
+	if len(d.Positions) == 0 {
+		return Sphere{}
+	}
+
+	// Start with the most distant pair among extreme points along axes
+	var minIdx, maxIdx [3]int
+	for i, p := range d.Positions {
+		for j := 0; j < 3; j++ {
+			if p[j] < d.Positions[minIdx[j]][j] {
+				minIdx[j] = i
+			}
+			if p[j] > d.Positions[maxIdx[j]][j] {
+				maxIdx[j] = i
+			}
+		}
+	}
+	a, b := d.Positions[minIdx[0]], d.Positions[maxIdx[0]]
+	for j := 1; j < 3; j++ {
+		pa, pb := d.Positions[minIdx[j]], d.Positions[maxIdx[j]]
+		if pb.Sub(pa).LenSqr() > b.Sub(a).LenSqr() {
+			a, b = pa, pb
+		}
+	}
+	s := Sphere{
+		Center: a.Add(b).Mul(0.5),
+		Radius: b.Sub(a).Len() / 2,
+	}
+
+	// Grow sphere to include outlying points
+	for _, p := range d.Positions {
+		dist := p.Sub(s.Center).Len()
+		if dist > s.Radius {
+			r := (s.Radius + dist) / 2
+			s.Center = s.Center.Add(p.Sub(s.Center).Mul((r - s.Radius) / dist))
+			s.Radius = r
+		}
+	}
+	return s
+}
+
+// Half-line starting at Origin, Direction is normalized
+type Ray struct {
+	Origin    mgl32.Vec3
+	Direction mgl32.Vec3
+}
+
+// At returns point at distance t along ray.
+func (r Ray) At(t float32) mgl32.Vec3 {
+	return r.Origin.Add(r.Direction.Mul(t))
+}
+
+// IntersectAABB returns distance to the nearest intersection with box,
+// which is 0 if ray starts inside.
+func (r Ray) IntersectAABB(b AABB) (float32, bool) {
+	tmin, tmax := 0.0, math.Inf(1)
+	for i := 0; i < 3; i++ {
+		inv := 1 / float64(r.Direction[i])
+		t1 := (float64(b.Min[i]) - float64(r.Origin[i])) * inv
+		t2 := (float64(b.Max[i]) - float64(r.Origin[i])) * inv
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		// NaN appears when ray is parallel to slab and starts on its plane
+		if !math.IsNaN(t1) {
+			tmin = math.Max(tmin, t1)
+		}
+		if !math.IsNaN(t2) {
+			tmax = math.Min(tmax, t2)
+		}
+		if tmin > tmax {
+			return 0, false
+		}
+	}
+	return float32(tmin), true
+}
+
+// IntersectSphere returns distance to the nearest intersection with sphere,
+// which is 0 if ray starts inside.
+func (r Ray) IntersectSphere(s Sphere) (float32, bool) {
+	oc := r.Origin.Sub(s.Center)
+	b := oc.Dot(r.Direction)
+	c := oc.LenSqr() - s.Radius*s.Radius
+	if c <= 0 {
+		return 0, true
+	}
+	disc := b*b - c
+	if b > 0 || disc < 0 {
+		return 0, false
+	}
+	return -b - float32(math.Sqrt(float64(disc))), true
+}
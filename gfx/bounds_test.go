@@ -0,0 +1,140 @@
+package gfx
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestAABB(t *testing.T) {
+	b := EmptyAABB()
+	if !b.IsEmpty() {
+		t.Fatal("empty box isn't empty")
+	}
+	b = b.Extend(mgl32.Vec3{1, 2, 3}).Extend(mgl32.Vec3{-1, 0, 5})
+	if b.Min != (mgl32.Vec3{-1, 0, 3}) || b.Max != (mgl32.Vec3{1, 2, 5}) {
+		t.Errorf("got box %v", b)
+	}
+	if b.Center() != (mgl32.Vec3{0, 1, 4}) || b.Size() != (mgl32.Vec3{2, 2, 2}) {
+		t.Errorf("got center %v and size %v", b.Center(), b.Size())
+	}
+	if b.Union(EmptyAABB()) != b || EmptyAABB().Union(b) != b {
+		t.Error("union with empty box changes box")
+	}
+
+	tests := []struct {
+		name   string
+		point  mgl32.Vec3
+		inside bool
+	}{
+		{"center", mgl32.Vec3{0, 1, 4}, true},
+		{"corner", mgl32.Vec3{1, 2, 5}, true},
+		{"outside", mgl32.Vec3{0, 1, 5.5}, false},
+	}
+	for _, tt := range tests {
+		if got := b.Contains(tt.point); got != tt.inside {
+			t.Errorf("%s: got contains %v, want %v", tt.name, got, tt.inside)
+		}
+	}
+	corners := b.Corners()
+	for _, c := range corners {
+		if !b.Contains(c) {
+			t.Errorf("corner %v is outside", c)
+		}
+	}
+	if corners[0] != b.Min || corners[7] != b.Max {
+		t.Errorf("got corners %v", corners)
+	}
+}
+
+func TestAABBTransform(t *testing.T) {
+	box := AABB{Min: mgl32.Vec3{-1, -2, -3}, Max: mgl32.Vec3{1, 2, 3}}
+	tests := []struct {
+		name string
+		m    mgl32.Mat4
+		want AABB
+	}{
+		{"identity", mgl32.Ident4(), box},
+		{"translation", mgl32.Translate3D(1, 2, 3), AABB{Min: mgl32.Vec3{0, 0, 0}, Max: mgl32.Vec3{2, 4, 6}}},
+		{"scale", mgl32.Scale3D(2, -1, 1), AABB{Min: mgl32.Vec3{-2, -2, -3}, Max: mgl32.Vec3{2, 2, 3}}},
+		{"rotation", mgl32.HomogRotate3DZ(math.Pi / 2), AABB{Min: mgl32.Vec3{-2, -1, -3}, Max: mgl32.Vec3{2, 1, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := box.Transform(tt.m)
+			if !got.Min.ApproxEqualThreshold(tt.want.Min, 1e-5) || !got.Max.ApproxEqualThreshold(tt.want.Max, 1e-5) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if !EmptyAABB().Transform(mgl32.Translate3D(1, 0, 0)).IsEmpty() {
+		t.Error("transformed empty box isn't empty")
+	}
+}
+
+func TestBoundingSphere(t *testing.T) {
+	tests := []struct {
+		name      string
+		mesh      *MeshData
+		maxRadius float32
+	}{
+		{"sphere", GenUVSphere(2, 32, 16), 2.1},
+		{"cube", GenCube(2, 1), float32(math.Sqrt(3)) * 1.25},
+		{"single point", &MeshData{Positions: []mgl32.Vec3{{1, 2, 3}}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.mesh.BoundingSphere()
+			if s.Radius > tt.maxRadius {
+				t.Errorf("got radius %v, want at most %v", s.Radius, tt.maxRadius)
+			}
+			for _, p := range tt.mesh.Positions {
+				if p.Sub(s.Center).Len() > s.Radius+1e-4 {
+					t.Fatalf("point %v is outside of sphere %v", p, s)
+				}
+			}
+		})
+	}
+	if s := (&MeshData{}).BoundingSphere(); s != (Sphere{}) {
+		t.Errorf("got sphere %v of empty mesh", s)
+	}
+}
+
+func TestRayIntersect(t *testing.T) {
+	box := AABB{Min: mgl32.Vec3{-1, -1, -1}, Max: mgl32.Vec3{1, 1, 1}}
+	sphere := Sphere{Radius: 1}
+	tests := []struct {
+		name   string
+		ray    Ray
+		hit    bool
+		boxT   float32
+		sphere float32
+	}{
+		{"hit", Ray{mgl32.Vec3{-5, 0, 0}, mgl32.Vec3{1, 0, 0}}, true, 4, 4},
+		{"inside", Ray{mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0}}, true, 0, 0},
+		{"miss", Ray{mgl32.Vec3{-5, 3, 0}, mgl32.Vec3{1, 0, 0}}, false, 0, 0},
+		{"behind", Ray{mgl32.Vec3{5, 0, 0}, mgl32.Vec3{1, 0, 0}}, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bt, ok := tt.ray.IntersectAABB(box)
+			if ok != tt.hit || (ok && math.Abs(float64(bt-tt.boxT)) > 1e-5) {
+				t.Errorf("got box intersection %v at %v, want %v at %v", ok, bt, tt.hit, tt.boxT)
+			}
+			st, ok := tt.ray.IntersectSphere(sphere)
+			if ok != tt.hit || (ok && math.Abs(float64(st-tt.sphere)) > 1e-5) {
+				t.Errorf("got sphere intersection %v at %v, want %v at %v", ok, st, tt.hit, tt.sphere)
+			}
+		})
+	}
+
+	// Ray parallel to faces of box and starting on plane of one
+	ray := Ray{mgl32.Vec3{-5, 1, 0}, mgl32.Vec3{1, 0, 0}}
+	if bt, ok := ray.IntersectAABB(box); !ok || bt != 4 {
+		t.Errorf("got intersection %v at %v for ray along face", ok, bt)
+	}
+	if p := ray.At(2); p != (mgl32.Vec3{-3, 1, 0}) {
+		t.Errorf("got point %v", p)
+	}
+}
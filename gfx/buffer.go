@@ -0,0 +1,315 @@
+package gfx
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Update strategy of buffer
+type BufferUsage int
+
+const (
+	// Content is uploaded once (or rarely) and drawn many times
+	BufferStatic BufferUsage = iota
+
+	// Content is replaced every now and then, old storage is orphaned on
+	// every upload to avoid waiting for draws still using it
+	BufferDynamic
+
+	// Content is uploaded every frame (possibly many times), uploads are
+	// appended to a ring buffer which is persistently mapped and guarded by
+	// fences when ARB_buffer_storage is available, or orphaned when it wraps
+	BufferStreaming
+)
+
+// Number of sections of streaming ring buffer, each is guarded by a fence
+const streamSections = 3
+
+// Usage statistics of buffer
+type BufferStats struct {
+	Uploads       int   // number of uploads and sub-range updates
+	Bytes         int64 // number of bytes uploaded
+	Reallocations int   // number of times storage was (re)allocated
+	Orphans       int   // number of times storage was orphaned
+	Waits         int   // number of times cpu waited for gpu to release storage
+	Capacity      int   // current size of storage in bytes
+}
+
+// Buffer object with storage managed according to usage
+type Buffer struct {
+	id         uint32
+	target     uint32
+	usage      BufferUsage
+	capacity   int
+	alignment  int
+	persistent bool
+	mapped     unsafe.Pointer
+	head       int
+	section    int
+	fences     [streamSections]uintptr
+	stats      BufferStats
+}
+
+// NewBuffer creates buffer bound to target when drawing (e.g. gl.ARRAY_BUFFER,
+// gl.UNIFORM_BUFFER), with initial capacity in bytes, which could be 0.
+func NewBuffer(target uint32, usage BufferUsage, capacity int) *Buffer {
+	b := &Buffer{
+		target:    target,
+		usage:     usage,
+		alignment: 16,
+	}
+
+	// Offsets of uniform and storage blocks have stricter requirement
+	var alignment int32
+	switch target {
+	case gl.UNIFORM_BUFFER:
+		gl.GetIntegerv(gl.UNIFORM_BUFFER_OFFSET_ALIGNMENT, &alignment)
+	case gl.SHADER_STORAGE_BUFFER:
+		gl.GetIntegerv(gl.SHADER_STORAGE_BUFFER_OFFSET_ALIGNMENT, &alignment)
+	}
+	if int(alignment) > b.alignment {
+		b.alignment = int(alignment)
+	}
+
+	if usage == BufferStreaming {
+		b.persistent = VersionAtLeast(4, 4) || HasExtension("GL_ARB_buffer_storage")
+	}
+	b.allocate(capacity)
+	return b
+}
+
+// ID returns name of buffer object. Storage of streaming buffer is
+// recreated when it grows, so ID should be fetched after uploading.
+func (b *Buffer) ID() uint32 {
+	return b.id
+}
+
+// Target returns binding target of buffer.
+func (b *Buffer) Target() uint32 {
+	return b.target
+}
+
+// Capacity returns size of storage in bytes.
+func (b *Buffer) Capacity() int {
+	return b.capacity
+}
+
+// Stats returns usage statistics of buffer.
+func (b *Buffer) Stats() BufferStats {
+	return b.stats
+}
+
+// ResetStats clears usage statistics, e.g. at the beginning of frame.
+func (b *Buffer) ResetStats() {
+	b.stats = BufferStats{Capacity: b.capacity}
+}
+
+// Bind binds buffer to its target.
+func (b *Buffer) Bind() {
+	gl.BindBuffer(b.target, b.id)
+}
+
+// BindRange binds range of buffer to indexed target (uniform or storage
+// block binding point).
+func (b *Buffer) BindRange(index uint32, offset, size int) {
+	gl.BindBufferRange(b.target, index, b.id, offset, size)
+}
+
+// Upload writes data (slice of plain values) to buffer and returns offset
+// of it. Static and dynamic buffers are overwritten from start, while
+// streaming buffers append data after previous uploads.
+func (b *Buffer) Upload(data interface{}) (int, error) {
+	ptr, size, err := sliceData(data)
+	if err != nil {
+		return 0, err
+	}
+	return b.UploadPointer(ptr, size), nil
+}
+
+// UploadPointer is like Upload, but takes raw address and size of data.
+func (b *Buffer) UploadPointer(ptr unsafe.Pointer, size int) int {
+	b.stats.Uploads++
+	b.stats.Bytes += int64(size)
+
+	switch b.usage {
+	case BufferStatic:
+		if size > b.capacity {
+			b.allocate(size)
+		}
+	case BufferDynamic:
+		if size > b.capacity {
+			b.allocate(maxInt(b.capacity*2, size))
+		} else {
+			b.orphan()
+		}
+	case BufferStreaming:
+		return b.stream(ptr, size)
+	}
+	if size > 0 {
+		gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+		gl.BufferSubData(gl.COPY_WRITE_BUFFER, 0, size, ptr)
+	}
+	return 0
+}
+
+// UpdateRange overwrites part of static or dynamic buffer starting at
+// offset, data must fit in current storage.
+func (b *Buffer) UpdateRange(offset int, data interface{}) error {
+	if b.usage == BufferStreaming {
+		return fmt.Errorf("streaming buffer doesn't support sub-range update")
+	}
+	ptr, size, err := sliceData(data)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset+size > b.capacity {
+		return fmt.Errorf("range [%d, %d) is out of buffer capacity %d", offset, offset+size, b.capacity)
+	}
+	if size == 0 {
+		return nil
+	}
+	b.stats.Uploads++
+	b.stats.Bytes += int64(size)
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+	gl.BufferSubData(gl.COPY_WRITE_BUFFER, offset, size, ptr)
+	return nil
+}
+
+// Dispose cleans up the resources.
+func (b *Buffer) Dispose() {
+	b.release()
+	b.capacity = 0
+}
+
+// Create storage of given capacity, old content is discarded
+func (b *Buffer) allocate(capacity int) {
+	if b.usage == BufferStreaming {
+		// Every section must be able to hold the same aligned amount
+		section := (capacity/streamSections + b.alignment - 1) / b.alignment * b.alignment
+		capacity = section * streamSections
+	}
+	b.stats.Reallocations++
+	b.capacity = capacity
+	b.stats.Capacity = capacity
+	b.head = 0
+	b.section = 0
+
+	if !b.persistent {
+		if b.id == 0 {
+			gl.GenBuffers(1, &b.id)
+		}
+		gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+		gl.BufferData(gl.COPY_WRITE_BUFFER, capacity, nil, b.glUsage())
+		return
+	}
+
+	// Immutable storage can't be resized, create new buffer instead
+	b.release()
+	if capacity == 0 {
+		return
+	}
+	flags := uint32(gl.MAP_WRITE_BIT | gl.MAP_PERSISTENT_BIT | gl.MAP_COHERENT_BIT)
+	gl.GenBuffers(1, &b.id)
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+	gl.BufferStorage(gl.COPY_WRITE_BUFFER, capacity, nil, flags)
+	b.mapped = gl.MapBufferRange(gl.COPY_WRITE_BUFFER, 0, capacity, flags)
+}
+
+// Delete buffer object and fences
+func (b *Buffer) release() {
+	for i, f := range b.fences {
+		if f != 0 {
+			gl.DeleteSync(f)
+		}
+		b.fences[i] = 0
+	}
+	if b.id != 0 {
+		if b.mapped != nil {
+			gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+			gl.UnmapBuffer(gl.COPY_WRITE_BUFFER)
+		}
+		gl.DeleteBuffers(1, &b.id)
+	}
+	b.id = 0
+	b.mapped = nil
+}
+
+// Replace storage with new one of the same size, old one is kept by
+// driver until draws using it are done
+func (b *Buffer) orphan() {
+	b.stats.Orphans++
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+	gl.BufferData(gl.COPY_WRITE_BUFFER, b.capacity, nil, b.glUsage())
+}
+
+func (b *Buffer) glUsage() uint32 {
+	switch b.usage {
+	case BufferDynamic:
+		return gl.DYNAMIC_DRAW
+	case BufferStreaming:
+		return gl.STREAM_DRAW
+	}
+	return gl.STATIC_DRAW
+}
+
+// Append data to ring buffer, return offset of it
+func (b *Buffer) stream(ptr unsafe.Pointer, size int) int {
+	sectionSize := b.capacity / streamSections
+	if size > sectionSize {
+		b.allocate(maxInt(b.capacity*2, size*streamSections))
+		sectionSize = b.capacity / streamSections
+	}
+	offset := (b.head + b.alignment - 1) / b.alignment * b.alignment
+	if offset+size > (b.section+1)*sectionSize {
+		// Fence draws using current section, then move on to next one
+		// and wait until gpu is done with it
+		if b.persistent {
+			b.fences[b.section] = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+		}
+		b.section = (b.section + 1) % streamSections
+		offset = b.section * sectionSize
+		if b.persistent {
+			b.waitSection(b.section)
+		} else if b.section == 0 {
+			b.orphan()
+		}
+	}
+	b.head = offset + size
+	if size == 0 {
+		return offset
+	}
+
+	src := (*[1 << 30]byte)(ptr)[:size:size]
+	if b.persistent {
+		dst := (*[1 << 30]byte)(b.mapped)[offset : offset+size : offset+size]
+		copy(dst, src)
+		return offset
+	}
+
+	// Range after last orphaning is never used by gpu, no need to synchronize
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, b.id)
+	access := uint32(gl.MAP_WRITE_BIT | gl.MAP_INVALIDATE_RANGE_BIT | gl.MAP_UNSYNCHRONIZED_BIT)
+	dst := gl.MapBufferRange(gl.COPY_WRITE_BUFFER, offset, size, access)
+	copy((*[1 << 30]byte)(dst)[:size:size], src)
+	gl.UnmapBuffer(gl.COPY_WRITE_BUFFER)
+	return offset
+}
+
+// Wait for fence guarding section of ring buffer
+func (b *Buffer) waitSection(section int) {
+	fence := b.fences[section]
+	if fence == 0 {
+		return
+	}
+	status := gl.ClientWaitSync(fence, 0, 0)
+	if status == gl.TIMEOUT_EXPIRED {
+		b.stats.Waits++
+		for status == gl.TIMEOUT_EXPIRED {
+			status = gl.ClientWaitSync(fence, gl.SYNC_FLUSH_COMMANDS_BIT, 1e9)
+		}
+	}
+	gl.DeleteSync(fence)
+	b.fences[section] = 0
+}
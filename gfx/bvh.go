@@ -0,0 +1,364 @@
+package gfx
+
+import (
+	"math"
+)
+
+// No node of tree
+const bvhNull = -1
+
+// Node of tree, leaves hold scene nodes and have no children
+type bvhNode struct {
+	box    AABB // fattened for leaves
+	parent int32
+	left   int32
+	right  int32
+	node   *Node
+}
+
+func (n *bvhNode) isLeaf() bool {
+	return n.left == bvhNull
+}
+
+// State of scene node tracked by tree
+type bvhLeaf struct {
+	index  int32 // bvhNull for node without bounds
+	bounds AABB  // tight world bounds
+	moves  uint32
+	mesh   *Mesh
+	seen   bool
+}
+
+// Culling statistics of last draw or query
+type CullStats struct {
+	Objects    int // number of tracked objects
+	Considered int // number of objects whose bounds were tested
+	Drawn      int // number of objects found visible
+}
+
+// BVH is a dynamic bounding volume hierarchy of mesh nodes of scene, used
+// for frustum culling and ray queries. Leaves keep boxes enlarged by
+// Margin, so nodes moving a little don't change the tree, nodes moving
+// further are reinserted.
+type BVH struct {
+	Margin float32 // enlargement of leaf boxes in world units
+
+	nodes     []bvhNode
+	free      []int32
+	root      int32
+	leaves    map[*Node]*bvhLeaf
+	unbounded []*Node // nodes whose meshes have no bounds, always visible
+	stats     CullStats
+}
+
+// NewBVH creates empty hierarchy.
+func NewBVH() *BVH {
+	return &BVH{
+		Margin: 0.1,
+		root:   bvhNull,
+		leaves: map[*Node]*bvhLeaf{},
+	}
+}
+
+// Update synchronizes hierarchy with visible mesh nodes of scene: new
+// nodes are inserted, moved ones updated and removed or hidden ones
+// dropped. It should be called once per frame before queries.
+func (b *BVH) Update(scene *Node) {
+	for _, leaf := range b.leaves {
+		leaf.seen = false
+	}
+	b.unbounded = b.unbounded[:0]
+	scene.Traverse(func(node *Node) bool {
+		if !node.Visible {
+			return false
+		}
+		if node.Mesh != nil {
+			b.track(node)
+		}
+		return true
+	})
+	for node, leaf := range b.leaves {
+		if !leaf.seen {
+			if leaf.index != bvhNull {
+				b.removeLeaf(leaf.index)
+			}
+			delete(b.leaves, node)
+		}
+	}
+}
+
+// Len returns number of tracked nodes.
+func (b *BVH) Len() int {
+	return len(b.leaves)
+}
+
+// Bounds returns box containing all tracked nodes, except those without
+// bounds.
+func (b *BVH) Bounds() AABB {
+	if b.root == bvhNull {
+		return EmptyAABB()
+	}
+	return b.nodes[b.root].box
+}
+
+// Query calls fn for every tracked node whose bounds intersect frustum,
+// and nodes without bounds.
+func (b *BVH) Query(frustum Frustum, fn func(node *Node)) {
+	b.stats = CullStats{Objects: len(b.leaves)}
+	for _, node := range b.unbounded {
+		b.stats.Drawn++
+		fn(node)
+	}
+	if b.root == bvhNull {
+		return
+	}
+	stack := []int32{b.root}
+	for len(stack) > 0 {
+		n := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !frustum.IntersectsAABB(n.box) {
+			continue
+		}
+		if !n.isLeaf() {
+			stack = append(stack, n.left, n.right)
+			continue
+		}
+		b.stats.Considered++
+		if frustum.IntersectsAABB(b.leaves[n.node].bounds) {
+			b.stats.Drawn++
+			fn(n.node)
+		}
+	}
+}
+
+// Raycast returns the nearest tracked node whose bounds are hit by ray,
+// and distance to the hit. Nodes without bounds are ignored.
+func (b *BVH) Raycast(ray Ray) (*Node, float32, bool) {
+	var nearest *Node
+	best := float32(math.Inf(1))
+	if b.root == bvhNull {
+		return nil, 0, false
+	}
+	stack := []int32{b.root}
+	for len(stack) > 0 {
+		n := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if t, hit := ray.IntersectAABB(n.box); !hit || t >= best {
+			continue
+		}
+		if !n.isLeaf() {
+			stack = append(stack, n.left, n.right)
+			continue
+		}
+		if t, hit := ray.IntersectAABB(b.leaves[n.node].bounds); hit && t < best {
+			nearest, best = n.node, t
+		}
+	}
+	if nearest == nil {
+		return nil, 0, false
+	}
+	return nearest, best, true
+}
+
+// Draw renders tracked nodes inside view of camera, like Node.Draw.
+// Transparent materials are drawn last, back to front.
+func (b *BVH) Draw(camera Camera) {
+	projection, view := camera.Projection(), camera.View()
+	var opaque, transparent []*Node
+	b.Query(NewFrustum(projection.Mul4(view)), func(node *Node) {
+		if node.Material == nil {
+			return
+		}
+		if node.Material.Transparent {
+			transparent = append(transparent, node)
+		} else {
+			opaque = append(opaque, node)
+		}
+	})
+	drawNodes(opaque, projection, view)
+	drawTransparent(transparent, projection, view)
+}
+
+// Stats returns statistics of the last draw or query.
+func (b *BVH) Stats() CullStats {
+	return b.stats
+}
+
+// Walk calls fn for every box of hierarchy with its depth, leaves are
+// reported with their enlarged boxes. Useful for debug drawing.
+func (b *BVH) Walk(fn func(box AABB, depth int, leaf bool)) {
+	if b.root == bvhNull {
+		return
+	}
+	var walk func(index int32, depth int)
+	walk = func(index int32, depth int) {
+		n := &b.nodes[index]
+		fn(n.box, depth, n.isLeaf())
+		if !n.isLeaf() {
+			walk(n.left, depth+1)
+			walk(n.right, depth+1)
+		}
+	}
+	walk(b.root, 0)
+}
+
+// Insert, update or keep leaf of node
+func (b *BVH) track(node *Node) {
+	leaf, ok := b.leaves[node]
+	if !ok {
+		leaf = &bvhLeaf{index: bvhNull}
+		b.leaves[node] = leaf
+	} else if leaf.moves == node.moves && leaf.mesh == node.Mesh {
+		leaf.seen = true
+		if leaf.index == bvhNull {
+			b.unbounded = append(b.unbounded, node)
+		}
+		return
+	}
+	leaf.seen = true
+	leaf.moves = node.moves
+	leaf.mesh = node.Mesh
+	leaf.bounds = node.WorldBounds()
+
+	if leaf.bounds.IsEmpty() {
+		if leaf.index != bvhNull {
+			b.removeLeaf(leaf.index)
+			leaf.index = bvhNull
+		}
+		b.unbounded = append(b.unbounded, node)
+		return
+	}
+	if leaf.index != bvhNull {
+		if containsBox(b.nodes[leaf.index].box, leaf.bounds) {
+			return
+		}
+		b.removeLeaf(leaf.index)
+	}
+	leaf.index = b.insertLeaf(node, b.fatten(leaf.bounds))
+}
+
+// Get box enlarged by margin
+func (b *BVH) fatten(box AABB) AABB {
+	m := b.Margin
+	box.Min = box.Min.Sub([3]float32{m, m, m})
+	box.Max = box.Max.Add([3]float32{m, m, m})
+	return box
+}
+
+func (b *BVH) allocate() int32 {
+	if n := len(b.free); n > 0 {
+		index := b.free[n-1]
+		b.free = b.free[:n-1]
+		return index
+	}
+	b.nodes = append(b.nodes, bvhNode{})
+	return int32(len(b.nodes) - 1)
+}
+
+func (b *BVH) release(index int32) {
+	b.nodes[index] = bvhNode{}
+	b.free = append(b.free, index)
+}
+
+// Insert leaf next to the sibling which increases surface area the least
+func (b *BVH) insertLeaf(node *Node, box AABB) int32 {
+	leaf := b.allocate()
+	b.nodes[leaf] = bvhNode{box: box, parent: bvhNull, left: bvhNull, right: bvhNull, node: node}
+	if b.root == bvhNull {
+		b.root = leaf
+		return leaf
+	}
+
+	sibling := b.root
+	for !b.nodes[sibling].isLeaf() {
+		n := b.nodes[sibling]
+		area := surfaceArea(n.box)
+		combined := surfaceArea(n.box.Union(box))
+
+		// Cost of new parent here, versus descending into a child
+		cost := 2 * combined
+		inherited := 2 * (combined - area)
+		childCost := func(child int32) float32 {
+			c := b.nodes[child]
+			enlarged := surfaceArea(c.box.Union(box))
+			if c.isLeaf() {
+				return enlarged + inherited
+			}
+			return enlarged - surfaceArea(c.box) + inherited
+		}
+		leftCost, rightCost := childCost(n.left), childCost(n.right)
+		if cost < leftCost && cost < rightCost {
+			break
+		}
+		if leftCost < rightCost {
+			sibling = n.left
+		} else {
+			sibling = n.right
+		}
+	}
+
+	oldParent := b.nodes[sibling].parent
+	parent := b.allocate()
+	b.nodes[parent] = bvhNode{
+		box:    b.nodes[sibling].box.Union(box),
+		parent: oldParent,
+		left:   sibling,
+		right:  leaf,
+	}
+	b.nodes[sibling].parent = parent
+	b.nodes[leaf].parent = parent
+	if oldParent == bvhNull {
+		b.root = parent
+	} else if b.nodes[oldParent].left == sibling {
+		b.nodes[oldParent].left = parent
+	} else {
+		b.nodes[oldParent].right = parent
+	}
+	b.refit(oldParent)
+	return leaf
+}
+
+// Remove leaf, its sibling takes place of their parent
+func (b *BVH) removeLeaf(leaf int32) {
+	if leaf == b.root {
+		b.root = bvhNull
+		b.release(leaf)
+		return
+	}
+	parent := b.nodes[leaf].parent
+	grandParent := b.nodes[parent].parent
+	sibling := b.nodes[parent].left
+	if sibling == leaf {
+		sibling = b.nodes[parent].right
+	}
+	b.nodes[sibling].parent = grandParent
+	if grandParent == bvhNull {
+		b.root = sibling
+	} else if b.nodes[grandParent].left == parent {
+		b.nodes[grandParent].left = sibling
+	} else {
+		b.nodes[grandParent].right = sibling
+	}
+	b.release(parent)
+	b.release(leaf)
+	b.refit(grandParent)
+}
+
+// Recompute boxes of node and its ancestors
+func (b *BVH) refit(index int32) {
+	for index != bvhNull {
+		n := &b.nodes[index]
+		n.box = b.nodes[n.left].box.Union(b.nodes[n.right].box)
+		index = n.parent
+	}
+}
+
+func surfaceArea(b AABB) float32 {
+	s := b.Size()
+	return 2 * (s[0]*s[1] + s[1]*s[2] + s[2]*s[0])
+}
+
+// Report whether outer box contains inner box
+func containsBox(outer, inner AABB) bool {
+	return outer.Contains(inner.Min) && outer.Contains(inner.Max)
+}
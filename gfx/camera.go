@@ -0,0 +1,188 @@
+package gfx
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Camera provides view and projection matrices of scene
+type Camera interface {
+	// View returns world to view space matrix.
+	View() mgl32.Mat4
+
+	// Projection returns view to clip space matrix.
+	Projection() mgl32.Mat4
+
+	// SetViewport updates size of viewport in window coordinates.
+	SetViewport(width, height int32)
+
+	// ProcessEvent follows window size changes.
+	ProcessEvent(event sdl.Event)
+
+	// ScreenRay returns ray in world space passing through point of viewport.
+	ScreenRay(x, y float32) Ray
+}
+
+// Position and orientation of camera, along with size of its viewport.
+// Camera looks down -Z axis of its local space, with Y axis being up.
+type CameraView struct {
+	Position mgl32.Vec3
+	Rotation mgl32.Quat
+	width    int32
+	height   int32
+}
+
+// LookAt moves camera to eye and turns it to target.
+func (v *CameraView) LookAt(eye, target, up mgl32.Vec3) {
+	v.Position = eye
+	back := eye.Sub(target).Normalize()
+	right := up.Cross(back)
+	if right.Len() < 1e-6 {
+		// Up is parallel to looking direction, pick any perpendicular one
+		right = mgl32.Vec3{0, 0, 1}.Cross(back)
+		if right.Len() < 1e-6 {
+			right = mgl32.Vec3{1, 0, 0}.Cross(back)
+		}
+	}
+	right = right.Normalize()
+	v.Rotation = mgl32.Mat4ToQuat(mgl32.Mat3FromCols(right, back.Cross(right), back).Mat4()).Normalize()
+}
+
+// Forward returns looking direction in world space.
+func (v *CameraView) Forward() mgl32.Vec3 {
+	return v.Rotation.Rotate(mgl32.Vec3{0, 0, -1})
+}
+
+// Right returns right direction in world space.
+func (v *CameraView) Right() mgl32.Vec3 {
+	return v.Rotation.Rotate(mgl32.Vec3{1, 0, 0})
+}
+
+// Up returns up direction in world space.
+func (v *CameraView) Up() mgl32.Vec3 {
+	return v.Rotation.Rotate(mgl32.Vec3{0, 1, 0})
+}
+
+// View returns world to view space matrix.
+func (v *CameraView) View() mgl32.Mat4 {
+	return v.Rotation.Conjugate().Mat4().Mul4(mgl32.Translate3D(-v.Position[0], -v.Position[1], -v.Position[2]))
+}
+
+// World returns view to world space matrix.
+func (v *CameraView) World() mgl32.Mat4 {
+	return mgl32.Translate3D(v.Position[0], v.Position[1], v.Position[2]).Mul4(v.Rotation.Mat4())
+}
+
+// SetViewport updates size of viewport in window coordinates.
+func (v *CameraView) SetViewport(width, height int32) {
+	v.width, v.height = width, height
+}
+
+// Viewport returns size of viewport in window coordinates.
+func (v *CameraView) Viewport() (int32, int32) {
+	return v.width, v.height
+}
+
+// Aspect returns width/height ratio of viewport, 1 if viewport is empty.
+func (v *CameraView) Aspect() float32 {
+	if v.width <= 0 || v.height <= 0 {
+		return 1
+	}
+	return float32(v.width) / float32(v.height)
+}
+
+// ProcessEvent follows window size changes.
+func (v *CameraView) ProcessEvent(event sdl.Event) {
+	if e, ok := event.(*sdl.WindowEvent); ok && e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+		v.SetViewport(e.Data1, e.Data2)
+	}
+}
+
+// Get ray through point of viewport by unprojecting it onto near and far
+// planes, empty viewport (e.g. minimized window) gives ray through center
+func (v *CameraView) screenRay(x, y float32, projection mgl32.Mat4) Ray {
+	inv := projection.Mul4(v.View()).Inv()
+	var ndcX, ndcY float32
+	if v.width > 0 && v.height > 0 {
+		ndcX = 2*x/float32(v.width) - 1
+		ndcY = 1 - 2*y/float32(v.height)
+	}
+	near := mgl32.TransformCoordinate(mgl32.Vec3{ndcX, ndcY, -1}, inv)
+	far := mgl32.TransformCoordinate(mgl32.Vec3{ndcX, ndcY, 1}, inv)
+	return Ray{
+		Origin:    near,
+		Direction: far.Sub(near).Normalize(),
+	}
+}
+
+// Camera with perspective projection
+type PerspectiveCamera struct {
+	CameraView
+	Fovy float32 // vertical field of view in radians
+	Near float32
+	Far  float32
+}
+
+// NewPerspectiveCamera creates perspective camera at origin looking down -Z axis.
+func NewPerspectiveCamera(fovy float32, width, height int32, near, far float32) *PerspectiveCamera {
+	c := &PerspectiveCamera{
+		CameraView: CameraView{Rotation: mgl32.QuatIdent()},
+		Fovy:       fovy,
+		Near:       near,
+		Far:        far,
+	}
+	c.SetViewport(width, height)
+	return c
+}
+
+// Projection returns view to clip space matrix.
+func (c *PerspectiveCamera) Projection() mgl32.Mat4 {
+	return mgl32.Perspective(c.Fovy, c.Aspect(), c.Near, c.Far)
+}
+
+// ScreenRay returns ray in world space passing through point of viewport,
+// which starts at near plane. Ray goes through center of empty viewport.
+func (c *PerspectiveCamera) ScreenRay(x, y float32) Ray {
+	return c.screenRay(x, y, c.Projection())
+}
+
+// Camera with orthographic projection
+type OrthographicCamera struct {
+	CameraView
+	Height float32 // height of view volume, width is determined by aspect ratio
+	Near   float32
+	Far    float32
+}
+
+// NewOrthographicCamera creates orthographic camera at origin looking down -Z axis.
+func NewOrthographicCamera(height float32, width, viewportHeight int32, near, far float32) *OrthographicCamera {
+	c := &OrthographicCamera{
+		CameraView: CameraView{Rotation: mgl32.QuatIdent()},
+		Height:     height,
+		Near:       near,
+		Far:        far,
+	}
+	c.SetViewport(width, viewportHeight)
+	return c
+}
+
+// Projection returns view to clip space matrix.
+func (c *OrthographicCamera) Projection() mgl32.Mat4 {
+	halfHeight := c.Height / 2
+	halfWidth := halfHeight * c.Aspect()
+	return mgl32.Ortho(-halfWidth, halfWidth, -halfHeight, halfHeight, c.Near, c.Far)
+}
+
+// ScreenRay returns ray in world space passing through point of viewport,
+// which starts at near plane. Ray goes through center of empty viewport.
+func (c *OrthographicCamera) ScreenRay(x, y float32) Ray {
+	return c.screenRay(x, y, c.Projection())
+}
+
+// UnitsPerPixel returns size of one pixel of viewport in world units.
+func (c *OrthographicCamera) UnitsPerPixel() float32 {
+	if c.height <= 0 {
+		return 0
+	}
+	return c.Height / float32(c.height)
+}
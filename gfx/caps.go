@@ -0,0 +1,41 @@
+package gfx
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Capabilities of current opengl context, queried lazily
+var caps struct {
+	queried    bool
+	major      int32
+	minor      int32
+	extensions map[string]bool
+}
+
+func queryCaps() {
+	if caps.queried {
+		return
+	}
+	gl.GetIntegerv(gl.MAJOR_VERSION, &caps.major)
+	gl.GetIntegerv(gl.MINOR_VERSION, &caps.minor)
+
+	var extNum int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &extNum)
+	caps.extensions = make(map[string]bool, extNum)
+	for i := int32(0); i < extNum; i++ {
+		caps.extensions[gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i)))] = true
+	}
+	caps.queried = true
+}
+
+// VersionAtLeast reports whether version of current context is at least major.minor.
+func VersionAtLeast(major, minor int32) bool {
+	queryCaps()
+	return caps.major > major || (caps.major == major && caps.minor >= minor)
+}
+
+// HasExtension reports whether current context supports extension.
+func HasExtension(name string) bool {
+	queryCaps()
+	return caps.extensions[name]
+}
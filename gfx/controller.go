@@ -0,0 +1,315 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// InputCapturer reports whether ui is consuming input (e.g. *iu.Context),
+// controllers leave such input alone.
+type InputCapturer interface {
+	WantCaptureMouse() bool
+	WantCaptureKeyboard() bool
+}
+
+// Controller moves camera according to user input
+type Controller interface {
+	// ProcessEvent handles input event.
+	ProcessEvent(event sdl.Event)
+
+	// Update advances continuous movement by dt seconds.
+	Update(dt float32)
+}
+
+// Limit of pitch, which keeps camera from flipping over
+const maxPitch = math.Pi/2 - 0.01
+
+func wantMouse(ui InputCapturer) bool {
+	return ui == nil || !ui.WantCaptureMouse()
+}
+
+func wantKeyboard(ui InputCapturer) bool {
+	return ui == nil || !ui.WantCaptureKeyboard()
+}
+
+// Get yaw (around Y axis) and pitch (around X axis) of looking direction
+func yawPitch(forward mgl32.Vec3) (float32, float32) {
+	forward = forward.Normalize()
+	yaw := math.Atan2(float64(-forward[0]), float64(-forward[2]))
+	pitch := math.Asin(float64(mgl32.Clamp(forward[1], -1, 1)))
+	return float32(yaw), float32(pitch)
+}
+
+// Get rotation of camera with yaw and pitch, without roll
+func yawPitchRotation(yaw, pitch float32) mgl32.Quat {
+	return mgl32.QuatRotate(yaw, mgl32.Vec3{0, 1, 0}).Mul(mgl32.QuatRotate(pitch, mgl32.Vec3{1, 0, 0}))
+}
+
+// Get scrolled amount of wheel event, positive when scrolling away from user
+func wheelDelta(e *sdl.MouseWheelEvent) float32 {
+	if e.Direction == sdl.MOUSEWHEEL_FLIPPED {
+		return float32(-e.Y)
+	}
+	return float32(e.Y)
+}
+
+// Controller rotating camera around target, zooming with wheel.
+// Left button drag rotates, right or middle button drag pans.
+type OrbitController struct {
+	Camera      *CameraView
+	Target      mgl32.Vec3
+	Distance    float32
+	Yaw         float32
+	Pitch       float32
+	RotateSpeed float32 // radians per pixel
+	PanSpeed    float32 // distance units per pixel
+	ZoomFactor  float32 // distance ratio per wheel step
+	MinDistance float32
+	MaxDistance float32
+	ui          InputCapturer
+	rotating    bool
+	panning     bool
+}
+
+// NewOrbitController creates orbit controller around target, keeping
+// camera's current position. ui could be nil.
+func NewOrbitController(camera *CameraView, ui InputCapturer, target mgl32.Vec3) *OrbitController {
+	c := &OrbitController{
+		Camera:      camera,
+		Target:      target,
+		Distance:    camera.Position.Sub(target).Len(),
+		RotateSpeed: 0.01,
+		PanSpeed:    0.002,
+		ZoomFactor:  1.1,
+		MinDistance: 0.1,
+		MaxDistance: 1000,
+		ui:          ui,
+	}
+	if c.Distance > 0 {
+		c.Yaw, c.Pitch = yawPitch(target.Sub(camera.Position))
+	}
+	c.apply()
+	return c
+}
+
+// ProcessEvent handles input event.
+func (c *OrbitController) ProcessEvent(event sdl.Event) {
+	switch e := event.(type) {
+	case *sdl.MouseButtonEvent:
+		if e.Type == sdl.MOUSEBUTTONUP {
+			switch e.Button {
+			case sdl.BUTTON_LEFT:
+				c.rotating = false
+			case sdl.BUTTON_RIGHT, sdl.BUTTON_MIDDLE:
+				c.panning = false
+			}
+			return
+		}
+		if !wantMouse(c.ui) {
+			return
+		}
+		switch e.Button {
+		case sdl.BUTTON_LEFT:
+			c.rotating = true
+		case sdl.BUTTON_RIGHT, sdl.BUTTON_MIDDLE:
+			c.panning = true
+		}
+	case *sdl.MouseMotionEvent:
+		dx, dy := float32(e.XRel), float32(e.YRel)
+		if c.rotating {
+			c.Yaw -= dx * c.RotateSpeed
+			c.Pitch = mgl32.Clamp(c.Pitch-dy*c.RotateSpeed, -maxPitch, maxPitch)
+		} else if c.panning {
+			scale := c.PanSpeed * c.Distance
+			c.Target = c.Target.
+				Sub(c.Camera.Right().Mul(dx * scale)).
+				Add(c.Camera.Up().Mul(dy * scale))
+		} else {
+			return
+		}
+		c.apply()
+	case *sdl.MouseWheelEvent:
+		if !wantMouse(c.ui) {
+			return
+		}
+		c.Distance *= float32(math.Pow(float64(c.ZoomFactor), float64(-wheelDelta(e))))
+		c.apply()
+	}
+}
+
+// Update does nothing, orbit controller moves camera on events only.
+func (c *OrbitController) Update(dt float32) {
+}
+
+// Place camera according to parameters
+func (c *OrbitController) apply() {
+	c.Distance = mgl32.Clamp(c.Distance, c.MinDistance, c.MaxDistance)
+	c.Camera.Rotation = yawPitchRotation(c.Yaw, c.Pitch)
+	c.Camera.Position = c.Target.Sub(c.Camera.Forward().Mul(c.Distance))
+}
+
+// First-person controller, moving with WASD (Q/E for down/up, shift to
+// speed up) and looking around with mouse while right button is held.
+type FlyController struct {
+	Camera      *CameraView
+	Yaw         float32
+	Pitch       float32
+	Speed       float32 // distance units per second
+	BoostFactor float32 // speed ratio when shift is held
+	Sensitivity float32 // radians per pixel
+	ui          InputCapturer
+	looking     bool
+	keys        map[sdl.Scancode]bool
+}
+
+// NewFlyController creates fly controller, keeping camera's current
+// looking direction. ui could be nil.
+func NewFlyController(camera *CameraView, ui InputCapturer) *FlyController {
+	c := &FlyController{
+		Camera:      camera,
+		Speed:       3,
+		BoostFactor: 4,
+		Sensitivity: 0.003,
+		ui:          ui,
+		keys:        map[sdl.Scancode]bool{},
+	}
+	c.Yaw, c.Pitch = yawPitch(camera.Forward())
+	return c
+}
+
+// ProcessEvent handles input event.
+func (c *FlyController) ProcessEvent(event sdl.Event) {
+	switch e := event.(type) {
+	case *sdl.MouseButtonEvent:
+		if e.Button != sdl.BUTTON_RIGHT {
+			return
+		}
+		if e.Type == sdl.MOUSEBUTTONUP {
+			c.setLooking(false)
+		} else if wantMouse(c.ui) {
+			c.setLooking(true)
+		}
+	case *sdl.MouseMotionEvent:
+		if !c.looking {
+			return
+		}
+		c.Yaw -= float32(e.XRel) * c.Sensitivity
+		c.Pitch = mgl32.Clamp(c.Pitch-float32(e.YRel)*c.Sensitivity, -maxPitch, maxPitch)
+		c.Camera.Rotation = yawPitchRotation(c.Yaw, c.Pitch)
+	case *sdl.KeyboardEvent:
+		// Releasing is always accepted, so keys don't get stuck
+		if e.Type == sdl.KEYUP {
+			delete(c.keys, e.Keysym.Scancode)
+		} else if wantKeyboard(c.ui) {
+			c.keys[e.Keysym.Scancode] = true
+		}
+	}
+}
+
+// Update moves camera according to keys being held.
+func (c *FlyController) Update(dt float32) {
+	var move mgl32.Vec3
+	axes := []struct {
+		key sdl.Scancode
+		dir mgl32.Vec3
+	}{
+		{sdl.SCANCODE_W, c.Camera.Forward()},
+		{sdl.SCANCODE_S, c.Camera.Forward().Mul(-1)},
+		{sdl.SCANCODE_D, c.Camera.Right()},
+		{sdl.SCANCODE_A, c.Camera.Right().Mul(-1)},
+		{sdl.SCANCODE_E, mgl32.Vec3{0, 1, 0}},
+		{sdl.SCANCODE_Q, mgl32.Vec3{0, -1, 0}},
+	}
+	for _, a := range axes {
+		if c.keys[a.key] {
+			move = move.Add(a.dir)
+		}
+	}
+	if move.Len() == 0 {
+		return
+	}
+	speed := c.Speed
+	if c.keys[sdl.SCANCODE_LSHIFT] || c.keys[sdl.SCANCODE_RSHIFT] {
+		speed *= c.BoostFactor
+	}
+	c.Camera.Position = c.Camera.Position.Add(move.Normalize().Mul(speed * dt))
+}
+
+// Hide cursor and report relative motion only while looking around
+func (c *FlyController) setLooking(looking bool) {
+	if c.looking != looking {
+		sdl.SetRelativeMouseMode(looking)
+	}
+	c.looking = looking
+}
+
+// Controller for 2d views, dragging with left or middle button pans and
+// wheel zooms around cursor.
+type PanZoomController struct {
+	Camera     *OrthographicCamera
+	ZoomFactor float32 // height ratio per wheel step
+	MinHeight  float32
+	MaxHeight  float32
+	ui         InputCapturer
+	panning    bool
+}
+
+// NewPanZoomController creates pan/zoom controller of orthographic camera.
+// ui could be nil.
+func NewPanZoomController(camera *OrthographicCamera, ui InputCapturer) *PanZoomController {
+	return &PanZoomController{
+		Camera:     camera,
+		ZoomFactor: 1.1,
+		MinHeight:  0.01,
+		MaxHeight:  1e6,
+		ui:         ui,
+	}
+}
+
+// ProcessEvent handles input event.
+func (c *PanZoomController) ProcessEvent(event sdl.Event) {
+	switch e := event.(type) {
+	case *sdl.MouseButtonEvent:
+		if e.Button != sdl.BUTTON_LEFT && e.Button != sdl.BUTTON_MIDDLE {
+			return
+		}
+		if e.Type == sdl.MOUSEBUTTONUP {
+			c.panning = false
+		} else if wantMouse(c.ui) {
+			c.panning = true
+		}
+	case *sdl.MouseMotionEvent:
+		if !c.panning {
+			return
+		}
+		c.move(float32(-e.XRel)*c.Camera.UnitsPerPixel(), float32(e.YRel)*c.Camera.UnitsPerPixel())
+	case *sdl.MouseWheelEvent:
+		if !wantMouse(c.ui) {
+			return
+		}
+
+		// Keep point under cursor in place
+		x, y, _ := sdl.GetMouseState()
+		width, height := c.Camera.Viewport()
+		px := float32(x) - float32(width)/2
+		py := float32(height)/2 - float32(y)
+		before := c.Camera.UnitsPerPixel()
+		zoom := float32(math.Pow(float64(c.ZoomFactor), float64(-wheelDelta(e))))
+		c.Camera.Height = mgl32.Clamp(c.Camera.Height*zoom, c.MinHeight, c.MaxHeight)
+		after := c.Camera.UnitsPerPixel()
+		c.move(px*(before-after), py*(before-after))
+	}
+}
+
+// Update does nothing, pan/zoom controller moves camera on events only.
+func (c *PanZoomController) Update(dt float32) {
+}
+
+// Move camera along its right and up directions
+func (c *PanZoomController) move(dx, dy float32) {
+	c.Camera.Position = c.Camera.Position.
+		Add(c.Camera.Right().Mul(dx)).
+		Add(c.Camera.Up().Mul(dy))
+}
@@ -0,0 +1,340 @@
+package gfx
+
+import (
+	_ "embed"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/debug/debug.vert
+var debugVertexShader string
+
+//go:embed shaders/debug/debug.frag
+var debugFragmentShader string
+
+// Number of segments of debug circles
+const debugCircleSegments = 32
+
+// Vertex of debug line, color is packed RGBA
+type debugVertex struct {
+	Position mgl32.Vec3
+	Color    [4]uint8
+}
+
+// Lines of one depth mode, expiry is kept per line
+type debugLines struct {
+	vertices []debugVertex // pairs of line ends
+	expires  []time.Time
+}
+
+// Text which faces camera, so it's turned into lines when drawing
+type debugText struct {
+	position mgl32.Vec3
+	text     string
+	height   float32
+	color    [4]uint8
+	expires  time.Time
+	onTop    bool
+}
+
+// DebugDraw collects lines, shapes and text in immediate mode and draws
+// them at once from a single dynamic buffer. Primitives are drawn in the
+// frame they are added, or for Duration when it's set. Settings apply to
+// primitives added afterwards.
+type DebugDraw struct {
+	Duration time.Duration // how long primitives stay, 0 for a single frame
+	OnTop    bool          // draw over scene regardless of depth
+
+	lines    [2]debugLines // depth tested, on top
+	texts    []debugText
+	vertices []debugVertex
+	buffer   *Buffer
+	vao      uint32
+	program  uint32
+	location int32
+}
+
+// NewDebugDraw creates empty debug drawing.
+func NewDebugDraw() (*DebugDraw, error) {
+	program, err := LoadShaders([]Shader{
+		{Source: "#version 330 core\n" + debugVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + debugFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := &DebugDraw{
+		program:  program,
+		location: gl.GetUniformLocation(program, gl.Str("viewProjection\x00")),
+		buffer:   NewBuffer(gl.ARRAY_BUFFER, BufferDynamic, 0),
+	}
+
+	// Storage of dynamic buffer keeps its name, so layout is set once
+	stride := int32(unsafe.Sizeof(debugVertex{}))
+	gl.GenVertexArrays(1, &d.vao)
+	gl.BindVertexArray(d.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, d.buffer.ID())
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride, nil)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(1, 4, gl.UNSIGNED_BYTE, true, stride, gl.PtrOffset(12))
+	gl.BindVertexArray(0)
+	return d, nil
+}
+
+// Line adds line between points.
+func (d *DebugDraw) Line(from, to mgl32.Vec3, color mgl32.Vec4) {
+	d.addLine(from, to, packDebugColor(color), d.expiry())
+}
+
+// Arrow adds line from point to point with arrowhead at its end.
+func (d *DebugDraw) Arrow(from, to mgl32.Vec3, color mgl32.Vec4) {
+	c, expires := packDebugColor(color), d.expiry()
+	d.addLine(from, to, c, expires)
+	dir := to.Sub(from)
+	length := dir.Len()
+	if length == 0 {
+		return
+	}
+	dir = dir.Mul(1 / length)
+	side := dir.Cross(perpendicularUp(dir)).Normalize()
+	up := side.Cross(dir)
+	head := length * 0.2
+	base := to.Sub(dir.Mul(head))
+	for _, offset := range []mgl32.Vec3{side, side.Mul(-1), up, up.Mul(-1)} {
+		d.addLine(to, base.Add(offset.Mul(head*0.4)), c, expires)
+	}
+}
+
+// AABB adds edges of box.
+func (d *DebugDraw) AABB(box AABB, color mgl32.Vec4) {
+	if box.IsEmpty() {
+		return
+	}
+	d.addBox(box.Corners(), packDebugColor(color), d.expiry())
+}
+
+// Sphere adds circles of sphere in planes of axes.
+func (d *DebugDraw) Sphere(sphere Sphere, color mgl32.Vec4) {
+	c, expires := packDebugColor(color), d.expiry()
+	axes := []mgl32.Vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	for i := range axes {
+		d.addCircle(sphere.Center, axes[i].Mul(sphere.Radius), axes[(i+1)%3].Mul(sphere.Radius), c, expires)
+	}
+}
+
+// Frustum adds edges of volume which viewProjection maps to clip space,
+// e.g. projection of camera multiplied by its view matrix.
+func (d *DebugDraw) Frustum(viewProjection mgl32.Mat4, color mgl32.Vec4) {
+	inv := viewProjection.Inv()
+	var corners [8]mgl32.Vec3
+	for i := range corners {
+		var ndc mgl32.Vec3
+		for j := 0; j < 3; j++ {
+			ndc[j] = -1
+			if i&(1<<j) != 0 {
+				ndc[j] = 1
+			}
+		}
+		corners[i] = mgl32.TransformCoordinate(ndc, inv)
+	}
+	d.addBox(corners, packDebugColor(color), d.expiry())
+}
+
+// Axis adds X, Y and Z axes (red, green, blue) of space transformed by
+// matrix m, of length size.
+func (d *DebugDraw) Axis(m mgl32.Mat4, size float32) {
+	expires := d.expiry()
+	origin := m.Col(3).Vec3()
+	colors := [][4]uint8{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	for i, c := range colors {
+		axis := m.Col(i).Vec3()
+		if axis.Len() > 0 {
+			axis = axis.Normalize()
+		}
+		d.addLine(origin, origin.Add(axis.Mul(size)), c, expires)
+	}
+}
+
+// Grid adds square grid of size in XZ plane around center, split into
+// divisions cells along each side.
+func (d *DebugDraw) Grid(center mgl32.Vec3, size float32, divisions int, color mgl32.Vec4) {
+	if divisions < 1 {
+		divisions = 1
+	}
+	c, expires := packDebugColor(color), d.expiry()
+	half := size / 2
+	for i := 0; i <= divisions; i++ {
+		t := -half + size*float32(i)/float32(divisions)
+		d.addLine(center.Add(mgl32.Vec3{t, 0, -half}), center.Add(mgl32.Vec3{t, 0, half}), c, expires)
+		d.addLine(center.Add(mgl32.Vec3{-half, 0, t}), center.Add(mgl32.Vec3{half, 0, t}), c, expires)
+	}
+}
+
+// Text3D adds single line of text centered at position, facing camera.
+// Letters are drawn by strokes of height, lowercase ones as uppercase.
+func (d *DebugDraw) Text3D(position mgl32.Vec3, text string, height float32, color mgl32.Vec4) {
+	d.texts = append(d.texts, debugText{
+		position: position,
+		text:     text,
+		height:   height,
+		color:    packDebugColor(color),
+		expires:  d.expiry(),
+		onTop:    d.OnTop,
+	})
+}
+
+// Clear removes all primitives, including persistent ones.
+func (d *DebugDraw) Clear() {
+	for i := range d.lines {
+		d.lines[i].vertices = d.lines[i].vertices[:0]
+		d.lines[i].expires = d.lines[i].expires[:0]
+	}
+	d.texts = d.texts[:0]
+}
+
+// Draw renders primitives viewed by camera into bound framebuffer and
+// flushes single-frame and expired ones, so it should be called once per
+// frame after the scene is drawn.
+func (d *DebugDraw) Draw(camera Camera) {
+	view := camera.View()
+	right := mgl32.Vec3{view.At(0, 0), view.At(0, 1), view.At(0, 2)}
+	up := mgl32.Vec3{view.At(1, 0), view.At(1, 1), view.At(1, 2)}
+
+	// Depth tested lines go first, then lines on top
+	d.vertices = d.vertices[:0]
+	var counts [2]int32
+	for i := range d.lines {
+		start := len(d.vertices)
+		d.vertices = append(d.vertices, d.lines[i].vertices...)
+		for _, t := range d.texts {
+			if t.onTop == (i == 1) {
+				d.vertices = appendDebugText(d.vertices, t, right, up)
+			}
+		}
+		counts[i] = int32(len(d.vertices) - start)
+	}
+	d.flush()
+	if len(d.vertices) == 0 {
+		return
+	}
+	d.buffer.UploadPointer(unsafe.Pointer(&d.vertices[0]), len(d.vertices)*int(unsafe.Sizeof(debugVertex{})))
+
+	lastEnableDepthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	lastEnableBlend := gl.IsEnabled(gl.BLEND)
+	lastBlend := saveBlend()
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	gl.Enable(gl.BLEND)
+	gl.BlendEquation(gl.FUNC_ADD)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+
+	viewProjection := camera.Projection().Mul4(view)
+	gl.UseProgram(d.program)
+	gl.UniformMatrix4fv(d.location, 1, false, &viewProjection[0])
+	gl.BindVertexArray(d.vao)
+	if counts[0] > 0 {
+		gl.Enable(gl.DEPTH_TEST)
+		gl.DrawArrays(gl.LINES, 0, counts[0])
+	}
+	if counts[1] > 0 {
+		gl.Disable(gl.DEPTH_TEST)
+		gl.DrawArrays(gl.LINES, counts[0], counts[1])
+	}
+	gl.BindVertexArray(0)
+	forgetBindings()
+
+	gl.DepthMask(lastDepthMask)
+	setEnabled(gl.DEPTH_TEST, lastEnableDepthTest)
+	setEnabled(gl.BLEND, lastEnableBlend)
+	lastBlend.restore()
+}
+
+// Dispose cleans up the resources.
+func (d *DebugDraw) Dispose() {
+	if d.vao != 0 {
+		gl.DeleteVertexArrays(1, &d.vao)
+		d.vao = 0
+	}
+	if d.program != 0 {
+		gl.DeleteProgram(d.program)
+		d.program = 0
+	}
+	d.buffer.Dispose()
+}
+
+// Get expiry of primitives added now, zero time for a single frame
+func (d *DebugDraw) expiry() time.Time {
+	if d.Duration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d.Duration)
+}
+
+// Remove primitives which shouldn't be drawn in the next frame
+func (d *DebugDraw) flush() {
+	now := time.Now()
+	for i := range d.lines {
+		l := &d.lines[i]
+		kept := 0
+		for j, expires := range l.expires {
+			if expires.After(now) {
+				l.vertices[kept*2], l.vertices[kept*2+1] = l.vertices[j*2], l.vertices[j*2+1]
+				l.expires[kept] = expires
+				kept++
+			}
+		}
+		l.vertices, l.expires = l.vertices[:kept*2], l.expires[:kept]
+	}
+	kept := d.texts[:0]
+	for _, t := range d.texts {
+		if t.expires.After(now) {
+			kept = append(kept, t)
+		}
+	}
+	d.texts = kept
+}
+
+func (d *DebugDraw) addLine(from, to mgl32.Vec3, color [4]uint8, expires time.Time) {
+	l := &d.lines[0]
+	if d.OnTop {
+		l = &d.lines[1]
+	}
+	l.vertices = append(l.vertices, debugVertex{from, color}, debugVertex{to, color})
+	l.expires = append(l.expires, expires)
+}
+
+// Add edges between corners indexed like AABB.Corners
+func (d *DebugDraw) addBox(corners [8]mgl32.Vec3, color [4]uint8, expires time.Time) {
+	for i := range corners {
+		for j := 0; j < 3; j++ {
+			if i&(1<<j) == 0 {
+				d.addLine(corners[i], corners[i|1<<j], color, expires)
+			}
+		}
+	}
+}
+
+// Add circle around center spanned by perpendicular radius vectors
+func (d *DebugDraw) addCircle(center, u, v mgl32.Vec3, color [4]uint8, expires time.Time) {
+	previous := center.Add(u)
+	for i := 1; i <= debugCircleSegments; i++ {
+		angle := float64(i) * 2 * math.Pi / debugCircleSegments
+		p := center.Add(u.Mul(float32(math.Cos(angle)))).Add(v.Mul(float32(math.Sin(angle))))
+		d.addLine(previous, p, color, expires)
+		previous = p
+	}
+}
+
+func packDebugColor(color mgl32.Vec4) [4]uint8 {
+	var c [4]uint8
+	for i := range c {
+		c[i] = uint8(mgl32.Clamp(color[i], 0, 1)*255 + 0.5)
+	}
+	return c
+}
@@ -0,0 +1,105 @@
+package gfx
+
+import (
+	"unicode"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// Glyphs are drawn on grid of this size, y grows upwards
+	debugGlyphWidth  = 4
+	debugGlyphHeight = 6
+
+	// Distance between origins of successive glyphs
+	debugGlyphAdvance = debugGlyphWidth + 2
+)
+
+// Strokes of glyphs of debug text, each group of 4 digits is a line
+// x1 y1 x2 y2 on the glyph grid. Unknown characters are drawn as '?'.
+var debugGlyphs = map[rune]string{
+	'0':  "0040 4046 4606 0600 0046",
+	'1':  "2026 2615 1030",
+	'2':  "0646 4643 4303 0300 0040",
+	'3':  "0646 4640 4000 1343",
+	'4':  "0603 0343 4640",
+	'5':  "4606 0604 0434 3443 4341 4130 3000",
+	'6':  "4606 0600 0040 4043 4303",
+	'7':  "0646 4620",
+	'8':  "0040 4046 4606 0600 0343",
+	'9':  "4303 0306 0646 4640 4000",
+	'A':  "0026 2640 1333",
+	'B':  "0006 0636 3645 4544 4433 0333 3342 4241 4130 3000",
+	'C':  "4606 0600 0040",
+	'D':  "0006 0636 3645 4541 4130 3000",
+	'E':  "4606 0600 0040 0333",
+	'F':  "4606 0600 0333",
+	'G':  "4606 0600 0040 4043 4323",
+	'H':  "0006 4046 0343",
+	'I':  "0646 0040 2026",
+	'J':  "0646 3631 3120 2010 1001",
+	'K':  "0006 0346 0340",
+	'L':  "0600 0040",
+	'M':  "0006 0623 2346 4640",
+	'N':  "0006 0640 4046",
+	'O':  "0040 4046 4606 0600",
+	'P':  "0006 0646 4643 4303",
+	'Q':  "0040 4046 4606 0600 2240",
+	'R':  "0006 0646 4643 4303 2340",
+	'S':  "4606 0603 0343 4340 4000",
+	'T':  "0646 2620",
+	'U':  "0600 0040 4046",
+	'V':  "0620 2046",
+	'W':  "0610 1023 2330 3046",
+	'X':  "0046 0640",
+	'Y':  "0623 2346 2320",
+	'Z':  "0646 4600 0040",
+	' ':  "",
+	'.':  "2021",
+	',':  "2110",
+	':':  "2122 2425",
+	';':  "2425 2110",
+	'-':  "1333",
+	'+':  "1333 2224",
+	'_':  "0040",
+	'=':  "1232 1434",
+	'/':  "0046",
+	'\\': "0640",
+	'(':  "3625 2521 2130",
+	')':  "1625 2521 2110",
+	'[':  "3626 2620 2030",
+	']':  "1626 2620 2010",
+	'<':  "3603 0330",
+	'>':  "1643 4310",
+	'!':  "2622 2021",
+	'?':  "0646 4643 4323 2322 2021",
+	'*':  "1335 1533 0343",
+	'#':  "1016 3036 0242 0444",
+	'%':  "0046 0616 3040",
+	'\'': "2625",
+	'"':  "1615 3635",
+}
+
+// Append lines of text spanned by right and up directions of camera
+func appendDebugText(vertices []debugVertex, t debugText, right, up mgl32.Vec3) []debugVertex {
+	scale := t.height / debugGlyphHeight
+	runes := []rune(t.text)
+	width := float32(len(runes)*debugGlyphAdvance-(debugGlyphAdvance-debugGlyphWidth)) * scale
+	origin := t.position.Sub(right.Mul(width / 2)).Sub(up.Mul(t.height / 2))
+	point := func(x, y byte) mgl32.Vec3 {
+		return origin.Add(right.Mul(float32(x-'0') * scale)).Add(up.Mul(float32(y-'0') * scale))
+	}
+	for _, r := range runes {
+		strokes, ok := debugGlyphs[unicode.ToUpper(r)]
+		if !ok {
+			strokes = debugGlyphs['?']
+		}
+		for i := 0; i+4 <= len(strokes); i += 5 {
+			vertices = append(vertices,
+				debugVertex{point(strokes[i], strokes[i+1]), t.color},
+				debugVertex{point(strokes[i+2], strokes[i+3]), t.color})
+		}
+		origin = origin.Add(right.Mul(debugGlyphAdvance * scale))
+	}
+	return vertices
+}
@@ -0,0 +1,278 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+//go:embed shaders/deferred/light.vert
+var deferredLightVertexShader string
+
+//go:embed shaders/deferred/light.frag
+var deferredLightFragmentShader string
+
+// Attachments of G-buffer, must match outputs of shading.glsl
+const (
+	GBufferAlbedo   = iota // albedo, occlusion, sRGB encoded against banding
+	GBufferNormal          // world normal, shading model
+	GBufferMaterial        // specular color and shininess, or metallic and roughness
+	GBufferEmissive        // emitted light
+)
+
+// Light volumes are icospheres, which are scaled to enclose their sphere
+const lightVolumeScale = 1.1
+
+// Statistics of last frame of deferred renderer
+type DeferredStats struct {
+	Opaque       int // meshes drawn into G-buffer
+	Transparent  int // meshes drawn forward
+	LightVolumes int // lights shaded by their volumes
+}
+
+// DeferredRenderer draws scene in deferred path: opaque meshes write their
+// surfaces into G-buffer, which is shaded by a full-screen pass of indirect
+// light and directional lights, and by volumes of point and spot lights
+// limited by range. Transparent meshes are then shaded forward. Materials
+// should be created by lighting whose Path is RenderDeferred.
+type DeferredRenderer struct {
+	lighting *Lighting
+	gbuffer  *Framebuffer
+	program  uint32
+	volume   *Mesh
+	vao      uint32 // empty, for full-screen triangle
+	stats    DeferredStats
+
+	fullScreen            int32
+	volumeMatrix          int32
+	lightIndex            int32
+	inverseViewProjection int32
+	camera                int32
+	cameraPosition        int32
+}
+
+// NewDeferredRenderer creates renderer with G-buffer of size, which should
+// match drawable size of window.
+func NewDeferredRenderer(lighting *Lighting, width, height int32) (*DeferredRenderer, error) {
+	if lighting.Path != RenderDeferred {
+		return nil, fmt.Errorf("lighting isn't set up for deferred path")
+	}
+	r := &DeferredRenderer{lighting: lighting}
+	var err error
+	r.gbuffer, err = NewFramebuffer(width, height, FramebufferSpec{
+		Colors:       []uint32{gl.SRGB8_ALPHA8, gl.RGBA16F, gl.RGBA16F, gl.R11F_G11F_B10F},
+		Depth:        gl.DEPTH24_STENCIL8,
+		DepthTexture: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.program, err = LoadShaders([]Shader{
+		{Source: "#version 430 core\n" + deferredLightVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: lighting.shaderHeader() + "#define DEFERRED_LIGHTING\n" + lightsShader + shadingShader +
+			deferredLightFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		r.Dispose()
+		return nil, err
+	}
+	r.volume, err = GenIcosphere(1, 1).Upload(r.program)
+	if err != nil {
+		r.Dispose()
+		return nil, err
+	}
+	gl.GenVertexArrays(1, &r.vao)
+
+	location := func(name string) int32 {
+		return gl.GetUniformLocation(r.program, gl.Str(name+"\x00"))
+	}
+	r.fullScreen = location("fullScreen")
+	r.volumeMatrix = location("volumeMatrix")
+	r.lightIndex = location("lightIndex")
+	r.inverseViewProjection = location("inverseViewProjection")
+	r.camera = location(UniformView)
+	r.cameraPosition = location(UniformCameraPosition)
+	return r, nil
+}
+
+// GBuffer returns framebuffer holding surfaces of the last frame, whose
+// color attachments are indexed by GBufferAlbedo etc.
+func (r *DeferredRenderer) GBuffer() *Framebuffer {
+	return r.gbuffer
+}
+
+// Stats returns statistics of the last frame.
+func (r *DeferredRenderer) Stats() DeferredStats {
+	return r.stats
+}
+
+// Resize resizes G-buffer.
+func (r *DeferredRenderer) Resize(width, height int32) error {
+	return r.gbuffer.Resize(width, height)
+}
+
+// ProcessEvent resizes G-buffer to drawable size of window when it
+// changes size.
+func (r *DeferredRenderer) ProcessEvent(event sdl.Event) error {
+	return r.gbuffer.ProcessEvent(event)
+}
+
+// Draw renders objects inside view of camera into target, which must be
+// single-sampled, of the same size as G-buffer and have DEPTH24_STENCIL8
+// depth, which receives depth of opaque meshes. Its color attachments
+// receive outputs of shading.glsl: light, normals and indirect light.
+// Lighting must be updated and shadow maps rendered beforehand. Target is
+// left bound.
+func (r *DeferredRenderer) Draw(objects *BVH, camera Camera, target *Framebuffer) error {
+	width, height := r.gbuffer.Size()
+	if targetWidth, targetHeight := target.Size(); targetWidth != width || targetHeight != height {
+		return fmt.Errorf("target size %dx%d differs from G-buffer size %dx%d", targetWidth, targetHeight, width, height)
+	}
+	if target.Samples() > 1 {
+		return fmt.Errorf("target of deferred renderer can't be multisampled")
+	}
+
+	projection, view := camera.Projection(), camera.View()
+	viewProjection := projection.Mul4(view)
+	frustum := NewFrustum(viewProjection)
+	var opaque, transparent []*Node
+	objects.Query(frustum, func(node *Node) {
+		if node.Material == nil {
+			return
+		}
+		if node.Material.Transparent {
+			transparent = append(transparent, node)
+		} else {
+			opaque = append(opaque, node)
+		}
+	})
+	r.stats = DeferredStats{Opaque: len(opaque), Transparent: len(transparent)}
+
+	// Surfaces of opaque meshes
+	lastEnableDepthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	lastEnableBlend := gl.IsEnabled(gl.BLEND)
+	lastEnableCullFace := gl.IsEnabled(gl.CULL_FACE)
+	lastEnableFramebufferSRGB := gl.IsEnabled(gl.FRAMEBUFFER_SRGB)
+	lastBlend := saveBlend()
+	var lastDepthFunc int32
+	gl.GetIntegerv(gl.DEPTH_FUNC, &lastDepthFunc)
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	r.gbuffer.Bind()
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.FRAMEBUFFER_SRGB) // albedo is encoded when written
+	var zero [4]float32
+	for i := int32(0); i <= GBufferEmissive; i++ {
+		gl.ClearBufferfv(gl.COLOR, i, &zero[0])
+	}
+	gl.ClearBufferfi(gl.DEPTH_STENCIL, 0, 1, 0)
+	drawNodes(opaque, projection, view)
+	setEnabled(gl.FRAMEBUFFER_SRGB, lastEnableFramebufferSRGB)
+
+	// Depth is shared with forward passes drawn into target
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, r.gbuffer.ID())
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, target.ID())
+	gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, gl.DEPTH_BUFFER_BIT|gl.STENCIL_BUFFER_BIT, gl.NEAREST)
+	target.Bind()
+
+	// Lights are added up
+	gl.UseProgram(r.program)
+	inverse := viewProjection.Inv()
+	eye := view.Inv().Col(3).Vec3()
+	gl.UniformMatrix4fv(r.inverseViewProjection, 1, false, &inverse[0])
+	gl.UniformMatrix4fv(r.camera, 1, false, &view[0])
+	gl.Uniform3fv(r.cameraPosition, 1, &eye[0])
+	textures := []uint32{
+		r.gbuffer.ColorTexture(GBufferAlbedo),
+		r.gbuffer.ColorTexture(GBufferNormal),
+		r.gbuffer.ColorTexture(GBufferMaterial),
+		r.gbuffer.ColorTexture(GBufferEmissive),
+		r.gbuffer.DepthTexture(),
+	}
+	for i, texture := range textures {
+		gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
+		gl.BindTexture(gl.TEXTURE_2D, texture)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.Enable(gl.BLEND)
+	gl.BlendEquation(gl.FUNC_ADD)
+	gl.BlendFunc(gl.ONE, gl.ONE)
+	gl.DepthMask(false)
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Uniform1i(r.fullScreen, 1)
+	gl.Uniform1i(r.lightIndex, -1)
+	gl.BindVertexArray(r.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+
+	// Back faces of volumes in front of surfaces cover lit pixels, also
+	// when camera is inside, far faces are clamped instead of clipped
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.GEQUAL)
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.FRONT)
+	gl.Enable(gl.DEPTH_CLAMP)
+	gl.Uniform1i(r.fullScreen, 0)
+	lights := r.lighting.Lights
+	if len(lights) > r.lighting.maxLights {
+		lights = lights[:r.lighting.maxLights]
+	}
+	for i, light := range lights {
+		sphere, ok := lightVolume(light)
+		if !ok || !frustum.IntersectsSphere(sphere) {
+			continue
+		}
+		radius := sphere.Radius * lightVolumeScale
+		volumeMatrix := viewProjection.
+			Mul4(mgl32.Translate3D(sphere.Center[0], sphere.Center[1], sphere.Center[2])).
+			Mul4(mgl32.Scale3D(radius, radius, radius))
+		gl.UniformMatrix4fv(r.volumeMatrix, 1, false, &volumeMatrix[0])
+		gl.Uniform1i(r.lightIndex, int32(i))
+		r.volume.Draw()
+		r.stats.LightVolumes++
+	}
+	gl.BindVertexArray(0)
+	gl.Disable(gl.DEPTH_CLAMP)
+	gl.CullFace(gl.BACK)
+	setEnabled(gl.CULL_FACE, lastEnableCullFace)
+	gl.DepthFunc(uint32(lastDepthFunc))
+	gl.DepthMask(lastDepthMask)
+	setEnabled(gl.DEPTH_TEST, lastEnableDepthTest)
+	setEnabled(gl.BLEND, lastEnableBlend)
+	lastBlend.restore()
+	forgetBindings()
+
+	drawTransparent(transparent, projection, view)
+	return nil
+}
+
+// Dispose cleans up the resources.
+func (r *DeferredRenderer) Dispose() {
+	if r.volume != nil {
+		r.volume.Dispose()
+		r.volume = nil
+	}
+	if r.vao != 0 {
+		gl.DeleteVertexArrays(1, &r.vao)
+		r.vao = 0
+	}
+	if r.program != 0 {
+		gl.DeleteProgram(r.program)
+		r.program = 0
+	}
+	r.gbuffer.Dispose()
+}
+
+// Get sphere lit by light, which is shaded by its own volume, must match
+// hasVolume of deferred lighting shader
+func lightVolume(light Light) (Sphere, bool) {
+	if light.Type == LightDirectional || light.Range <= 0 {
+		return Sphere{}, false
+	}
+	return Sphere{Center: light.Position, Radius: light.Range}, true
+}
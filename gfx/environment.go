@@ -0,0 +1,631 @@
+package gfx
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/env/cube.vert
+var cubeVertexShader string
+
+//go:embed shaders/env/common.glsl
+var environmentCommonShader string
+
+//go:embed shaders/env/equirect.frag
+var equirectFragmentShader string
+
+//go:embed shaders/env/skybox.frag
+var skyboxFragmentShader string
+
+//go:embed shaders/env/irradiance.frag
+var irradianceFragmentShader string
+
+//go:embed shaders/env/prefilter.frag
+var prefilterFragmentShader string
+
+//go:embed shaders/env/brdf.frag
+var brdfFragmentShader string
+
+// First of three texture units where environment maps are bound for lit
+// programs: irradiance, prefiltered specular and BRDF lookup table
+const EnvironmentTextureUnit = 12
+
+const (
+	environmentCubeSize   = 512
+	irradianceSize        = 32
+	prefilterSize         = 128
+	prefilterLevels       = 5
+	brdfSize              = 512
+	environmentCacheMagic = "GLENV1"
+)
+
+// Environment is image of surroundings drawn as skybox and lighting lit
+// programs: diffuse light comes from irradiance map, specular reflections
+// from prefiltered map (blurrier mips for rougher surfaces) combined with
+// BRDF lookup table. Convolved maps are cached on disk.
+type Environment struct {
+	Intensity  float32 // scale of environment lighting and sky
+	SkyboxBlur float32 // mip level of sky, 0 for sharp
+
+	cubemap     uint32 // HDR environment with mipmaps
+	irradiance  uint32
+	prefiltered uint32
+	brdf        uint32
+	skybox      uint32 // program
+	vao         uint32
+}
+
+// LoadEnvironment creates environment from equirectangular image, Radiance
+// HDR (.hdr) or any registered image format holding sRGB colors. Convolved
+// maps are cached in cacheDir, "" disables caching.
+func LoadEnvironment(file, cacheDir string) (*Environment, error) {
+	var equirect uint32
+	var err error
+	if strings.EqualFold(filepath.Ext(file), ".hdr") {
+		var img *HDRImage
+		if img, err = LoadHDR(file); err == nil {
+			equirect = uploadHDRImage(img)
+		}
+	} else {
+		equirect, err = LoadTextureSRGB(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer gl.DeleteTextures(1, &equirect)
+
+	cubemap, err := EquirectToCubemap(equirect, environmentCubeSize)
+	if err != nil {
+		return nil, err
+	}
+	key := ""
+	if info, err := os.Stat(file); err == nil && cacheDir != "" {
+		abs, _ := filepath.Abs(file)
+		key = fmt.Sprintf("%s:%d:%d", abs, info.Size(), info.ModTime().UnixNano())
+	}
+	return NewEnvironment(cubemap, cacheDir, key)
+}
+
+// LoadCubemap loads cubemap from six square images of the same size, in
+// order +X, -X, +Y, -Y, +Z, -Z, e.g. to be passed to NewEnvironment. Faces
+// are either all Radiance HDR (.hdr) or all in registered image formats
+// holding sRGB colors, rows run from top to bottom.
+func LoadCubemap(files [6]string) (uint32, error) {
+	var cubemap uint32
+	gl.GenTextures(1, &cubemap)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, cubemap)
+	fail := func(err error) (uint32, error) {
+		gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+		gl.DeleteTextures(1, &cubemap)
+		return 0, err
+	}
+
+	size := 0
+	checkSize := func(file string, width, height int) error {
+		if size == 0 {
+			size = width
+		}
+		if width != height || width != size || size == 0 {
+			return fmt.Errorf("cubemap face %q is %dx%d, expected square of size %d", file, width, height, size)
+		}
+		return nil
+	}
+	hdr := strings.EqualFold(filepath.Ext(files[0]), ".hdr")
+	for i, file := range files {
+		if strings.EqualFold(filepath.Ext(file), ".hdr") != hdr {
+			return fail(fmt.Errorf("cubemap faces mix HDR and other images"))
+		}
+		target := gl.TEXTURE_CUBE_MAP_POSITIVE_X + uint32(i)
+		if hdr {
+			img, err := LoadHDR(file)
+			if err != nil {
+				return fail(err)
+			}
+			if err := checkSize(file, img.Width, img.Height); err != nil {
+				return fail(err)
+			}
+			gl.TexImage2D(target, 0, gl.RGB16F, int32(size), int32(size), 0, gl.RGB, gl.FLOAT, gl.Ptr(img.Pix))
+			continue
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return fail(fmt.Errorf("texture %q not found on disk: %v", file, err))
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return fail(fmt.Errorf("load texture %q failed: %v", file, err))
+		}
+		if err := checkSize(file, img.Bounds().Dx(), img.Bounds().Dy()); err != nil {
+			return fail(err)
+		}
+		rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		gl.TexImage2D(target, 0, gl.SRGB8_ALPHA8, int32(size), int32(size), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
+	}
+
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+	return cubemap, nil
+}
+
+// NewEnvironment creates environment from HDR cubemap, which is owned by
+// environment and deleted when it fails. Convolved maps are cached in
+// cacheDir under cacheKey, which should identify content of cubemap,
+// caching is disabled when either is empty. Caching is best effort,
+// failures only cause recomputation.
+func NewEnvironment(cubemap uint32, cacheDir, cacheKey string) (*Environment, error) {
+	// Filtering across faces avoids seams, especially in blurry mips
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+	e := &Environment{Intensity: 1, cubemap: cubemap}
+	gl.GenVertexArrays(1, &e.vao)
+
+	var err error
+	e.skybox, err = LoadShaders([]Shader{
+		{Source: "#version 330 core\n#define SKYBOX\n" + cubeVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + skyboxFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		e.Dispose()
+		return nil, err
+	}
+
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, cubemap)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+	e.irradiance = newCubemap(irradianceSize, 1)
+	e.prefiltered = newCubemap(prefilterSize, prefilterLevels)
+	e.brdf = newBRDFTexture()
+	forgetBindings()
+
+	maps := []cachedTexture{
+		{gl.TEXTURE_CUBE_MAP, e.irradiance, irradianceSize, 1, gl.RGBA},
+		{gl.TEXTURE_CUBE_MAP, e.prefiltered, prefilterSize, prefilterLevels, gl.RGBA},
+	}
+	mapsFile, brdfFile := "", ""
+	if cacheDir != "" {
+		brdfFile = filepath.Join(cacheDir, fmt.Sprintf("brdf-%d.cache", brdfSize))
+		if cacheKey != "" {
+			hash := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d|%d", cacheKey, environmentCubeSize, irradianceSize, prefilterSize)))
+			mapsFile = filepath.Join(cacheDir, "env-"+hex.EncodeToString(hash[:])+".cache")
+		}
+	}
+
+	brdfMap := []cachedTexture{{gl.TEXTURE_2D, e.brdf, brdfSize, 1, gl.RG}}
+	if brdfFile == "" || loadTextureCache(brdfFile, brdfMap) != nil {
+		if err := e.renderBRDF(); err != nil {
+			e.Dispose()
+			return nil, err
+		}
+		if brdfFile != "" {
+			saveTextureCache(brdfFile, brdfMap)
+		}
+	}
+	if mapsFile == "" || loadTextureCache(mapsFile, maps) != nil {
+		if err := e.convolve(); err != nil {
+			e.Dispose()
+			return nil, err
+		}
+		if mapsFile != "" {
+			saveTextureCache(mapsFile, maps)
+		}
+	}
+	return e, nil
+}
+
+// Cubemap returns HDR environment cubemap.
+func (e *Environment) Cubemap() uint32 {
+	return e.cubemap
+}
+
+// IrradianceMap returns cubemap of diffuse lighting.
+func (e *Environment) IrradianceMap() uint32 {
+	return e.irradiance
+}
+
+// PrefilteredMap returns cubemap of specular lighting, whose mips match
+// increasing roughness.
+func (e *Environment) PrefilteredMap() uint32 {
+	return e.prefiltered
+}
+
+// BRDFTexture returns lookup table of scale and bias of F0, indexed by
+// NdotV and roughness.
+func (e *Environment) BRDFTexture() uint32 {
+	return e.brdf
+}
+
+// MaxLod returns mip level of prefiltered map matching roughness 1.
+func (e *Environment) MaxLod() float32 {
+	return prefilterLevels - 1
+}
+
+// Bind binds environment maps to texture units of lit programs.
+func (e *Environment) Bind() {
+	textures := []struct {
+		target, texture uint32
+	}{
+		{gl.TEXTURE_CUBE_MAP, e.irradiance},
+		{gl.TEXTURE_CUBE_MAP, e.prefiltered},
+		{gl.TEXTURE_2D, e.brdf},
+	}
+	for i, t := range textures {
+		gl.ActiveTexture(gl.TEXTURE0 + EnvironmentTextureUnit + uint32(i))
+		gl.BindTexture(t.target, t.texture)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// DrawSkybox draws environment behind the scene viewed by camera, it
+// should be called after opaque meshes so hidden sky isn't shaded.
+func (e *Environment) DrawSkybox(camera Camera) {
+	view := camera.View().Mat3().Mat4()
+	viewProjection := camera.Projection().Mul4(view)
+
+	var lastDepthFunc int32
+	gl.GetIntegerv(gl.DEPTH_FUNC, &lastDepthFunc)
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	lastEnableCullFace := gl.IsEnabled(gl.CULL_FACE)
+	gl.DepthFunc(gl.LEQUAL)
+	gl.DepthMask(false)
+	gl.Disable(gl.CULL_FACE)
+
+	gl.UseProgram(e.skybox)
+	gl.UniformMatrix4fv(gl.GetUniformLocation(e.skybox, gl.Str("viewProjection\x00")), 1, false, &viewProjection[0])
+	gl.Uniform1f(gl.GetUniformLocation(e.skybox, gl.Str("lod\x00")), e.SkyboxBlur)
+	gl.Uniform1f(gl.GetUniformLocation(e.skybox, gl.Str("intensity\x00")), e.Intensity)
+	gl.Uniform1i(gl.GetUniformLocation(e.skybox, gl.Str("environmentMap\x00")), 0)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, e.cubemap)
+	gl.BindVertexArray(e.vao)
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 14)
+	gl.BindVertexArray(0)
+	forgetBindings()
+
+	gl.DepthFunc(uint32(lastDepthFunc))
+	gl.DepthMask(lastDepthMask)
+	setEnabled(gl.CULL_FACE, lastEnableCullFace)
+}
+
+// Dispose cleans up the resources.
+func (e *Environment) Dispose() {
+	for _, t := range []*uint32{&e.cubemap, &e.irradiance, &e.prefiltered, &e.brdf} {
+		if *t != 0 {
+			gl.DeleteTextures(1, t)
+		}
+		*t = 0
+	}
+	if e.skybox != 0 {
+		gl.DeleteProgram(e.skybox)
+	}
+	e.skybox = 0
+	if e.vao != 0 {
+		gl.DeleteVertexArrays(1, &e.vao)
+	}
+	e.vao = 0
+}
+
+// Render irradiance and prefiltered maps from environment cubemap
+func (e *Environment) convolve() error {
+	irradiance, err := newCubeProgram(irradianceFragmentShader)
+	if err != nil {
+		return err
+	}
+	defer gl.DeleteProgram(irradiance)
+	prefilter, err := newCubeProgram(prefilterFragmentShader)
+	if err != nil {
+		return err
+	}
+	defer gl.DeleteProgram(prefilter)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, e.cubemap)
+	err = renderCubemap(irradiance, e.irradiance, irradianceSize, 0, e.vao)
+	if err != nil {
+		return err
+	}
+	for level := int32(0); level < prefilterLevels; level++ {
+		gl.UseProgram(prefilter)
+		gl.Uniform1f(gl.GetUniformLocation(prefilter, gl.Str("environmentSize\x00")), environmentCubeSize)
+		roughness := float32(level) / float32(prefilterLevels-1)
+		gl.Uniform1f(gl.GetUniformLocation(prefilter, gl.Str("roughness\x00")), roughness)
+		if err := renderCubemap(prefilter, e.prefiltered, prefilterSize>>level, level, e.vao); err != nil {
+			return err
+		}
+	}
+	forgetBindings()
+	return nil
+}
+
+// Render BRDF lookup table
+func (e *Environment) renderBRDF() error {
+	program, err := LoadShaders([]Shader{
+		{Source: "#version 330 core\n" + postVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + environmentCommonShader + brdfFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		return err
+	}
+	defer gl.DeleteProgram(program)
+
+	return renderOffscreen(func() error {
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, e.brdf, 0)
+		if err := checkFramebuffer(gl.FRAMEBUFFER); err != nil {
+			return err
+		}
+		gl.Viewport(0, 0, brdfSize, brdfSize)
+		gl.UseProgram(program)
+		gl.BindVertexArray(e.vao)
+		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+		return nil
+	})
+}
+
+// EquirectToCubemap renders equirectangular texture into new HDR cubemap
+// with faces of size.
+func EquirectToCubemap(equirect uint32, size int32) (uint32, error) {
+	program, err := newCubeProgram(equirectFragmentShader)
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteProgram(program)
+	var vao uint32
+	gl.GenVertexArrays(1, &vao)
+	defer gl.DeleteVertexArrays(1, &vao)
+
+	levels := int32(math.Log2(float64(size))) + 1
+	cubemap := newCubemap(size, levels)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, equirect)
+	err = renderCubemap(program, cubemap, size, 0, vao)
+	forgetBindings()
+	if err != nil {
+		gl.DeleteTextures(1, &cubemap)
+		return 0, err
+	}
+	return cubemap, nil
+}
+
+// Compile program drawing cube with fragment shader
+func newCubeProgram(fragment string) (uint32, error) {
+	return LoadShaders([]Shader{
+		{Source: "#version 330 core\n" + cubeVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + environmentCommonShader + fragment + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+}
+
+// Create RGBA16F cubemap with faces of size
+func newCubemap(size, levels int32) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, texture)
+	gl.TexStorage2D(gl.TEXTURE_CUBE_MAP, levels, gl.RGBA16F, size, size)
+	minFilter := int32(gl.LINEAR)
+	if levels > 1 {
+		minFilter = gl.LINEAR_MIPMAP_LINEAR
+	}
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, minFilter)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+	return texture
+}
+
+func newBRDFTexture() uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexStorage2D(gl.TEXTURE_2D, 1, gl.RG16F, brdfSize, brdfSize)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return texture
+}
+
+// Upload HDR image as RGB16F texture
+func uploadHDRImage(img *HDRImage) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGB16F, int32(img.Width), int32(img.Height), 0, gl.RGB, gl.FLOAT, gl.Ptr(img.Pix))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return texture
+}
+
+// Draw cube with program into each face of level of cubemap, with
+// camera at center of cube
+func renderCubemap(program, cubemap uint32, size, level int32, vao uint32) error {
+	projection := mgl32.Perspective(mgl32.DegToRad(90), 1, 0.1, 10)
+	faces := [6][2]mgl32.Vec3{
+		{{1, 0, 0}, {0, -1, 0}},
+		{{-1, 0, 0}, {0, -1, 0}},
+		{{0, 1, 0}, {0, 0, 1}},
+		{{0, -1, 0}, {0, 0, -1}},
+		{{0, 0, 1}, {0, -1, 0}},
+		{{0, 0, -1}, {0, -1, 0}},
+	}
+	return renderOffscreen(func() error {
+		gl.Viewport(0, 0, size, size)
+		gl.UseProgram(program)
+		location := gl.GetUniformLocation(program, gl.Str("viewProjection\x00"))
+		gl.BindVertexArray(vao)
+		for i, face := range faces {
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0,
+				gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), cubemap, level)
+			if err := checkFramebuffer(gl.FRAMEBUFFER); err != nil {
+				return err
+			}
+			viewProjection := projection.Mul4(mgl32.LookAtV(mgl32.Vec3{}, face[0], face[1]))
+			gl.UniformMatrix4fv(location, 1, false, &viewProjection[0])
+			gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 14)
+		}
+		return nil
+	})
+}
+
+// Run draw with temporary framebuffer bound, restoring state afterwards
+func renderOffscreen(draw func() error) error {
+	var lastFramebuffer, lastVertexArray, lastProgram int32
+	var lastViewport [4]int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastFramebuffer)
+	gl.GetIntegerv(gl.VERTEX_ARRAY_BINDING, &lastVertexArray)
+	gl.GetIntegerv(gl.CURRENT_PROGRAM, &lastProgram)
+	gl.GetIntegerv(gl.VIEWPORT, &lastViewport[0])
+	lastEnableDepthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	lastEnableCullFace := gl.IsEnabled(gl.CULL_FACE)
+	lastEnableBlend := gl.IsEnabled(gl.BLEND)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.CULL_FACE)
+	gl.Disable(gl.BLEND)
+
+	var fbo uint32
+	gl.GenFramebuffers(1, &fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	err := draw()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(lastFramebuffer))
+	gl.DeleteFramebuffers(1, &fbo)
+
+	gl.BindVertexArray(uint32(lastVertexArray))
+	gl.UseProgram(uint32(lastProgram))
+	gl.Viewport(lastViewport[0], lastViewport[1], lastViewport[2], lastViewport[3])
+	setEnabled(gl.DEPTH_TEST, lastEnableDepthTest)
+	setEnabled(gl.CULL_FACE, lastEnableCullFace)
+	setEnabled(gl.BLEND, lastEnableBlend)
+	return err
+}
+
+// Texture stored in cache file, as float32 components of each level and
+// face in order
+type cachedTexture struct {
+	target  uint32 // gl.TEXTURE_2D or gl.TEXTURE_CUBE_MAP
+	texture uint32
+	size    int32
+	levels  int32
+	format  uint32 // gl.RGBA or gl.RG
+}
+
+// Call fn with each 2d image of textures and number of its floats
+func forEachCachedImage(textures []cachedTexture, fn func(t cachedTexture, target uint32, level, size int32, n int) error) error {
+	for _, t := range textures {
+		components := 4
+		if t.format == gl.RG {
+			components = 2
+		}
+		faces := []uint32{gl.TEXTURE_2D}
+		if t.target == gl.TEXTURE_CUBE_MAP {
+			faces = nil
+			for i := uint32(0); i < 6; i++ {
+				faces = append(faces, gl.TEXTURE_CUBE_MAP_POSITIVE_X+i)
+			}
+		}
+		for level := int32(0); level < t.levels; level++ {
+			size := t.size >> level
+			for _, face := range faces {
+				if err := fn(t, face, level, size, int(size*size)*components); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Fill textures from cache file
+func loadTextureCache(file string, textures []cachedTexture) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(environmentCacheMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != environmentCacheMagic {
+		return fmt.Errorf("%q isn't environment cache", file)
+	}
+
+	// Whole file is read before anything is uploaded, so broken file
+	// doesn't leave textures half-filled
+	var images [][]float32
+	err = forEachCachedImage(textures, func(t cachedTexture, target uint32, level, size int32, n int) error {
+		data := make([]float32, n)
+		images = append(images, data)
+		return binary.Read(r, binary.LittleEndian, data)
+	})
+	if err != nil {
+		return err
+	}
+	i := 0
+	forEachCachedImage(textures, func(t cachedTexture, target uint32, level, size int32, n int) error {
+		gl.BindTexture(t.target, t.texture)
+		gl.TexSubImage2D(target, level, 0, 0, size, size, t.format, gl.FLOAT, gl.Ptr(images[i]))
+		gl.BindTexture(t.target, 0)
+		i++
+		return nil
+	})
+	forgetBindings()
+	return nil
+}
+
+// Write textures into cache file, errors are ignored
+func saveTextureCache(file string, textures []cachedTexture) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+
+	// Written into temporary file, so readers never see partial file
+	f, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(environmentCacheMagic)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+	err = forEachCachedImage(textures, func(t cachedTexture, target uint32, level, size int32, n int) error {
+		data := make([]float32, n)
+		gl.BindTexture(t.target, t.texture)
+		gl.GetTexImage(target, level, t.format, gl.FLOAT, gl.Ptr(data))
+		gl.BindTexture(t.target, 0)
+		return binary.Write(w, binary.LittleEndian, data)
+	})
+	forgetBindings()
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,355 @@
+package gfx
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Attachments of framebuffer
+type FramebufferSpec struct {
+	// Internal formats of color attachments, e.g. gl.RGBA8,
+	// gl.SRGB8_ALPHA8, gl.RGBA16F
+	Colors []uint32
+
+	// Internal format of depth (e.g. gl.DEPTH_COMPONENT24) or
+	// depth-stencil (e.g. gl.DEPTH24_STENCIL8) attachment, 0 for none
+	Depth uint32
+
+	// Whether depth is stored in texture which could be sampled, rather
+	// than in renderbuffer
+	DepthTexture bool
+
+	// Number of samples, more than 1 makes attachments multisampled
+	// renderbuffers, which are resolved into textures by Resolve
+	Samples int32
+
+	// Number of mipmap levels of color textures, 0 or 1 for base level
+	// only. Framebuffer renders into base level, the rest could be filled
+	// by gl.GenerateMipmap.
+	Levels int32
+}
+
+// Framebuffer renders into textures instead of window. Color attachments
+// are textures which could be sampled, unless framebuffer is multisampled,
+// then its textures are filled by Resolve.
+type Framebuffer struct {
+	spec     FramebufferSpec
+	width    int32
+	height   int32
+	id       uint32
+	colors   []uint32 // textures, or renderbuffers when multisampled
+	depth    uint32   // texture or renderbuffer
+	resolved *Framebuffer
+}
+
+// NewFramebuffer creates framebuffer of size with attachments of spec.
+func NewFramebuffer(width, height int32, spec FramebufferSpec) (*Framebuffer, error) {
+	spec.Colors = append([]uint32(nil), spec.Colors...)
+	f := &Framebuffer{spec: spec}
+	if f.multisampled() {
+		resolvedSpec := spec
+		resolvedSpec.Samples = 0
+		if !spec.DepthTexture {
+			resolvedSpec.Depth = 0
+		}
+		resolved, err := NewFramebuffer(width, height, resolvedSpec)
+		if err != nil {
+			return nil, err
+		}
+		f.resolved = resolved
+	}
+	gl.GenFramebuffers(1, &f.id)
+	if err := f.allocate(width, height); err != nil {
+		f.Dispose()
+		return nil, err
+	}
+	return f, nil
+}
+
+// ID returns name of framebuffer object, which is recreated when
+// framebuffer is resized.
+func (f *Framebuffer) ID() uint32 {
+	return f.id
+}
+
+// Size returns width and height of attachments.
+func (f *Framebuffer) Size() (int32, int32) {
+	return f.width, f.height
+}
+
+// Samples returns number of samples, 0 or 1 if not multisampled.
+func (f *Framebuffer) Samples() int32 {
+	return f.spec.Samples
+}
+
+// ColorTexture returns texture of i-th color attachment. Texture of
+// multisampled framebuffer is valid after Resolve. Textures are recreated
+// when framebuffer is resized.
+func (f *Framebuffer) ColorTexture(i int) uint32 {
+	if f.resolved != nil {
+		return f.resolved.ColorTexture(i)
+	}
+	return f.colors[i]
+}
+
+// DepthTexture returns texture of depth attachment, 0 if depth isn't
+// stored in texture.
+func (f *Framebuffer) DepthTexture() uint32 {
+	if f.resolved != nil {
+		return f.resolved.DepthTexture()
+	}
+	if !f.spec.DepthTexture {
+		return 0
+	}
+	return f.depth
+}
+
+// Bind makes framebuffer target of rendering, viewport is set to its size.
+func (f *Framebuffer) Bind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.id)
+	gl.Viewport(0, 0, f.width, f.height)
+}
+
+// Resize recreates attachments with new size, it does nothing when size
+// doesn't change or is empty, as of minimized window. Framebuffer is left
+// intact when new attachments can't be created.
+func (f *Framebuffer) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 || (width == f.width && height == f.height) {
+		return nil
+	}
+	next, err := NewFramebuffer(width, height, f.spec)
+	if err != nil {
+		return err
+	}
+	f.Dispose()
+	*f = *next
+	return nil
+}
+
+// ProcessEvent resizes framebuffer to drawable size of window when it
+// changes size.
+func (f *Framebuffer) ProcessEvent(event sdl.Event) error {
+	e, ok := event.(*sdl.WindowEvent)
+	if !ok || e.Event != sdl.WINDOWEVENT_SIZE_CHANGED {
+		return nil
+	}
+	window, err := sdl.GetWindowFromID(e.WindowID)
+	if err != nil {
+		return err
+	}
+	width, height := window.GLGetDrawableSize()
+	return f.Resize(width, height)
+}
+
+// Resolve copies samples of multisampled framebuffer into its textures,
+// it does nothing when framebuffer isn't multisampled.
+func (f *Framebuffer) Resolve() {
+	if f.resolved == nil {
+		return
+	}
+	var lastRead, lastDraw int32
+	gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &lastRead)
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastDraw)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, f.id)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, f.resolved.id)
+
+	// Attachments are copied one by one, blit writes to all draw buffers
+	for i := range f.colors {
+		attachment := uint32(gl.COLOR_ATTACHMENT0 + i)
+		gl.ReadBuffer(attachment)
+		gl.DrawBuffer(attachment)
+		gl.BlitFramebuffer(0, 0, f.width, f.height, 0, 0, f.width, f.height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+	if f.resolved.depth != 0 {
+		gl.BlitFramebuffer(0, 0, f.width, f.height, 0, 0, f.width, f.height, f.depthMask(), gl.NEAREST)
+	}
+	f.resolved.setDrawBuffers()
+	if len(f.colors) > 0 {
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	}
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, uint32(lastRead))
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, uint32(lastDraw))
+}
+
+// BlitToScreen copies first color attachment to default framebuffer of
+// size, stretching it when sizes differ. Multisampled framebuffer is
+// resolved.
+func (f *Framebuffer) BlitToScreen(width, height int32) {
+	// Samples are resolved first, multisampled blits can't scale nor
+	// convert formats
+	source := f
+	if f.resolved != nil {
+		f.Resolve()
+		source = f.resolved
+	}
+	var lastRead, lastDraw int32
+	gl.GetIntegerv(gl.READ_FRAMEBUFFER_BINDING, &lastRead)
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastDraw)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, source.id)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	gl.BlitFramebuffer(0, 0, source.width, source.height, 0, 0, width, height, gl.COLOR_BUFFER_BIT, gl.LINEAR)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, uint32(lastRead))
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, uint32(lastDraw))
+}
+
+// Dispose cleans up the resources.
+func (f *Framebuffer) Dispose() {
+	f.release()
+	if f.id != 0 {
+		gl.DeleteFramebuffers(1, &f.id)
+	}
+	f.id = 0
+	if f.resolved != nil {
+		f.resolved.Dispose()
+	}
+}
+
+func (f *Framebuffer) multisampled() bool {
+	return f.spec.Samples > 1
+}
+
+// Create attachments of size and check completeness
+func (f *Framebuffer) allocate(width, height int32) error {
+	f.width, f.height = width, height
+	var lastFramebuffer int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &lastFramebuffer)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(lastFramebuffer))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.id)
+
+	f.colors = make([]uint32, len(f.spec.Colors))
+	for i, format := range f.spec.Colors {
+		attachment := uint32(gl.COLOR_ATTACHMENT0 + i)
+		if f.multisampled() {
+			f.colors[i] = f.newRenderbuffer(format)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER, f.colors[i])
+		} else {
+			f.colors[i] = f.newTexture(format, f.spec.Levels)
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, attachment, gl.TEXTURE_2D, f.colors[i], 0)
+		}
+	}
+	if f.spec.Depth != 0 {
+		attachment := uint32(gl.DEPTH_ATTACHMENT)
+		switch f.depthMask() {
+		case gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT:
+			attachment = gl.DEPTH_STENCIL_ATTACHMENT
+		case gl.STENCIL_BUFFER_BIT:
+			attachment = gl.STENCIL_ATTACHMENT
+		}
+		if f.spec.DepthTexture && !f.multisampled() {
+			f.depth = f.newTexture(f.spec.Depth, 1)
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, attachment, gl.TEXTURE_2D, f.depth, 0)
+		} else {
+			f.depth = f.newRenderbuffer(f.spec.Depth)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER, f.depth)
+		}
+	}
+	f.setDrawBuffers()
+	return checkFramebuffer(gl.FRAMEBUFFER)
+}
+
+// Delete attachments
+func (f *Framebuffer) release() {
+	for i, name := range f.colors {
+		if f.multisampled() {
+			gl.DeleteRenderbuffers(1, &name)
+		} else {
+			gl.DeleteTextures(1, &name)
+		}
+		f.colors[i] = 0
+	}
+	f.colors = nil
+	if f.depth != 0 {
+		if f.spec.DepthTexture && !f.multisampled() {
+			gl.DeleteTextures(1, &f.depth)
+		} else {
+			gl.DeleteRenderbuffers(1, &f.depth)
+		}
+	}
+	f.depth = 0
+}
+
+// Direct fragment outputs to color attachments in order, framebuffer
+// must be bound to gl.DRAW_FRAMEBUFFER
+func (f *Framebuffer) setDrawBuffers() {
+	if len(f.colors) == 0 {
+		gl.DrawBuffer(gl.NONE)
+		gl.ReadBuffer(gl.NONE)
+		return
+	}
+	buffers := make([]uint32, len(f.colors))
+	for i := range buffers {
+		buffers[i] = uint32(gl.COLOR_ATTACHMENT0 + i)
+	}
+	gl.DrawBuffers(int32(len(buffers)), &buffers[0])
+}
+
+// Buffer bits held by depth attachment
+func (f *Framebuffer) depthMask() uint32 {
+	switch f.spec.Depth {
+	case 0:
+		return 0
+	case gl.DEPTH24_STENCIL8, gl.DEPTH32F_STENCIL8:
+		return gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT
+	case gl.STENCIL_INDEX8:
+		return gl.STENCIL_BUFFER_BIT
+	}
+	return gl.DEPTH_BUFFER_BIT
+}
+
+func (f *Framebuffer) newTexture(format uint32, levels int32) uint32 {
+	minFilter := int32(gl.LINEAR)
+	if levels > 1 {
+		minFilter = gl.LINEAR_MIPMAP_LINEAR
+	} else {
+		levels = 1
+	}
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexStorage2D(gl.TEXTURE_2D, levels, format, f.width, f.height)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, minFilter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return texture
+}
+
+func (f *Framebuffer) newRenderbuffer(format uint32) uint32 {
+	var renderbuffer uint32
+	gl.GenRenderbuffers(1, &renderbuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, renderbuffer)
+	if f.multisampled() {
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, f.spec.Samples, format, f.width, f.height)
+	} else {
+		gl.RenderbufferStorage(gl.RENDERBUFFER, format, f.width, f.height)
+	}
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+	return renderbuffer
+}
+
+// Check completeness of framebuffer bound to target
+func checkFramebuffer(target uint32) error {
+	status := gl.CheckFramebufferStatus(target)
+	if status == gl.FRAMEBUFFER_COMPLETE {
+		return nil
+	}
+	reasons := map[uint32]string{
+		gl.FRAMEBUFFER_UNDEFINED:                     "default framebuffer doesn't exist",
+		gl.FRAMEBUFFER_INCOMPLETE_ATTACHMENT:         "attachment is incomplete",
+		gl.FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT: "no attachments",
+		gl.FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER:        "draw buffer has no attachment",
+		gl.FRAMEBUFFER_INCOMPLETE_READ_BUFFER:        "read buffer has no attachment",
+		gl.FRAMEBUFFER_UNSUPPORTED:                   "combination of formats is unsupported",
+		gl.FRAMEBUFFER_INCOMPLETE_MULTISAMPLE:        "attachments have different numbers of samples",
+		gl.FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS:      "attachments have different layer counts",
+	}
+	if reason, ok := reasons[status]; ok {
+		return fmt.Errorf("framebuffer is incomplete: %v", reason)
+	}
+	return fmt.Errorf("framebuffer is incomplete: status 0x%x", status)
+}
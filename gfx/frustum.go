@@ -0,0 +1,97 @@
+package gfx
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Plane of points p where Normal·p + Distance = 0, Normal points to the
+// inner side
+type Plane struct {
+	Normal   mgl32.Vec3
+	Distance float32
+}
+
+// SignedDistance returns distance of point p from plane, positive on the
+// inner side.
+func (p Plane) SignedDistance(point mgl32.Vec3) float32 {
+	return p.Normal.Dot(point) + p.Distance
+}
+
+// View volume bounded by planes facing inwards: left, right, bottom, top,
+// near and far
+type Frustum struct {
+	Planes [6]Plane
+}
+
+// NewFrustum extracts planes of volume which viewProjection maps to clip
+// space, e.g. projection of camera multiplied by its view matrix.
+func NewFrustum(viewProjection mgl32.Mat4) Frustum {
+	var f Frustum
+	w := viewProjection.Row(3)
+	for i := 0; i < 3; i++ {
+		row := viewProjection.Row(i)
+		f.Planes[i*2] = newPlane(w.Add(row))
+		f.Planes[i*2+1] = newPlane(w.Sub(row))
+	}
+	return f
+}
+
+// CameraFrustum returns view volume of camera.
+func CameraFrustum(camera Camera) Frustum {
+	return NewFrustum(camera.Projection().Mul4(camera.View()))
+}
+
+// ContainsPoint reports whether point p is inside frustum.
+func (f Frustum) ContainsPoint(p mgl32.Vec3) bool {
+	for _, plane := range f.Planes {
+		if plane.SignedDistance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsAABB reports whether box is at least partially inside
+// frustum. It is conservative, big boxes near corners of frustum may be
+// reported although they are outside.
+func (f Frustum) IntersectsAABB(b AABB) bool {
+	if b.IsEmpty() {
+		return false
+	}
+	for _, plane := range f.Planes {
+		// Corner of box furthest along normal of plane
+		var p mgl32.Vec3
+		for i := 0; i < 3; i++ {
+			if plane.Normal[i] >= 0 {
+				p[i] = b.Max[i]
+			} else {
+				p[i] = b.Min[i]
+			}
+		}
+		if plane.SignedDistance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSphere reports whether sphere is at least partially inside
+// frustum, with the same conservativeness as IntersectsAABB.
+func (f Frustum) IntersectsSphere(s Sphere) bool {
+	for _, plane := range f.Planes {
+		if plane.SignedDistance(s.Center) < -s.Radius {
+			return false
+		}
+	}
+	return true
+}
+
+// Get plane of coefficients with normalized normal
+func newPlane(v mgl32.Vec4) Plane {
+	normal := v.Vec3()
+	length := normal.Len()
+	if length == 0 {
+		return Plane{}
+	}
+	return Plane{Normal: normal.Mul(1 / length), Distance: v[3] / length}
+}
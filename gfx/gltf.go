@@ -0,0 +1,1401 @@
+package gfx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Model loaded from glTF 2.0 file (.gltf or .glb)
+type GltfModel struct {
+	Scene      int // index of default scene
+	Scenes     []*GltfScene
+	Nodes      []*GltfNode
+	Meshes     []*GltfMesh
+	Materials  []*GltfMaterial
+	Textures   []*GltfTexture
+	Images     []image.Image
+	Skins      []*GltfSkin
+	Animations []*GltfAnimation
+}
+
+// Scene is a set of root nodes
+type GltfScene struct {
+	Name  string
+	Nodes []int
+}
+
+// Node of hierarchy, indices are -1 when absent
+type GltfNode struct {
+	Name        string
+	Parent      int
+	Children    []int
+	Mesh        int
+	Skin        int
+	Camera      int
+	Translation mgl32.Vec3
+	Rotation    mgl32.Quat
+	Scale       mgl32.Vec3
+	Weights     []float32 // weights of morph targets
+}
+
+// LocalMatrix returns transform relative to parent node.
+func (n *GltfNode) LocalMatrix() mgl32.Mat4 {
+	return mgl32.Translate3D(n.Translation[0], n.Translation[1], n.Translation[2]).
+		Mul4(n.Rotation.Mat4()).
+		Mul4(mgl32.Scale3D(n.Scale[0], n.Scale[1], n.Scale[2]))
+}
+
+// Mesh consisting of primitives
+type GltfMesh struct {
+	Name       string
+	Primitives []*GltfPrimitive
+	Weights    []float32 // default weights of morph targets
+}
+
+// Generic vertex attribute, values are converted to float32
+type GltfAttribute struct {
+	Components int
+	Values     []float32
+}
+
+// Morph target, containing displacements of vertex attributes
+type GltfMorphTarget struct {
+	Positions []mgl32.Vec3
+	Normals   []mgl32.Vec3
+	Tangents  []mgl32.Vec3
+}
+
+// Primitive, i.e. geometry drawn with one material
+type GltfPrimitive struct {
+	Mode       uint32 // opengl primitive type
+	Material   int    // -1 means default material
+	Data       MeshData
+	TexCoords1 []mgl32.Vec2
+	Joints     [][4]uint16
+	Weights    []mgl32.Vec4
+	Targets    []GltfMorphTarget
+	Attributes map[string]GltfAttribute // application-specific attributes, e.g. _TEMPERATURE
+}
+
+// Upload creates mesh from primitive, joints/weights and second texture
+// coordinates are included when present.
+func (p *GltfPrimitive) Upload(program uint32) (*Mesh, error) {
+	streams, indices := p.Data.vertexStreams()
+	if len(p.TexCoords1) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribTexCoord1, Components: 2, Type: gl.FLOAT}},
+			Data:   p.TexCoords1,
+		})
+	}
+	if len(p.Joints) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribJoints, Components: 4, Type: gl.UNSIGNED_SHORT}},
+			Data:   p.Joints,
+		})
+	}
+	if len(p.Weights) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribWeights, Components: 4, Type: gl.FLOAT}},
+			Data:   p.Weights,
+		})
+	}
+	m, err := NewMesh(program, p.Mode, streams, indices)
+	if err != nil {
+		return nil, err
+	}
+	m.bounds = p.Data.Bounds()
+	return m, nil
+}
+
+// Reference to texture used by material, Index is -1 when absent
+type GltfTextureRef struct {
+	Index    int
+	TexCoord int     // index of texture coordinates set
+	Scale    float32 // scale of normal map, or strength of occlusion map
+}
+
+// PBR metallic-roughness material
+type GltfMaterial struct {
+	Name                     string
+	BaseColorFactor          mgl32.Vec4
+	BaseColorTexture         GltfTextureRef
+	MetallicFactor           float32
+	RoughnessFactor          float32
+	MetallicRoughnessTexture GltfTextureRef
+	NormalTexture            GltfTextureRef
+	OcclusionTexture         GltfTextureRef
+	EmissiveTexture          GltfTextureRef
+	EmissiveFactor           mgl32.Vec3 // scaled by KHR_materials_emissive_strength
+	AlphaMode                string     // OPAQUE, MASK or BLEND
+	AlphaCutoff              float32
+	DoubleSided              bool
+}
+
+// Texture is an image combined with sampler settings,
+// filters and wraps use opengl enums.
+type GltfTexture struct {
+	Image     int
+	MagFilter int32
+	MinFilter int32
+	WrapS     int32
+	WrapT     int32
+}
+
+// Skin for vertex skinning
+type GltfSkin struct {
+	Name                string
+	Joints              []int // indices of joint nodes
+	Skeleton            int   // root node, -1 when absent
+	InverseBindMatrices []mgl32.Mat4
+}
+
+// Animation target path
+const (
+	GltfPathTranslation = "translation"
+	GltfPathRotation    = "rotation"
+	GltfPathScale       = "scale"
+	GltfPathWeights     = "weights"
+)
+
+// Animation interpolation
+const (
+	GltfInterpolationLinear      = "LINEAR"
+	GltfInterpolationStep        = "STEP"
+	GltfInterpolationCubicSpline = "CUBICSPLINE"
+)
+
+// Keyframes of animated property
+type GltfAnimationSampler struct {
+	Input         []float32 // keyframe times in seconds
+	Output        []float32 // keyframe values, tangents are included for cubic spline
+	Components    int       // number of components of each value
+	Interpolation string
+}
+
+// Channel connecting sampler with animated node property
+type GltfAnimationChannel struct {
+	Sampler int
+	Node    int
+	Path    string
+}
+
+// Animation of node properties
+type GltfAnimation struct {
+	Name     string
+	Channels []GltfAnimationChannel
+	Samplers []*GltfAnimationSampler
+}
+
+// LoadGltf loads glTF or GLB model from disk, external buffers and
+// images are loaded relative to directory of the file.
+func LoadGltf(file string) (*GltfModel, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found on disk: %v", file, err)
+	}
+
+	dir := filepath.Dir(file)
+	m, err := parseGltf(data, func(uri string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(uri)))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load model %q failed: %v", file, err)
+	}
+	return m, nil
+}
+
+// LoadGltfFS loads glTF or GLB model from filesystem fsys.
+func LoadGltfFS(fsys fs.FS, name string) (*GltfModel, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found in filesystem: %v", name, err)
+	}
+
+	dir := path.Dir(name)
+	m, err := parseGltf(data, func(uri string) ([]byte, error) {
+		return fs.ReadFile(fsys, path.Join(dir, uri))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load model %q failed: %v", name, err)
+	}
+	return m, nil
+}
+
+// ReadGltf parses self-contained glTF or GLB model from r,
+// external buffers and images are not allowed.
+func ReadGltf(r io.Reader) (*GltfModel, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseGltf(data, nil)
+}
+
+// LoadTextures uploads all textures of model, returned slice is indexed
+// like Textures. Base color and emissive textures are sRGB encoded and
+// linearized by sampling, other textures are stored as is.
+func (m *GltfModel) LoadTextures() ([]uint32, error) {
+	srgb := make([]bool, len(m.Textures))
+	for _, mat := range m.Materials {
+		for _, ref := range []GltfTextureRef{mat.BaseColorTexture, mat.EmissiveTexture} {
+			if ref.Index >= 0 && ref.Index < len(srgb) {
+				srgb[ref.Index] = true
+			}
+		}
+	}
+
+	textures := make([]uint32, len(m.Textures))
+	for i, t := range m.Textures {
+		upload := LoadTextureFromImage
+		if srgb[i] {
+			upload = LoadTextureFromImageSRGB
+		}
+		texture, err := upload(m.Images[t.Image])
+		if err != nil {
+			gl.DeleteTextures(int32(i), &textures[0])
+			return nil, fmt.Errorf("load texture %d failed: %v", i, err)
+		}
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, t.MagFilter)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, t.MinFilter)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, t.WrapS)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, t.WrapT)
+		if t.MinFilter != gl.NEAREST && t.MinFilter != gl.LINEAR {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
+		textures[i] = texture
+	}
+	return textures, nil
+}
+
+// WorldMatrices returns transforms of all nodes relative to scene root.
+func (m *GltfModel) WorldMatrices() []mgl32.Mat4 {
+	matrices := make([]mgl32.Mat4, len(m.Nodes))
+	done := make([]bool, len(m.Nodes))
+	var visit func(i int) mgl32.Mat4
+	visit = func(i int) mgl32.Mat4 {
+		if !done[i] {
+			matrices[i] = m.Nodes[i].LocalMatrix()
+			if p := m.Nodes[i].Parent; p >= 0 {
+				matrices[i] = visit(p).Mul4(matrices[i])
+			}
+			done[i] = true
+		}
+		return matrices[i]
+	}
+	for i := range m.Nodes {
+		visit(i)
+	}
+	return matrices
+}
+
+// Duration returns length of animation in seconds.
+func (a *GltfAnimation) Duration() float32 {
+	var d float32
+	for _, s := range a.Samplers {
+		if len(s.Input) > 0 && s.Input[len(s.Input)-1] > d {
+			d = s.Input[len(s.Input)-1]
+		}
+	}
+	return d
+}
+
+// Apply sets animated properties of model's nodes at time t.
+func (a *GltfAnimation) Apply(m *GltfModel, t float32) {
+	for _, c := range a.Channels {
+		node := m.Nodes[c.Node]
+		v := a.Samplers[c.Sampler].Sample(t, c.Path == GltfPathRotation)
+		switch c.Path {
+		case GltfPathTranslation:
+			node.Translation = mgl32.Vec3{v[0], v[1], v[2]}
+		case GltfPathRotation:
+			node.Rotation = mgl32.Quat{W: v[3], V: mgl32.Vec3{v[0], v[1], v[2]}}.Normalize()
+		case GltfPathScale:
+			node.Scale = mgl32.Vec3{v[0], v[1], v[2]}
+		case GltfPathWeights:
+			node.Weights = append(node.Weights[:0], v...)
+		}
+	}
+}
+
+// Sample interpolates keyframes at time t, values are treated as
+// quaternions (x, y, z, w) when isRotation is true.
+func (s *GltfAnimationSampler) Sample(t float32, isRotation bool) []float32 {
+	n := s.Components
+	stride := n
+	offset := 0
+	if s.Interpolation == GltfInterpolationCubicSpline {
+		// Each keyframe is (in-tangent, value, out-tangent)
+		stride = 3 * n
+		offset = n
+	}
+	value := func(k int) []float32 {
+		return s.Output[k*stride+offset : k*stride+offset+n]
+	}
+
+	result := make([]float32, n)
+	last := len(s.Input) - 1
+	if last < 0 {
+		return result
+	}
+	if t <= s.Input[0] {
+		copy(result, value(0))
+		return result
+	}
+	if t >= s.Input[last] {
+		copy(result, value(last))
+		return result
+	}
+
+	k := sort.Search(len(s.Input), func(i int) bool { return s.Input[i] > t }) - 1
+	t0, t1 := s.Input[k], s.Input[k+1]
+	dt := t1 - t0
+	u := (t - t0) / dt
+	v0, v1 := value(k), value(k+1)
+	switch s.Interpolation {
+	case GltfInterpolationStep:
+		copy(result, v0)
+	case GltfInterpolationCubicSpline:
+		b0 := s.Output[k*stride+2*n : k*stride+3*n]   // out-tangent of k
+		a1 := s.Output[(k+1)*stride : (k+1)*stride+n] // in-tangent of k+1
+		u2, u3 := u*u, u*u*u
+		for i := 0; i < n; i++ {
+			result[i] = (2*u3-3*u2+1)*v0[i] + (u3-2*u2+u)*dt*b0[i] +
+				(-2*u3+3*u2)*v1[i] + (u3-u2)*dt*a1[i]
+		}
+		if isRotation {
+			q := mgl32.Quat{W: result[3], V: mgl32.Vec3{result[0], result[1], result[2]}}.Normalize()
+			result[0], result[1], result[2], result[3] = q.V[0], q.V[1], q.V[2], q.W
+		}
+	default:
+		if isRotation {
+			q0 := mgl32.Quat{W: v0[3], V: mgl32.Vec3{v0[0], v0[1], v0[2]}}
+			q1 := mgl32.Quat{W: v1[3], V: mgl32.Vec3{v1[0], v1[1], v1[2]}}
+			q := mgl32.QuatSlerp(q0, q1, u)
+			result[0], result[1], result[2], result[3] = q.V[0], q.V[1], q.V[2], q.W
+		} else {
+			for i := 0; i < n; i++ {
+				result[i] = v0[i] + (v1[i]-v0[i])*u
+			}
+		}
+	}
+	return result
+}
+
+// JSON structures of glTF document
+type gltfDoc struct {
+	Asset struct {
+		Version    string `json:"version"`
+		MinVersion string `json:"minVersion"`
+	} `json:"asset"`
+	ExtensionsRequired []string          `json:"extensionsRequired"`
+	Cameras            []json.RawMessage `json:"cameras"` // only counted
+	Scene              *int              `json:"scene"`
+	Scenes             []struct {
+		Name  string `json:"name"`
+		Nodes []int  `json:"nodes"`
+	} `json:"scenes"`
+	Nodes []struct {
+		Name        string    `json:"name"`
+		Children    []int     `json:"children"`
+		Mesh        *int      `json:"mesh"`
+		Skin        *int      `json:"skin"`
+		Camera      *int      `json:"camera"`
+		Matrix      []float32 `json:"matrix"`
+		Translation []float32 `json:"translation"`
+		Rotation    []float32 `json:"rotation"`
+		Scale       []float32 `json:"scale"`
+		Weights     []float32 `json:"weights"`
+	} `json:"nodes"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes map[string]int   `json:"attributes"`
+			Indices    *int             `json:"indices"`
+			Material   *int             `json:"material"`
+			Mode       *uint32          `json:"mode"`
+			Targets    []map[string]int `json:"targets"`
+		} `json:"primitives"`
+		Weights []float32 `json:"weights"`
+	} `json:"meshes"`
+	Accessors   []gltfAccessor `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+	Materials []struct {
+		Name                 string `json:"name"`
+		PbrMetallicRoughness *struct {
+			BaseColorFactor          []float32       `json:"baseColorFactor"`
+			BaseColorTexture         *gltfTextureRef `json:"baseColorTexture"`
+			MetallicFactor           *float32        `json:"metallicFactor"`
+			RoughnessFactor          *float32        `json:"roughnessFactor"`
+			MetallicRoughnessTexture *gltfTextureRef `json:"metallicRoughnessTexture"`
+		} `json:"pbrMetallicRoughness"`
+		NormalTexture    *gltfTextureRef `json:"normalTexture"`
+		OcclusionTexture *gltfTextureRef `json:"occlusionTexture"`
+		EmissiveTexture  *gltfTextureRef `json:"emissiveTexture"`
+		EmissiveFactor   []float32       `json:"emissiveFactor"`
+		AlphaMode        string          `json:"alphaMode"`
+		AlphaCutoff      *float32        `json:"alphaCutoff"`
+		DoubleSided      bool            `json:"doubleSided"`
+		Extensions       struct {
+			EmissiveStrength *struct {
+				EmissiveStrength *float32 `json:"emissiveStrength"`
+			} `json:"KHR_materials_emissive_strength"`
+		} `json:"extensions"`
+	} `json:"materials"`
+	Textures []struct {
+		Sampler *int `json:"sampler"`
+		Source  *int `json:"source"`
+	} `json:"textures"`
+	Images []struct {
+		URI        string `json:"uri"`
+		MimeType   string `json:"mimeType"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+	Samplers []struct {
+		MagFilter int32 `json:"magFilter"`
+		MinFilter int32 `json:"minFilter"`
+		WrapS     int32 `json:"wrapS"`
+		WrapT     int32 `json:"wrapT"`
+	} `json:"samplers"`
+	Skins []struct {
+		Name                string `json:"name"`
+		InverseBindMatrices *int   `json:"inverseBindMatrices"`
+		Skeleton            *int   `json:"skeleton"`
+		Joints              []int  `json:"joints"`
+	} `json:"skins"`
+	Animations []struct {
+		Name     string `json:"name"`
+		Channels []struct {
+			Sampler int `json:"sampler"`
+			Target  struct {
+				Node *int   `json:"node"`
+				Path string `json:"path"`
+			} `json:"target"`
+		} `json:"channels"`
+		Samplers []struct {
+			Input         int    `json:"input"`
+			Output        int    `json:"output"`
+			Interpolation string `json:"interpolation"`
+		} `json:"samplers"`
+	} `json:"animations"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int   `json:"bufferView"`
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType int    `json:"componentType"`
+	Normalized    bool   `json:"normalized"`
+	Count         int    `json:"count"`
+	Type          string `json:"type"`
+	Sparse        *struct {
+		Count   int `json:"count"`
+		Indices struct {
+			BufferView    int `json:"bufferView"`
+			ByteOffset    int `json:"byteOffset"`
+			ComponentType int `json:"componentType"`
+		} `json:"indices"`
+		Values struct {
+			BufferView int `json:"bufferView"`
+			ByteOffset int `json:"byteOffset"`
+		} `json:"values"`
+	} `json:"sparse"`
+}
+
+type gltfTextureRef struct {
+	Index    int      `json:"index"`
+	TexCoord int      `json:"texCoord"`
+	Scale    *float32 `json:"scale"`
+	Strength *float32 `json:"strength"`
+}
+
+// Accessor component types
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+var gltfTypeComponents = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT2":   4,
+	"MAT3":   9,
+	"MAT4":   16,
+}
+
+// Maximum size of data of single accessor
+const maxGltfAccessorSize = 1 << 30
+
+var gltfSupportedExtensions = map[string]bool{
+	"KHR_materials_emissive_strength": true,
+	"KHR_mesh_quantization":           true,
+}
+
+// State of glTF loader
+type gltfLoader struct {
+	doc     gltfDoc
+	buffers [][]byte
+	read    func(uri string) ([]byte, error)
+}
+
+func parseGltf(data []byte, read func(uri string) ([]byte, error)) (*GltfModel, error) {
+	l := &gltfLoader{read: read}
+
+	// Binary container: header followed by JSON and BIN chunks
+	var bin []byte
+	if len(data) >= 12 && string(data[:4]) == "glTF" {
+		version := binary.LittleEndian.Uint32(data[4:])
+		if version != 2 {
+			return nil, fmt.Errorf("unsupported GLB version %d", version)
+		}
+		length := int(binary.LittleEndian.Uint32(data[8:]))
+		if length > len(data) {
+			return nil, fmt.Errorf("GLB is truncated, expect %d bytes, got %d", length, len(data))
+		}
+		var jsonChunk []byte
+		for offset := 12; offset+8 <= length; {
+			chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+			chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+			offset += 8
+			if offset+chunkLength > length {
+				return nil, fmt.Errorf("GLB chunk at %d is truncated", offset-8)
+			}
+			chunk := data[offset : offset+chunkLength]
+			switch chunkType {
+			case 0x4E4F534A: // JSON
+				jsonChunk = chunk
+			case 0x004E4942: // BIN
+				if bin == nil {
+					bin = chunk
+				}
+			}
+			offset += (chunkLength + 3) &^ 3
+		}
+		if jsonChunk == nil {
+			return nil, fmt.Errorf("GLB has no JSON chunk")
+		}
+		data = jsonChunk
+	}
+
+	if err := json.Unmarshal(data, &l.doc); err != nil {
+		return nil, fmt.Errorf("invalid glTF json: %v", err)
+	}
+	if !strings.HasPrefix(l.doc.Asset.Version, "2.") {
+		return nil, fmt.Errorf("unsupported glTF version %q", l.doc.Asset.Version)
+	}
+	for _, ext := range l.doc.ExtensionsRequired {
+		if !gltfSupportedExtensions[ext] {
+			return nil, fmt.Errorf("required extension %q isn't supported", ext)
+		}
+	}
+
+	// Load buffers
+	l.buffers = make([][]byte, len(l.doc.Buffers))
+	for i, b := range l.doc.Buffers {
+		var err error
+		if b.URI == "" {
+			if bin == nil || i != 0 {
+				return nil, fmt.Errorf("buffer %d has no data", i)
+			}
+			l.buffers[i] = bin
+		} else if l.buffers[i], err = l.readURI(b.URI); err != nil {
+			return nil, fmt.Errorf("load buffer %d failed: %v", i, err)
+		}
+		if len(l.buffers[i]) < b.ByteLength {
+			return nil, fmt.Errorf("buffer %d is truncated, expect %d bytes, got %d", i, b.ByteLength, len(l.buffers[i]))
+		}
+	}
+
+	m := &GltfModel{}
+	steps := []func(*GltfModel) error{
+		l.loadImages,
+		l.loadTextures,
+		l.loadMaterials,
+		l.loadMeshes,
+		l.loadNodes,
+		l.loadSkins,
+		l.loadAnimations,
+	}
+	for _, step := range steps {
+		if err := step(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Read data referenced by uri, which is either data uri or relative path
+func (l *gltfLoader) readURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		i := strings.Index(uri, ",")
+		if i < 0 || !strings.HasSuffix(uri[:i], ";base64") {
+			return nil, fmt.Errorf("unsupported data uri")
+		}
+		return base64.StdEncoding.DecodeString(uri[i+1:])
+	}
+	if l.read == nil {
+		return nil, fmt.Errorf("external file %q isn't allowed", uri)
+	}
+	p, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri %q: %v", uri, err)
+	}
+	return l.read(p)
+}
+
+// Get bytes of buffer view
+func (l *gltfLoader) bufferView(index int) ([]byte, int, error) {
+	if index < 0 || index >= len(l.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("buffer view %d doesn't exist", index)
+	}
+	bv := l.doc.BufferViews[index]
+	if bv.Buffer < 0 || bv.Buffer >= len(l.buffers) {
+		return nil, 0, fmt.Errorf("buffer %d doesn't exist", bv.Buffer)
+	}
+	buf := l.buffers[bv.Buffer]
+	if bv.ByteOffset < 0 || bv.ByteLength < 0 || bv.ByteOffset > len(buf) || bv.ByteLength > len(buf)-bv.ByteOffset {
+		return nil, 0, fmt.Errorf("buffer view %d is out of range of buffer %d", index, bv.Buffer)
+	}
+	if bv.ByteStride < 0 {
+		return nil, 0, fmt.Errorf("buffer view %d has invalid stride %d", index, bv.ByteStride)
+	}
+	return buf[bv.ByteOffset : bv.ByteOffset+bv.ByteLength], bv.ByteStride, nil
+}
+
+func gltfComponentSize(componentType int) int {
+	switch componentType {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	case gltfUnsignedInt, gltfFloat:
+		return 4
+	}
+	return 0
+}
+
+// Read accessor into tightly packed bytes, returns accessor and number of components
+func (l *gltfLoader) accessorData(index int) (*gltfAccessor, int, []byte, error) {
+	if index < 0 || index >= len(l.doc.Accessors) {
+		return nil, 0, nil, fmt.Errorf("accessor %d doesn't exist", index)
+	}
+	acc := &l.doc.Accessors[index]
+	comps := gltfTypeComponents[acc.Type]
+	csize := gltfComponentSize(acc.ComponentType)
+	if comps == 0 || csize == 0 {
+		return nil, 0, nil, fmt.Errorf("accessor %d has invalid type %s/%d", index, acc.Type, acc.ComponentType)
+	}
+	elemSize := comps * csize
+
+	// Columns of matrices are aligned to 4 bytes
+	columns, columnSize, columnStride := 1, elemSize, elemSize
+	if strings.HasPrefix(acc.Type, "MAT") {
+		columns = int(acc.Type[3] - '0')
+		columnSize = columns * csize
+		columnStride = (columnSize + 3) &^ 3
+	}
+
+	// Sizes come from file, so they are checked before allocating
+	if acc.Count < 0 || acc.ByteOffset < 0 {
+		return nil, 0, nil, fmt.Errorf("accessor %d has invalid count or offset", index)
+	}
+	if acc.Count > maxGltfAccessorSize/elemSize {
+		return nil, 0, nil, fmt.Errorf("accessor %d has too many elements (%d)", index, acc.Count)
+	}
+	var view []byte
+	var stride int
+	if acc.BufferView != nil {
+		var err error
+		view, stride, err = l.bufferView(*acc.BufferView)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("accessor %d: %v", index, err)
+		}
+		if stride == 0 {
+			stride = columns * columnStride
+		}
+		extent := (columns-1)*columnStride + columnSize
+		if acc.Count > 0 && (acc.ByteOffset > len(view)-extent ||
+			acc.Count-1 > (len(view)-extent-acc.ByteOffset)/stride) {
+			return nil, 0, nil, fmt.Errorf("accessor %d is out of range of buffer view %d", index, *acc.BufferView)
+		}
+	}
+
+	dense := make([]byte, acc.Count*elemSize)
+	if view != nil {
+		for e := 0; e < acc.Count; e++ {
+			for c := 0; c < columns; c++ {
+				start := acc.ByteOffset + e*stride + c*columnStride
+				if start < 0 || start+columnSize > len(view) {
+					return nil, 0, nil, fmt.Errorf("accessor %d is out of range of buffer view %d", index, *acc.BufferView)
+				}
+				copy(dense[e*elemSize+c*columnSize:], view[start:start+columnSize])
+			}
+		}
+	}
+
+	if s := acc.Sparse; s != nil {
+		indexView, _, err := l.bufferView(s.Indices.BufferView)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("sparse accessor %d: %v", index, err)
+		}
+		valueView, _, err := l.bufferView(s.Values.BufferView)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("sparse accessor %d: %v", index, err)
+		}
+		isize := gltfComponentSize(s.Indices.ComponentType)
+		if s.Count < 0 || s.Count > acc.Count || s.Indices.ByteOffset < 0 || s.Values.ByteOffset < 0 {
+			return nil, 0, nil, fmt.Errorf("sparse accessor %d has invalid count or offset", index)
+		}
+		if isize == 0 || s.Indices.ComponentType == gltfFloat ||
+			s.Indices.ByteOffset > len(indexView) || s.Count*isize > len(indexView)-s.Indices.ByteOffset ||
+			s.Values.ByteOffset > len(valueView) || s.Count*elemSize > len(valueView)-s.Values.ByteOffset {
+			return nil, 0, nil, fmt.Errorf("sparse accessor %d is out of range", index)
+		}
+		indices := readGltfUints(indexView[s.Indices.ByteOffset:], s.Indices.ComponentType, s.Count)
+		values := valueView[s.Values.ByteOffset:]
+		for i, target := range indices {
+			if int(target) >= acc.Count {
+				return nil, 0, nil, fmt.Errorf("sparse accessor %d has invalid index %d", index, target)
+			}
+			copy(dense[int(target)*elemSize:(int(target)+1)*elemSize], values[i*elemSize:])
+		}
+	}
+	return acc, comps, dense, nil
+}
+
+// Convert tightly packed components to unsigned integers
+func readGltfUints(data []byte, componentType int, count int) []uint32 {
+	values := make([]uint32, count)
+	for i := range values {
+		switch componentType {
+		case gltfByte, gltfUnsignedByte:
+			values[i] = uint32(data[i])
+		case gltfShort, gltfUnsignedShort:
+			values[i] = uint32(binary.LittleEndian.Uint16(data[i*2:]))
+		case gltfUnsignedInt:
+			values[i] = binary.LittleEndian.Uint32(data[i*4:])
+		case gltfFloat:
+			values[i] = uint32(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+		}
+	}
+	return values
+}
+
+// Convert tightly packed components to floats
+func readGltfFloats(data []byte, componentType int, normalized bool, count int) []float32 {
+	values := make([]float32, count)
+	for i := range values {
+		var v float32
+		switch componentType {
+		case gltfByte:
+			v = float32(int8(data[i]))
+			if normalized {
+				v = float32(math.Max(float64(v)/127, -1))
+			}
+		case gltfUnsignedByte:
+			v = float32(data[i])
+			if normalized {
+				v /= 255
+			}
+		case gltfShort:
+			v = float32(int16(binary.LittleEndian.Uint16(data[i*2:])))
+			if normalized {
+				v = float32(math.Max(float64(v)/32767, -1))
+			}
+		case gltfUnsignedShort:
+			v = float32(binary.LittleEndian.Uint16(data[i*2:]))
+			if normalized {
+				v /= 65535
+			}
+		case gltfUnsignedInt:
+			v = float32(binary.LittleEndian.Uint32(data[i*4:]))
+		case gltfFloat:
+			v = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		values[i] = v
+	}
+	return values
+}
+
+// Read accessor as floats, check number of components if wanted is not 0
+func (l *gltfLoader) readFloats(index int, wanted ...int) ([]float32, int, error) {
+	acc, comps, data, err := l.accessorData(index)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(wanted) > 0 {
+		ok := false
+		for _, w := range wanted {
+			ok = ok || w == comps
+		}
+		if !ok {
+			return nil, 0, fmt.Errorf("accessor %d has unexpected type %s", index, acc.Type)
+		}
+	}
+	return readGltfFloats(data, acc.ComponentType, acc.Normalized, len(data)/gltfComponentSize(acc.ComponentType)), comps, nil
+}
+
+// Read accessor as unsigned integers
+func (l *gltfLoader) readUints(index int) ([]uint32, int, error) {
+	acc, comps, data, err := l.accessorData(index)
+	if err != nil {
+		return nil, 0, err
+	}
+	if acc.ComponentType == gltfFloat || acc.ComponentType == gltfByte || acc.ComponentType == gltfShort {
+		return nil, 0, fmt.Errorf("accessor %d isn't unsigned integer", index)
+	}
+	return readGltfUints(data, acc.ComponentType, len(data)/gltfComponentSize(acc.ComponentType)), comps, nil
+}
+
+func (l *gltfLoader) readVec2s(index int) ([]mgl32.Vec2, error) {
+	values, _, err := l.readFloats(index, 2)
+	if err != nil {
+		return nil, err
+	}
+	vs := make([]mgl32.Vec2, len(values)/2)
+	for i := range vs {
+		vs[i] = mgl32.Vec2{values[i*2], values[i*2+1]}
+	}
+	return vs, nil
+}
+
+func (l *gltfLoader) readVec3s(index int) ([]mgl32.Vec3, error) {
+	values, _, err := l.readFloats(index, 3)
+	if err != nil {
+		return nil, err
+	}
+	vs := make([]mgl32.Vec3, len(values)/3)
+	for i := range vs {
+		vs[i] = mgl32.Vec3{values[i*3], values[i*3+1], values[i*3+2]}
+	}
+	return vs, nil
+}
+
+// Read VEC3 or VEC4 accessor as Vec4, missing component is filled with 1
+func (l *gltfLoader) readVec4s(index int) ([]mgl32.Vec4, error) {
+	values, comps, err := l.readFloats(index, 3, 4)
+	if err != nil {
+		return nil, err
+	}
+	vs := make([]mgl32.Vec4, len(values)/comps)
+	for i := range vs {
+		vs[i] = mgl32.Vec4{1, 1, 1, 1}
+		copy(vs[i][:], values[i*comps:(i+1)*comps])
+	}
+	return vs, nil
+}
+
+func (l *gltfLoader) loadImages(m *GltfModel) error {
+	m.Images = make([]image.Image, len(l.doc.Images))
+	for i, img := range l.doc.Images {
+		var (
+			data []byte
+			err  error
+		)
+		if img.BufferView != nil {
+			data, _, err = l.bufferView(*img.BufferView)
+		} else {
+			data, err = l.readURI(img.URI)
+		}
+		if err != nil {
+			return fmt.Errorf("load image %d failed: %v", i, err)
+		}
+		if m.Images[i], _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("decode image %d failed: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadTextures(m *GltfModel) error {
+	m.Textures = make([]*GltfTexture, len(l.doc.Textures))
+	for i, t := range l.doc.Textures {
+		if t.Source == nil || *t.Source < 0 || *t.Source >= len(m.Images) {
+			return fmt.Errorf("texture %d has invalid image", i)
+		}
+		tex := &GltfTexture{
+			Image:     *t.Source,
+			MagFilter: gl.LINEAR,
+			MinFilter: gl.LINEAR_MIPMAP_LINEAR,
+			WrapS:     gl.REPEAT,
+			WrapT:     gl.REPEAT,
+		}
+		if t.Sampler != nil {
+			if *t.Sampler < 0 || *t.Sampler >= len(l.doc.Samplers) {
+				return fmt.Errorf("texture %d has invalid sampler", i)
+			}
+			s := l.doc.Samplers[*t.Sampler]
+			if s.MagFilter != 0 {
+				tex.MagFilter = s.MagFilter
+			}
+			if s.MinFilter != 0 {
+				tex.MinFilter = s.MinFilter
+			}
+			if s.WrapS != 0 {
+				tex.WrapS = s.WrapS
+			}
+			if s.WrapT != 0 {
+				tex.WrapT = s.WrapT
+			}
+		}
+		m.Textures[i] = tex
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadMaterials(m *GltfModel) error {
+	textureRef := func(ref *gltfTextureRef) (GltfTextureRef, error) {
+		r := GltfTextureRef{Index: -1, Scale: 1}
+		if ref == nil {
+			return r, nil
+		}
+		if ref.Index < 0 || ref.Index >= len(m.Textures) {
+			return r, fmt.Errorf("texture %d doesn't exist", ref.Index)
+		}
+		r.Index = ref.Index
+		r.TexCoord = ref.TexCoord
+		if ref.Scale != nil {
+			r.Scale = *ref.Scale
+		}
+		if ref.Strength != nil {
+			r.Scale = *ref.Strength
+		}
+		return r, nil
+	}
+
+	m.Materials = make([]*GltfMaterial, len(l.doc.Materials))
+	for i, mat := range l.doc.Materials {
+		gm := &GltfMaterial{
+			Name:            mat.Name,
+			BaseColorFactor: mgl32.Vec4{1, 1, 1, 1},
+			MetallicFactor:  1,
+			RoughnessFactor: 1,
+			AlphaMode:       "OPAQUE",
+			AlphaCutoff:     0.5,
+			DoubleSided:     mat.DoubleSided,
+		}
+		var errs [5]error
+		if pbr := mat.PbrMetallicRoughness; pbr != nil {
+			if len(pbr.BaseColorFactor) == 4 {
+				copy(gm.BaseColorFactor[:], pbr.BaseColorFactor)
+			}
+			if pbr.MetallicFactor != nil {
+				gm.MetallicFactor = *pbr.MetallicFactor
+			}
+			if pbr.RoughnessFactor != nil {
+				gm.RoughnessFactor = *pbr.RoughnessFactor
+			}
+			gm.BaseColorTexture, errs[0] = textureRef(pbr.BaseColorTexture)
+			gm.MetallicRoughnessTexture, errs[1] = textureRef(pbr.MetallicRoughnessTexture)
+		} else {
+			gm.BaseColorTexture, _ = textureRef(nil)
+			gm.MetallicRoughnessTexture, _ = textureRef(nil)
+		}
+		gm.NormalTexture, errs[2] = textureRef(mat.NormalTexture)
+		gm.OcclusionTexture, errs[3] = textureRef(mat.OcclusionTexture)
+		gm.EmissiveTexture, errs[4] = textureRef(mat.EmissiveTexture)
+		for _, err := range errs {
+			if err != nil {
+				return fmt.Errorf("material %d: %v", i, err)
+			}
+		}
+		if len(mat.EmissiveFactor) == 3 {
+			copy(gm.EmissiveFactor[:], mat.EmissiveFactor)
+		}
+		if ext := mat.Extensions.EmissiveStrength; ext != nil && ext.EmissiveStrength != nil {
+			gm.EmissiveFactor = gm.EmissiveFactor.Mul(*ext.EmissiveStrength)
+		}
+		if mat.AlphaMode != "" {
+			gm.AlphaMode = mat.AlphaMode
+		}
+		if mat.AlphaCutoff != nil {
+			gm.AlphaCutoff = *mat.AlphaCutoff
+		}
+		m.Materials[i] = gm
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadMeshes(m *GltfModel) error {
+	m.Meshes = make([]*GltfMesh, len(l.doc.Meshes))
+	for i, mesh := range l.doc.Meshes {
+		gm := &GltfMesh{
+			Name:       mesh.Name,
+			Primitives: make([]*GltfPrimitive, len(mesh.Primitives)),
+			Weights:    mesh.Weights,
+		}
+		for j, prim := range mesh.Primitives {
+			p, err := l.loadPrimitive(m, prim.Attributes, prim.Indices, prim.Material, prim.Mode, prim.Targets)
+			if err != nil {
+				return fmt.Errorf("mesh %d primitive %d: %v", i, j, err)
+			}
+			gm.Primitives[j] = p
+		}
+		m.Meshes[i] = gm
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadPrimitive(m *GltfModel, attributes map[string]int, indices *int,
+	material *int, mode *uint32, targets []map[string]int) (*GltfPrimitive, error) {
+	p := &GltfPrimitive{
+		Mode:     gl.TRIANGLES,
+		Material: -1,
+	}
+	if mode != nil {
+		if *mode > gl.TRIANGLE_FAN {
+			return nil, fmt.Errorf("invalid mode %d", *mode)
+		}
+		p.Mode = *mode
+	}
+	if material != nil {
+		if *material < 0 || *material >= len(m.Materials) {
+			return nil, fmt.Errorf("material %d doesn't exist", *material)
+		}
+		p.Material = *material
+	}
+
+	position, ok := attributes["POSITION"]
+	if !ok {
+		return nil, fmt.Errorf("missing POSITION attribute")
+	}
+
+	var err error
+	for name, index := range attributes {
+		switch name {
+		case "POSITION":
+			p.Data.Positions, err = l.readVec3s(position)
+		case "NORMAL":
+			p.Data.Normals, err = l.readVec3s(index)
+		case "TANGENT":
+			p.Data.Tangents, err = l.readVec4s(index)
+		case "TEXCOORD_0":
+			p.Data.TexCoords, err = l.readVec2s(index)
+		case "TEXCOORD_1":
+			p.TexCoords1, err = l.readVec2s(index)
+		case "COLOR_0":
+			p.Data.Colors, err = l.readVec4s(index)
+		case "JOINTS_0":
+			var (
+				joints []uint32
+				comps  int
+			)
+			if joints, comps, err = l.readUints(index); err == nil && comps != 4 {
+				err = fmt.Errorf("expect VEC4 type")
+			}
+			if err == nil {
+				p.Joints = make([][4]uint16, len(joints)/4)
+				for i := range p.Joints {
+					for j := 0; j < 4; j++ {
+						p.Joints[i][j] = uint16(joints[i*4+j])
+					}
+				}
+			}
+		case "WEIGHTS_0":
+			var weights []float32
+			if weights, _, err = l.readFloats(index, 4); err == nil {
+				p.Weights = make([]mgl32.Vec4, len(weights)/4)
+				for i := range p.Weights {
+					copy(p.Weights[i][:], weights[i*4:])
+				}
+			}
+		default:
+			var (
+				values []float32
+				comps  int
+			)
+			if values, comps, err = l.readFloats(index); err == nil {
+				if p.Attributes == nil {
+					p.Attributes = map[string]GltfAttribute{}
+				}
+				p.Attributes[name] = GltfAttribute{Components: comps, Values: values}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %v", name, err)
+		}
+	}
+
+	count := len(p.Data.Positions)
+	lengths := []int{len(p.Data.Normals), len(p.Data.Tangents), len(p.Data.TexCoords),
+		len(p.Data.Colors), len(p.TexCoords1), len(p.Joints), len(p.Weights)}
+	for _, n := range lengths {
+		if n != 0 && n != count {
+			return nil, fmt.Errorf("attribute count %d mismatches with position count %d", n, count)
+		}
+	}
+
+	if indices != nil {
+		if p.Data.Indices, _, err = l.readUints(*indices); err != nil {
+			return nil, fmt.Errorf("indices: %v", err)
+		}
+		for _, idx := range p.Data.Indices {
+			if int(idx) >= count {
+				return nil, fmt.Errorf("index %d out of range", idx)
+			}
+		}
+	}
+
+	for i, target := range targets {
+		var t GltfMorphTarget
+		for name, index := range target {
+			switch name {
+			case "POSITION":
+				t.Positions, err = l.readVec3s(index)
+			case "NORMAL":
+				t.Normals, err = l.readVec3s(index)
+			case "TANGENT":
+				t.Tangents, err = l.readVec3s(index)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("morph target %d attribute %s: %v", i, name, err)
+			}
+		}
+		p.Targets = append(p.Targets, t)
+	}
+	return p, nil
+}
+
+func (l *gltfLoader) loadNodes(m *GltfModel) error {
+	m.Nodes = make([]*GltfNode, len(l.doc.Nodes))
+	for i, node := range l.doc.Nodes {
+		n := &GltfNode{
+			Name:     node.Name,
+			Parent:   -1,
+			Children: node.Children,
+			Mesh:     -1,
+			Skin:     -1,
+			Camera:   -1,
+			Rotation: mgl32.QuatIdent(),
+			Scale:    mgl32.Vec3{1, 1, 1},
+			Weights:  node.Weights,
+		}
+		if node.Mesh != nil {
+			if *node.Mesh < 0 || *node.Mesh >= len(m.Meshes) {
+				return fmt.Errorf("node %d has invalid mesh %d", i, *node.Mesh)
+			}
+			n.Mesh = *node.Mesh
+			if n.Weights == nil {
+				n.Weights = append([]float32(nil), m.Meshes[n.Mesh].Weights...)
+			}
+		}
+		if node.Skin != nil {
+			if *node.Skin < 0 || *node.Skin >= len(l.doc.Skins) {
+				return fmt.Errorf("node %d has invalid skin %d", i, *node.Skin)
+			}
+			n.Skin = *node.Skin
+		}
+		if node.Camera != nil {
+			if *node.Camera < 0 || *node.Camera >= len(l.doc.Cameras) {
+				return fmt.Errorf("node %d has invalid camera %d", i, *node.Camera)
+			}
+			n.Camera = *node.Camera
+		}
+
+		if len(node.Matrix) == 16 {
+			var mat mgl32.Mat4
+			copy(mat[:], node.Matrix)
+			n.Translation, n.Rotation, n.Scale = decomposeMatrix(mat)
+		}
+		if len(node.Translation) == 3 {
+			copy(n.Translation[:], node.Translation)
+		}
+		if len(node.Rotation) == 4 {
+			n.Rotation = mgl32.Quat{W: node.Rotation[3], V: mgl32.Vec3{node.Rotation[0], node.Rotation[1], node.Rotation[2]}}
+		}
+		if len(node.Scale) == 3 {
+			copy(n.Scale[:], node.Scale)
+		}
+		m.Nodes[i] = n
+	}
+
+	for i, n := range m.Nodes {
+		for _, c := range n.Children {
+			if c < 0 || c >= len(m.Nodes) || c == i || m.Nodes[c].Parent >= 0 {
+				return fmt.Errorf("node %d has invalid child %d", i, c)
+			}
+			m.Nodes[c].Parent = i
+		}
+	}
+
+	// Detect cycles, which aren't allowed
+	for i := range m.Nodes {
+		steps := 0
+		for p := m.Nodes[i].Parent; p >= 0; p = m.Nodes[p].Parent {
+			if steps++; steps > len(m.Nodes) {
+				return fmt.Errorf("node %d is part of a cycle", i)
+			}
+		}
+	}
+
+	m.Scenes = make([]*GltfScene, len(l.doc.Scenes))
+	for i, s := range l.doc.Scenes {
+		for _, n := range s.Nodes {
+			if n < 0 || n >= len(m.Nodes) {
+				return fmt.Errorf("scene %d has invalid node %d", i, n)
+			}
+		}
+		m.Scenes[i] = &GltfScene{Name: s.Name, Nodes: s.Nodes}
+	}
+	if l.doc.Scene != nil {
+		if *l.doc.Scene < 0 || *l.doc.Scene >= len(m.Scenes) {
+			return fmt.Errorf("default scene %d doesn't exist", *l.doc.Scene)
+		}
+		m.Scene = *l.doc.Scene
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadSkins(m *GltfModel) error {
+	m.Skins = make([]*GltfSkin, len(l.doc.Skins))
+	for i, skin := range l.doc.Skins {
+		s := &GltfSkin{
+			Name:     skin.Name,
+			Joints:   skin.Joints,
+			Skeleton: -1,
+		}
+		for _, j := range skin.Joints {
+			if j < 0 || j >= len(m.Nodes) {
+				return fmt.Errorf("skin %d has invalid joint %d", i, j)
+			}
+		}
+		if skin.Skeleton != nil {
+			if *skin.Skeleton < 0 || *skin.Skeleton >= len(m.Nodes) {
+				return fmt.Errorf("skin %d has invalid skeleton %d", i, *skin.Skeleton)
+			}
+			s.Skeleton = *skin.Skeleton
+		}
+		if skin.InverseBindMatrices != nil {
+			values, _, err := l.readFloats(*skin.InverseBindMatrices, 16)
+			if err != nil {
+				return fmt.Errorf("skin %d: %v", i, err)
+			}
+			if len(values)/16 < len(skin.Joints) {
+				return fmt.Errorf("skin %d has less inverse bind matrices than joints", i)
+			}
+			s.InverseBindMatrices = make([]mgl32.Mat4, len(values)/16)
+			for j := range s.InverseBindMatrices {
+				copy(s.InverseBindMatrices[j][:], values[j*16:])
+			}
+		} else {
+			s.InverseBindMatrices = make([]mgl32.Mat4, len(skin.Joints))
+			for j := range s.InverseBindMatrices {
+				s.InverseBindMatrices[j] = mgl32.Ident4()
+			}
+		}
+		m.Skins[i] = s
+	}
+
+	for i, n := range m.Nodes {
+		if n.Skin < -1 || n.Skin >= len(m.Skins) {
+			return fmt.Errorf("node %d has invalid skin %d", i, n.Skin)
+		}
+	}
+	return nil
+}
+
+func (l *gltfLoader) loadAnimations(m *GltfModel) error {
+	m.Animations = make([]*GltfAnimation, len(l.doc.Animations))
+	for i, anim := range l.doc.Animations {
+		a := &GltfAnimation{
+			Name:     anim.Name,
+			Samplers: make([]*GltfAnimationSampler, len(anim.Samplers)),
+		}
+		for j, s := range anim.Samplers {
+			input, _, err := l.readFloats(s.Input, 1)
+			if err != nil {
+				return fmt.Errorf("animation %d sampler %d input: %v", i, j, err)
+			}
+			output, comps, err := l.readFloats(s.Output)
+			if err != nil {
+				return fmt.Errorf("animation %d sampler %d output: %v", i, j, err)
+			}
+			interpolation := s.Interpolation
+			if interpolation == "" {
+				interpolation = GltfInterpolationLinear
+			}
+			a.Samplers[j] = &GltfAnimationSampler{
+				Input:         input,
+				Output:        output,
+				Components:    comps,
+				Interpolation: interpolation,
+			}
+		}
+
+		for j, c := range anim.Channels {
+			if c.Target.Node == nil {
+				// Targets defined by extensions aren't supported
+				continue
+			}
+			if *c.Target.Node < 0 || *c.Target.Node >= len(m.Nodes) {
+				return fmt.Errorf("animation %d channel %d has invalid node", i, j)
+			}
+			if c.Sampler < 0 || c.Sampler >= len(a.Samplers) {
+				return fmt.Errorf("animation %d channel %d has invalid sampler", i, j)
+			}
+			s := a.Samplers[c.Sampler]
+			keys := len(s.Input)
+			if s.Interpolation == GltfInterpolationCubicSpline {
+				keys *= 3
+			}
+			switch c.Target.Path {
+			case GltfPathTranslation, GltfPathScale:
+				if s.Components != 3 {
+					return fmt.Errorf("animation %d channel %d expects VEC3 output", i, j)
+				}
+			case GltfPathRotation:
+				if s.Components != 4 {
+					return fmt.Errorf("animation %d channel %d expects VEC4 output", i, j)
+				}
+			case GltfPathWeights:
+				// Output is scalar, each keyframe has one weight per morph target
+				if keys > 0 {
+					s.Components = len(s.Output) / keys
+				}
+			default:
+				return fmt.Errorf("animation %d channel %d has invalid path %q", i, j, c.Target.Path)
+			}
+			if keys*s.Components != len(s.Output) || s.Components == 0 {
+				return fmt.Errorf("animation %d sampler %d has mismatched input and output", i, c.Sampler)
+			}
+			a.Channels = append(a.Channels, GltfAnimationChannel{
+				Sampler: c.Sampler,
+				Node:    *c.Target.Node,
+				Path:    c.Target.Path,
+			})
+		}
+		m.Animations[i] = a
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package gfx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Buffer of test triangle: positions, indices, index and value of sparse
+// accessor moving second vertex
+func gltfTestBuffer() []byte {
+	var buf bytes.Buffer
+	for _, f := range []float32{0, 0, 0, 1, 0, 0, 0, 1, 0} {
+		binary.Write(&buf, binary.LittleEndian, math.Float32bits(f))
+	}
+	binary.Write(&buf, binary.LittleEndian, []uint16{0, 1, 2, 0})
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 0})
+	for _, f := range []float32{2, 0, 0} {
+		binary.Write(&buf, binary.LittleEndian, math.Float32bits(f))
+	}
+	return buf.Bytes()
+}
+
+// Document of triangle mesh in node, buffer is embedded unless uri is false
+func gltfTestDoc(uri bool) map[string]interface{} {
+	data := gltfTestBuffer()
+	buffer := map[string]interface{}{"byteLength": len(data)}
+	if uri {
+		buffer["uri"] = "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return map[string]interface{}{
+		"asset":   map[string]interface{}{"version": "2.0"},
+		"buffers": []interface{}{buffer},
+		"bufferViews": []interface{}{
+			map[string]interface{}{"buffer": 0, "byteOffset": 0, "byteLength": 36},
+			map[string]interface{}{"buffer": 0, "byteOffset": 36, "byteLength": 6},
+			map[string]interface{}{"buffer": 0, "byteOffset": 44, "byteLength": 2},
+			map[string]interface{}{"buffer": 0, "byteOffset": 48, "byteLength": 12},
+		},
+		"accessors": []interface{}{
+			map[string]interface{}{"bufferView": 0, "componentType": gltfFloat, "count": 3, "type": "VEC3"},
+			map[string]interface{}{"bufferView": 1, "componentType": gltfUnsignedShort, "count": 3, "type": "SCALAR"},
+		},
+		"meshes": []interface{}{
+			map[string]interface{}{"primitives": []interface{}{
+				map[string]interface{}{"attributes": map[string]interface{}{"POSITION": 0}, "indices": 1},
+			}},
+		},
+		"nodes": []interface{}{
+			map[string]interface{}{"name": "root", "children": []interface{}{1}},
+			map[string]interface{}{"name": "triangle", "mesh": 0},
+		},
+		"scenes": []interface{}{map[string]interface{}{"nodes": []interface{}{0}}},
+		"scene":  0,
+	}
+}
+
+// Get i-th element of array of document as object
+func gltfTestItem(doc map[string]interface{}, array string, i int) map[string]interface{} {
+	return doc[array].([]interface{})[i].(map[string]interface{})
+}
+
+// Sparse accessor moving second vertex of accessor to (2, 0, 0)
+func gltfTestSparse() map[string]interface{} {
+	return map[string]interface{}{
+		"count":   1,
+		"indices": map[string]interface{}{"bufferView": 2, "componentType": gltfUnsignedShort},
+		"values":  map[string]interface{}{"bufferView": 3},
+	}
+}
+
+func encodeGltfTest(t *testing.T, doc map[string]interface{}) []byte {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Pack document and buffer into GLB container
+func encodeGlbTest(t *testing.T, doc map[string]interface{}, bin []byte) []byte {
+	jsonChunk := encodeGltfTest(t, doc)
+	for len(jsonChunk)%4 != 0 {
+		jsonChunk = append(jsonChunk, ' ')
+	}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("glTF")
+	binary.Write(&buf, binary.LittleEndian, []uint32{2, uint32(12 + 8 + len(jsonChunk) + 8 + len(bin))})
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(len(jsonChunk)), 0x4E4F534A})
+	buf.Write(jsonChunk)
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(len(bin)), 0x004E4942})
+	buf.Write(bin)
+	return buf.Bytes()
+}
+
+func TestReadGltf(t *testing.T) {
+	tests := []struct {
+		name      string
+		edit      func(doc map[string]interface{})
+		positions []mgl32.Vec3
+		indices   []uint32
+	}{
+		{
+			name:      "indexed triangle",
+			positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+			indices:   []uint32{0, 1, 2},
+		},
+		{
+			name: "non-indexed triangle",
+			edit: func(doc map[string]interface{}) {
+				delete(gltfTestItem(doc, "meshes", 0)["primitives"].([]interface{})[0].(map[string]interface{}), "indices")
+			},
+			positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		},
+		{
+			name: "sparse accessor",
+			edit: func(doc map[string]interface{}) {
+				gltfTestItem(doc, "accessors", 0)["sparse"] = gltfTestSparse()
+			},
+			positions: []mgl32.Vec3{{0, 0, 0}, {2, 0, 0}, {0, 1, 0}},
+			indices:   []uint32{0, 1, 2},
+		},
+		{
+			name: "sparse accessor without buffer view",
+			edit: func(doc map[string]interface{}) {
+				acc := gltfTestItem(doc, "accessors", 0)
+				delete(acc, "bufferView")
+				acc["sparse"] = gltfTestSparse()
+			},
+			positions: []mgl32.Vec3{{0, 0, 0}, {2, 0, 0}, {0, 0, 0}},
+			indices:   []uint32{0, 1, 2},
+		},
+		{
+			name: "interleaved positions",
+			edit: func(doc map[string]interface{}) {
+				// Every other vertex of 12-byte elements with 24-byte stride
+				view := gltfTestItem(doc, "bufferViews", 0)
+				view["byteStride"] = 24
+				acc := gltfTestItem(doc, "accessors", 0)
+				acc["count"] = 2
+				delete(gltfTestItem(doc, "meshes", 0)["primitives"].([]interface{})[0].(map[string]interface{}), "indices")
+			},
+			positions: []mgl32.Vec3{{0, 0, 0}, {0, 1, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := gltfTestDoc(true)
+			if tt.edit != nil {
+				tt.edit(doc)
+			}
+			m, err := ReadGltf(bytes.NewReader(encodeGltfTest(t, doc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := m.Meshes[0].Primitives[0].Data
+			if len(d.Positions) != len(tt.positions) {
+				t.Fatalf("got positions %v, want %v", d.Positions, tt.positions)
+			}
+			for i := range d.Positions {
+				if d.Positions[i] != tt.positions[i] {
+					t.Fatalf("got positions %v, want %v", d.Positions, tt.positions)
+				}
+			}
+			if !equalIndices(d.Indices, tt.indices) {
+				t.Errorf("got indices %v, want %v", d.Indices, tt.indices)
+			}
+		})
+	}
+}
+
+func TestReadGltfNodes(t *testing.T) {
+	doc := gltfTestDoc(true)
+	doc["cameras"] = []interface{}{map[string]interface{}{"type": "perspective"}}
+	doc["skins"] = []interface{}{map[string]interface{}{"joints": []interface{}{0, 1}, "skeleton": 0}}
+	triangle := gltfTestItem(doc, "nodes", 1)
+	triangle["skin"] = 0
+	triangle["camera"] = 0
+	triangle["translation"] = []float32{1, 2, 3}
+	m, err := ReadGltf(bytes.NewReader(encodeGltfTest(t, doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Nodes) != 2 || m.Nodes[0].Parent != -1 || m.Nodes[1].Parent != 0 {
+		t.Fatalf("got nodes %+v, want triangle under root", m.Nodes)
+	}
+	n := m.Nodes[1]
+	if n.Mesh != 0 || n.Skin != 0 || n.Camera != 0 {
+		t.Errorf("got mesh %d, skin %d and camera %d", n.Mesh, n.Skin, n.Camera)
+	}
+	if world := m.WorldMatrices()[1]; world.Col(3) != (mgl32.Vec4{1, 2, 3, 1}) {
+		t.Errorf("got world matrix %v", world)
+	}
+	if s := m.Skins[0]; s.Skeleton != 0 || len(s.InverseBindMatrices) != 2 {
+		t.Errorf("got skin %+v", s)
+	}
+}
+
+func TestReadGltfMaterial(t *testing.T) {
+	doc := gltfTestDoc(true)
+	doc["extensionsRequired"] = []interface{}{"KHR_materials_emissive_strength"}
+	doc["materials"] = []interface{}{map[string]interface{}{
+		"emissiveFactor": []float32{1, 0.5, 0},
+		"extensions": map[string]interface{}{
+			"KHR_materials_emissive_strength": map[string]interface{}{"emissiveStrength": 4},
+		},
+	}}
+	m, err := ReadGltf(bytes.NewReader(encodeGltfTest(t, doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mat := m.Materials[0]
+	if mat.EmissiveFactor != (mgl32.Vec3{4, 2, 0}) {
+		t.Errorf("got emissive factor %v, want it scaled by strength", mat.EmissiveFactor)
+	}
+	if mat.BaseColorFactor != (mgl32.Vec4{1, 1, 1, 1}) || mat.AlphaMode != "OPAQUE" || mat.BaseColorTexture.Index != -1 {
+		t.Errorf("got material %+v, want defaults", mat)
+	}
+}
+
+func TestReadGlb(t *testing.T) {
+	data := encodeGlbTest(t, gltfTestDoc(false), gltfTestBuffer())
+	m, err := ReadGltf(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := m.Meshes[0].Primitives[0].Data; len(d.Positions) != 3 || !equalIndices(d.Indices, []uint32{0, 1, 2}) {
+		t.Errorf("got positions %v and indices %v", d.Positions, d.Indices)
+	}
+
+	truncated := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"no chunks", []byte("glTF\x02\x00\x00\x00\x0c\x00\x00\x00"), "GLB has no JSON chunk"},
+		{"chunk cut", data[:len(data)-4], "GLB is truncated"},
+		{"wrong version", append([]byte("glTF\x01\x00\x00\x00"), data[8:]...), "unsupported GLB version"},
+	}
+	for _, tt := range truncated {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadGltf(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadGltfErrors(t *testing.T) {
+	primitive := func(doc map[string]interface{}) map[string]interface{} {
+		return gltfTestItem(doc, "meshes", 0)["primitives"].([]interface{})[0].(map[string]interface{})
+	}
+	tests := []struct {
+		name string
+		edit func(doc map[string]interface{})
+		err  string
+	}{
+		{"unsupported version", func(doc map[string]interface{}) {
+			doc["asset"] = map[string]interface{}{"version": "1.0"}
+		}, "unsupported glTF version"},
+		{"unsupported extension", func(doc map[string]interface{}) {
+			doc["extensionsRequired"] = []interface{}{"KHR_draco_mesh_compression"}
+		}, "isn't supported"},
+		{"truncated buffer", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "buffers", 0)["byteLength"] = 1000
+		}, "buffer 0 is truncated"},
+		{"external buffer", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "buffers", 0)["uri"] = "triangle.bin"
+		}, "isn't allowed"},
+		{"buffer view out of buffer", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "bufferViews", 0)["byteOffset"] = 40
+		}, "out of range of buffer"},
+		{"negative buffer view length", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "bufferViews", 0)["byteLength"] = -4
+		}, "out of range of buffer"},
+		{"negative stride", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "bufferViews", 0)["byteStride"] = -12
+		}, "invalid stride"},
+		{"negative count", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["count"] = -1
+		}, "invalid count or offset"},
+		{"negative offset", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["byteOffset"] = -12
+		}, "invalid count or offset"},
+		{"huge count", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["count"] = 1 << 40
+		}, "too many elements"},
+		{"accessor out of view", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["count"] = 4
+		}, "out of range of buffer view"},
+		{"accessor offset out of view", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["byteOffset"] = 28
+		}, "out of range of buffer view"},
+		{"invalid accessor type", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["type"] = "VEC5"
+		}, "invalid type"},
+		{"missing accessor", func(doc map[string]interface{}) {
+			primitive(doc)["indices"] = 7
+		}, "accessor 7 doesn't exist"},
+		{"index out of range", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["count"] = 2
+			gltfTestItem(doc, "bufferViews", 0)["byteLength"] = 24
+		}, "index 2 out of range"},
+		{"float indices", func(doc map[string]interface{}) {
+			primitive(doc)["indices"] = 0
+		}, "isn't unsigned integer"},
+		{"missing position", func(doc map[string]interface{}) {
+			primitive(doc)["attributes"] = map[string]interface{}{"NORMAL": 0}
+		}, "missing POSITION"},
+		{"attribute count mismatch", func(doc map[string]interface{}) {
+			doc["accessors"] = append(doc["accessors"].([]interface{}),
+				map[string]interface{}{"bufferView": 0, "componentType": gltfFloat, "count": 2, "type": "VEC3"})
+			primitive(doc)["attributes"].(map[string]interface{})["NORMAL"] = 2
+		}, "mismatches with position count"},
+		{"sparse count above count", func(doc map[string]interface{}) {
+			s := gltfTestSparse()
+			s["count"] = 4
+			gltfTestItem(doc, "accessors", 0)["sparse"] = s
+		}, "invalid count or offset"},
+		{"negative sparse count", func(doc map[string]interface{}) {
+			s := gltfTestSparse()
+			s["count"] = -1
+			gltfTestItem(doc, "accessors", 0)["sparse"] = s
+		}, "invalid count or offset"},
+		{"sparse index out of range", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "accessors", 0)["count"] = 1
+			gltfTestItem(doc, "accessors", 0)["sparse"] = gltfTestSparse()
+		}, "invalid index 1"},
+		{"sparse values out of view", func(doc map[string]interface{}) {
+			s := gltfTestSparse()
+			s["values"] = map[string]interface{}{"bufferView": 3, "byteOffset": 4}
+			gltfTestItem(doc, "accessors", 0)["sparse"] = s
+		}, "out of range"},
+		{"sparse float indices", func(doc map[string]interface{}) {
+			s := gltfTestSparse()
+			s["indices"] = map[string]interface{}{"bufferView": 2, "componentType": gltfFloat}
+			gltfTestItem(doc, "accessors", 0)["sparse"] = s
+		}, "out of range"},
+		{"node mesh out of range", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "nodes", 1)["mesh"] = 1
+		}, "invalid mesh 1"},
+		{"negative skin", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "nodes", 1)["skin"] = -1
+		}, "invalid skin -1"},
+		{"skin out of range", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "nodes", 1)["skin"] = 0
+		}, "invalid skin 0"},
+		{"camera out of range", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "nodes", 1)["camera"] = 0
+		}, "invalid camera 0"},
+		{"skeleton out of range", func(doc map[string]interface{}) {
+			doc["skins"] = []interface{}{map[string]interface{}{"joints": []interface{}{0}, "skeleton": 2}}
+		}, "invalid skeleton 2"},
+		{"joint out of range", func(doc map[string]interface{}) {
+			doc["skins"] = []interface{}{map[string]interface{}{"joints": []interface{}{-1}}}
+		}, "invalid joint -1"},
+		{"child out of range", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "nodes", 1)["children"] = []interface{}{2}
+		}, "invalid child 2"},
+		{"cycle", func(doc map[string]interface{}) {
+			gltfTestItem(doc, "nodes", 1)["children"] = []interface{}{0}
+		}, "cycle"},
+		{"scene node out of range", func(doc map[string]interface{}) {
+			doc["scenes"] = []interface{}{map[string]interface{}{"nodes": []interface{}{5}}}
+		}, "invalid node 5"},
+		{"default scene out of range", func(doc map[string]interface{}) {
+			doc["scene"] = 1
+		}, "default scene 1 doesn't exist"},
+		{"material out of range", func(doc map[string]interface{}) {
+			primitive(doc)["material"] = 0
+		}, "material 0 doesn't exist"},
+		{"texture out of range", func(doc map[string]interface{}) {
+			doc["materials"] = []interface{}{map[string]interface{}{"normalTexture": map[string]interface{}{"index": 0}}}
+		}, "texture 0 doesn't exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := gltfTestDoc(true)
+			tt.edit(doc)
+			_, err := ReadGltf(bytes.NewReader(encodeGltfTest(t, doc)))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	for _, data := range []string{"", "{", `{"asset": {"version": "2.0"}, "nodes": 1}`} {
+		if _, err := ReadGltf(strings.NewReader(data)); err == nil {
+			t.Errorf("got no error for malformed json %q", data)
+		}
+	}
+}
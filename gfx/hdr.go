@@ -0,0 +1,307 @@
+package gfx
+
+import (
+	_ "embed"
+	"math"
+	"time"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/post/tonemap.frag
+var tonemapFragmentShader string
+
+//go:embed shaders/post/luminance.frag
+var luminanceFragmentShader string
+
+//go:embed shaders/post/adapt.frag
+var adaptFragmentShader string
+
+//go:embed shaders/post/bloom.frag
+var bloomFragmentShader string
+
+//go:embed shaders/post/bloom_down.frag
+var bloomDownFragmentShader string
+
+//go:embed shaders/post/bloom_up.frag
+var bloomUpFragmentShader string
+
+// Tonemapping operators of tonemap pass, values of its tonemapOperator
+// parameter
+const (
+	TonemapReinhard int32 = iota
+	TonemapACES
+	TonemapFilmic
+)
+
+const (
+	// Size of image whose mipmaps average luminance of scene
+	luminanceSize = 256
+
+	// Number of levels of bloom, each half the size of previous one
+	bloomLevels = 6
+)
+
+// NewTonemapPass creates pass mapping HDR colors into [0, 1] range by
+// selectable operator. With autoExposure parameter set, exposure adapts to
+// average luminance of scene over time, keeping it at exposureKey.
+func NewTonemapPass() (*PostPass, error) {
+	p, err := NewPostPass("Tonemap", tonemapFragmentShader,
+		PostParam{Name: "tonemapOperator", Options: []string{"Reinhard", "ACES", "Filmic"}},
+		PostParam{Name: "exposure", Min: 0, Max: 8},
+		PostParam{Name: "autoExposure"},
+		PostParam{Name: "exposureKey", Min: 0.02, Max: 0.5},
+		PostParam{Name: "adaptationSpeed", Min: 0.1, Max: 10})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("tonemapOperator", TonemapACES)
+	p.Material.SetParam("exposure", float32(1))
+	p.Material.SetParam("autoExposure", false)
+	p.Material.SetParam("exposureKey", float32(0.18))
+	p.Material.SetParam("adaptationSpeed", float32(1.5))
+
+	a, err := newAutoExposure(p.Material)
+	if err != nil {
+		p.Dispose()
+		return nil, err
+	}
+	p.prepare, p.dispose = a.prepare, a.dispose
+	return p, nil
+}
+
+// Luminance adapted to before the first frame, giving exposure of 1 at
+// the default key
+const adaptedLuminanceInitial = 0.18
+
+// Average luminance of scene, adapting to changes over time
+type autoExposure struct {
+	tonemap   *Material
+	luminance *Framebuffer // log luminance with mipmaps
+	adapted   [2]*Framebuffer
+	current   int
+	reduce    *PostPass
+	adapt     *PostPass
+	last      time.Time
+}
+
+func newAutoExposure(tonemap *Material) (*autoExposure, error) {
+	a := &autoExposure{tonemap: tonemap}
+	var err error
+	levels := int32(math.Log2(luminanceSize)) + 1
+	a.luminance, err = NewFramebuffer(luminanceSize, luminanceSize,
+		FramebufferSpec{Colors: []uint32{gl.R16F}, Levels: levels})
+	if err != nil {
+		a.dispose()
+		return nil, err
+	}
+	// Storage is undefined, a NaN there would never adapt away
+	initial := float32(adaptedLuminanceInitial)
+	for i := range a.adapted {
+		a.adapted[i], err = NewFramebuffer(1, 1, FramebufferSpec{Colors: []uint32{gl.R32F}})
+		if err != nil {
+			a.dispose()
+			return nil, err
+		}
+		gl.ClearTexImage(a.adapted[i].ColorTexture(0), 0, gl.RED, gl.FLOAT, gl.Ptr(&initial))
+	}
+	if a.reduce, err = NewPostPass("Luminance", luminanceFragmentShader); err != nil {
+		a.dispose()
+		return nil, err
+	}
+	if a.adapt, err = NewPostPass("Adaptation", adaptFragmentShader); err != nil {
+		a.dispose()
+		return nil, err
+	}
+	a.adapt.Material.SetParam("minLuminance", float32(0.01))
+	a.adapt.Material.SetParam("maxLuminance", float32(100))
+	return a, nil
+}
+
+// Update adapted luminance from source, which is read by tonemap pass
+func (a *autoExposure) prepare(source uint32, width, height int32) error {
+	if enabled, _ := a.tonemap.Param("autoExposure").(bool); !enabled {
+		a.last = time.Time{}
+		return nil
+	}
+
+	// Average is in the smallest mipmap
+	a.luminance.Bind()
+	a.reduce.Material.SetTexture(UniformPostSource, source)
+	a.reduce.Material.Use()
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+	gl.BindTexture(gl.TEXTURE_2D, a.luminance.ColorTexture(0))
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+	forgetBindings()
+
+	// The first frame starts at current luminance
+	now := time.Now()
+	adaptation := float32(1)
+	if !a.last.IsZero() {
+		speed, _ := a.tonemap.Param("adaptationSpeed").(float32)
+		dt := float32(now.Sub(a.last).Seconds())
+		adaptation = 1 - float32(math.Exp(float64(-dt*speed)))
+	}
+	a.last = now
+	previous := a.adapted[a.current]
+	a.current = 1 - a.current
+	next := a.adapted[a.current]
+	next.Bind()
+	a.adapt.Material.SetTexture("luminanceMap", a.luminance.ColorTexture(0))
+	a.adapt.Material.SetTexture("previousLuminance", previous.ColorTexture(0))
+	a.adapt.Material.SetParam("adaptation", adaptation)
+	a.adapt.Material.Use()
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+
+	a.tonemap.SetTexture("adaptedLuminance", next.ColorTexture(0))
+	return nil
+}
+
+func (a *autoExposure) dispose() {
+	if a.luminance != nil {
+		a.luminance.Dispose()
+	}
+	for _, fb := range a.adapted {
+		if fb != nil {
+			fb.Dispose()
+		}
+	}
+	if a.reduce != nil {
+		a.reduce.Dispose()
+	}
+	if a.adapt != nil {
+		a.adapt.Dispose()
+	}
+}
+
+// NewBloomPass creates pass adding glow around bright areas. Colors
+// brighter than threshold (with soft knee) are blurred by a chain of
+// progressively downsampled and upsampled images, and added back scaled
+// by intensity. It should precede tonemap pass.
+func NewBloomPass() (*PostPass, error) {
+	p, err := NewPostPass("Bloom", bloomFragmentShader,
+		PostParam{Name: "intensity", Min: 0, Max: 1},
+		PostParam{Name: "threshold", Min: 0, Max: 10},
+		PostParam{Name: "knee", Min: 0, Max: 1},
+		PostParam{Name: "filterRadius", Min: 0.5, Max: 3})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("intensity", float32(0.05))
+	p.Material.SetParam("threshold", float32(1))
+	p.Material.SetParam("knee", float32(0.5))
+	p.Material.SetParam("filterRadius", float32(1))
+
+	b := &bloom{pass: p.Material}
+	if b.down, err = NewPostPass("Bloom Downsample", bloomDownFragmentShader); err != nil {
+		p.Dispose()
+		return nil, err
+	}
+	if b.up, err = NewPostPass("Bloom Upsample", bloomUpFragmentShader); err != nil {
+		b.dispose()
+		p.Dispose()
+		return nil, err
+	}
+	p.prepare, p.dispose = b.prepare, b.dispose
+	return p, nil
+}
+
+// Chain of images blurring bright areas of scene
+type bloom struct {
+	pass   *Material
+	levels []*Framebuffer // halving sizes, starting at half of source
+	down   *PostPass
+	up     *PostPass
+}
+
+// Render bloom of source into the first level, which is read by bloom pass
+func (b *bloom) prepare(source uint32, width, height int32) error {
+	if err := b.resize(width, height); err != nil {
+		return err
+	}
+	if len(b.levels) == 0 {
+		b.pass.SetTexture("bloomMap", 0)
+		return nil
+	}
+	threshold, _ := b.pass.Param("threshold").(float32)
+	knee, _ := b.pass.Param("knee").(float32)
+	knee = mgl32.Clamp(knee, 0, 1)*threshold + 0.0001
+	filterRadius, _ := b.pass.Param("filterRadius").(float32)
+
+	// Bright areas of source are downsampled level by level
+	down := b.down.Material
+	down.SetParam("threshold", mgl32.Vec4{threshold, threshold - knee, 2 * knee, 0.25 / knee})
+	input, inputWidth, inputHeight := source, width, height
+	for i, level := range b.levels {
+		level.Bind()
+		down.SetTexture(UniformPostSource, input)
+		down.SetParam(UniformPostTexelSize, mgl32.Vec2{1 / float32(inputWidth), 1 / float32(inputHeight)})
+		down.SetParam("prefilter", i == 0)
+		down.Use()
+		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+		input = level.ColorTexture(0)
+		inputWidth, inputHeight = level.Size()
+	}
+
+	// Then smaller levels are blurred and added to larger ones
+	up := b.up.Material
+	up.SetParam("filterRadius", filterRadius)
+	gl.Enable(gl.BLEND)
+	gl.BlendEquation(gl.FUNC_ADD)
+	gl.BlendFunc(gl.ONE, gl.ONE)
+	for i := len(b.levels) - 2; i >= 0; i-- {
+		smaller := b.levels[i+1]
+		smallerWidth, smallerHeight := smaller.Size()
+		b.levels[i].Bind()
+		up.SetTexture(UniformPostSource, smaller.ColorTexture(0))
+		up.SetParam(UniformPostTexelSize, mgl32.Vec2{1 / float32(smallerWidth), 1 / float32(smallerHeight)})
+		up.Use()
+		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+	}
+	gl.Disable(gl.BLEND)
+
+	b.pass.SetTexture("bloomMap", b.levels[0].ColorTexture(0))
+	return nil
+}
+
+// Fit chain of levels to source of size
+func (b *bloom) resize(width, height int32) error {
+	levelWidth, levelHeight := width/2, height/2
+	count := 0
+	for ; count < bloomLevels && levelWidth >= 2 && levelHeight >= 2; count++ {
+		if count < len(b.levels) {
+			if err := b.levels[count].Resize(levelWidth, levelHeight); err != nil {
+				return err
+			}
+		} else {
+			fb, err := NewFramebuffer(levelWidth, levelHeight,
+				FramebufferSpec{Colors: []uint32{gl.R11F_G11F_B10F}})
+			if err != nil {
+				return err
+			}
+			b.levels = append(b.levels, fb)
+		}
+		levelWidth, levelHeight = levelWidth/2, levelHeight/2
+	}
+
+	// Small source needs fewer levels
+	for _, fb := range b.levels[count:] {
+		fb.Dispose()
+	}
+	b.levels = b.levels[:count]
+	return nil
+}
+
+func (b *bloom) dispose() {
+	for _, fb := range b.levels {
+		fb.Dispose()
+	}
+	b.levels = nil
+	if b.down != nil {
+		b.down.Dispose()
+	}
+	if b.up != nil {
+		b.up.Dispose()
+	}
+}
@@ -0,0 +1,58 @@
+package gfx
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Buffer of per-instance attributes (e.g. model matrices, colors),
+// storage grows as needed.
+type InstanceBuffer struct {
+	layout VertexLayout
+	buffer *Buffer
+	count  int32
+}
+
+// NewInstanceBuffer creates empty instance buffer, attributes in layout
+// advance once per instance.
+func NewInstanceBuffer(layout VertexLayout) *InstanceBuffer {
+	b := &InstanceBuffer{
+		layout: layout,
+		buffer: NewBuffer(gl.ARRAY_BUFFER, BufferDynamic, 0),
+	}
+	return b
+}
+
+// Update replaces content of buffer, data must be a slice of plain values
+// interleaved according to layout.
+func (b *InstanceBuffer) Update(data interface{}) error {
+	if err := b.layout.validate(); err != nil {
+		return err
+	}
+	ptr, size, err := sliceData(data)
+	if err != nil {
+		return err
+	}
+	stride := int(b.layout.Stride())
+	if stride == 0 {
+		return fmt.Errorf("layout of instance buffer is empty")
+	}
+	if size%stride != 0 {
+		return fmt.Errorf("data size %d isn't multiple of stride %d", size, stride)
+	}
+
+	b.buffer.UploadPointer(ptr, size)
+	b.count = int32(size / stride)
+	return nil
+}
+
+// Count returns number of instances.
+func (b *InstanceBuffer) Count() int32 {
+	return b.count
+}
+
+// Dispose cleans up the resources.
+func (b *InstanceBuffer) Dispose() {
+	b.buffer.Dispose()
+}
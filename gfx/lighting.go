@@ -0,0 +1,284 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/lit.vert
+var litVertexShader string
+
+//go:embed shaders/lights.glsl
+var lightsShader string
+
+//go:embed shaders/blinn_phong.frag
+var blinnPhongFragmentShader string
+
+//go:embed shaders/pbr.frag
+var pbrFragmentShader string
+
+//go:embed shaders/shading.glsl
+var shadingShader string
+
+// Binding point of light buffer used by lit programs
+const LightBinding = 0
+
+// Kind of light source
+type LightType int32
+
+const (
+	LightDirectional LightType = iota
+	LightPoint
+	LightSpot
+)
+
+// Light source, colors are in linear space
+type Light struct {
+	Type      LightType
+	Position  mgl32.Vec3 // point and spot lights
+	Direction mgl32.Vec3 // directional and spot lights, where light travels to
+	Color     mgl32.Vec3
+	Intensity float32
+	Range     float32 // distance where point and spot lights fade out, 0 for infinite
+	InnerCone float32 // angle in radians where spot light starts fading
+	OuterCone float32 // angle in radians where spot light is faded out
+
+	// Shadows of directional and spot lights are rendered by ShadowMaps
+	CastShadows      bool
+	ShadowBias       float32 // depth bias in normalized depth of shadow map
+	ShadowSlopeBias  float32 // depth bias scaled by slope when rendering shadow map
+	ShadowNormalBias float32 // offset of sampling position along normal in world units
+}
+
+// Layout of light in buffer, must match Light of lights.glsl
+type gpuLight struct {
+	Position  mgl32.Vec3
+	Type      LightType
+	Direction mgl32.Vec3
+	Range     float32
+	Color     mgl32.Vec3
+	Intensity float32
+	CosInner  float32
+	CosOuter  float32
+
+	ShadowLayer      int32
+	ShadowBias       float32
+	ShadowNormalBias float32
+	_                [3]float32
+}
+
+// Header of light buffer, padded to size of light, must match Lights
+// block of lights.glsl
+type gpuLightsHeader struct {
+	Ambient              mgl32.Vec3
+	Count                int32
+	EnvironmentIntensity float32
+	EnvironmentMaxLod    float32
+	_                    [14]float32
+}
+
+// Way of shading lit meshes, chosen at startup
+type RenderPath int
+
+const (
+	// Every mesh is shaded by all lights in its fragment shader
+	RenderForward RenderPath = iota
+
+	// Opaque meshes write their surfaces into G-buffer, which is shaded
+	// by DeferredRenderer, transparent ones are shaded forward
+	RenderDeferred
+)
+
+// Shading model of lit programs
+type ShadingModel int
+
+const (
+	// Classic diffuse + specular highlight, parameters: diffuseColor,
+	// diffuseMap/useDiffuseMap, specularColor, shininess, emissiveColor,
+	// normalMap/useNormalMap
+	ShadingBlinnPhong ShadingModel = iota
+
+	// Metallic-roughness model of glTF, parameters: baseColorFactor,
+	// baseColorMap/useBaseColorMap, metallicFactor, roughnessFactor,
+	// metallicRoughnessMap/useMetallicRoughnessMap, normalMap/useNormalMap,
+	// normalScale, occlusionMap/useOcclusionMap, occlusionStrength,
+	// emissiveFactor, emissiveMap/useEmissiveMap
+	ShadingPBR
+)
+
+// Lighting holds lights of scene, which are uploaded to a uniform buffer, or
+// a storage buffer when available, shared by all lit programs.
+//
+// Lit programs output linear colors, color maps should be loaded as sRGB
+// textures (e.g. LoadTextureSRGB) and output converted to sRGB by enabling
+// gl.FRAMEBUFFER_SRGB or by post-processing.
+type Lighting struct {
+	Lights      []Light
+	Ambient     mgl32.Vec3
+	Shadows     *ShadowMaps  // could be nil
+	Environment *Environment // image based lighting, could be nil
+	Path        RenderPath   // should be set before creating materials
+	maxLights   int
+	storage     bool
+	buffer      *Buffer
+}
+
+// NewLighting creates lighting supporting up to maxLights lights.
+func NewLighting(maxLights int) *Lighting {
+	l := &Lighting{
+		maxLights: maxLights,
+		storage:   VersionAtLeast(4, 3) || HasExtension("GL_ARB_shader_storage_buffer_object"),
+	}
+	target := uint32(gl.UNIFORM_BUFFER)
+	if l.storage {
+		target = gl.SHADER_STORAGE_BUFFER
+	}
+	size := int(unsafe.Sizeof(gpuLight{})) * (maxLights + 1)
+	l.buffer = NewBuffer(target, BufferDynamic, size)
+	return l
+}
+
+// MaxLights returns maximum number of lights, the rest is ignored.
+func (l *Lighting) MaxLights() int {
+	return l.maxLights
+}
+
+// Update uploads lights and binds light buffer, it should be called when
+// lights, shadow maps or environment change, before drawing with lit programs.
+func (l *Lighting) Update() {
+	count := len(l.Lights)
+	if count > l.maxLights {
+		count = l.maxLights
+	}
+
+	var layers []int
+	if l.Shadows != nil {
+		layers = l.Shadows.assignLayers(l.Lights[:count])
+	}
+
+	// Header takes place of the first light
+	data := make([]gpuLight, count+1)
+	header := (*gpuLightsHeader)(unsafe.Pointer(&data[0]))
+	header.Ambient = l.Ambient
+	header.Count = int32(count)
+	if l.Environment != nil {
+		header.EnvironmentIntensity = l.Environment.Intensity
+		header.EnvironmentMaxLod = l.Environment.MaxLod()
+		l.Environment.Bind()
+	}
+	for i, light := range l.Lights[:count] {
+		g := &data[i+1]
+		g.Position = light.Position
+		g.Type = light.Type
+		g.Direction = light.Direction
+		if g.Direction.Len() > 0 {
+			g.Direction = g.Direction.Normalize()
+		}
+		g.Range = light.Range
+		g.Color = light.Color
+		g.Intensity = light.Intensity
+		g.CosInner = float32(math.Cos(float64(light.InnerCone)))
+		g.CosOuter = float32(math.Cos(float64(light.OuterCone)))
+		g.ShadowLayer = -1
+		if layers != nil {
+			g.ShadowLayer = int32(layers[i])
+		}
+		g.ShadowBias = light.ShadowBias
+		g.ShadowNormalBias = light.ShadowNormalBias
+	}
+	l.buffer.Upload(data)
+	gl.BindBufferBase(l.buffer.Target(), LightBinding, l.buffer.ID())
+}
+
+// NewProgram compiles lit program of shading model, matching maximum
+// light count and buffer kind of lighting. Program of deferred path
+// writes G-buffer instead of shading.
+func (l *Lighting) NewProgram(model ShadingModel) (uint32, error) {
+	return l.newProgram(model, l.Path)
+}
+
+// NewForwardProgram is like NewProgram, but program always shades meshes
+// by all lights, e.g. for transparent meshes of deferred path.
+func (l *Lighting) NewForwardProgram(model ShadingModel) (uint32, error) {
+	return l.newProgram(model, RenderForward)
+}
+
+func (l *Lighting) newProgram(model ShadingModel, path RenderPath) (uint32, error) {
+	defines := ""
+	if path == RenderDeferred {
+		defines = "#define GBUFFER_PASS\n"
+	}
+	fragment := blinnPhongFragmentShader
+	if model == ShadingPBR {
+		fragment = pbrFragmentShader
+	}
+	return LoadShaders([]Shader{
+		{Source: "#version 430 core\n" + litVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: l.shaderHeader() + defines + lightsShader + shadingShader + fragment + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+}
+
+// Get version and defines of lighting shared by its shaders
+func (l *Lighting) shaderHeader() string {
+	header := fmt.Sprintf("#version 430 core\n"+
+		"#define MAX_LIGHTS %d\n#define LIGHT_BINDING %d\n"+
+		"#define MAX_SHADOW_LAYERS %d\n#define SHADOW_BINDING %d\n#define SHADOW_TEXTURE_UNIT %d\n"+
+		"#define IRRADIANCE_TEXTURE_UNIT %d\n#define PREFILTERED_TEXTURE_UNIT %d\n#define BRDF_TEXTURE_UNIT %d\n",
+		l.maxLights, LightBinding, MaxShadowLayers, ShadowBinding, ShadowTextureUnit,
+		EnvironmentTextureUnit, EnvironmentTextureUnit+1, EnvironmentTextureUnit+2)
+	if l.storage {
+		header += "#define LIGHTS_IN_STORAGE\n"
+	}
+	return header
+}
+
+// NewMaterial creates material with new lit program of shading model,
+// parameters are set to defaults (white, non-metallic, no maps). Program
+// is owned by material.
+func (l *Lighting) NewMaterial(model ShadingModel) (*Material, error) {
+	program, err := l.NewProgram(model)
+	if err != nil {
+		return nil, err
+	}
+	return newLitMaterial(program, model), nil
+}
+
+// NewTransparentMaterial is like NewMaterial, but material is transparent
+// and shaded forward in either path.
+func (l *Lighting) NewTransparentMaterial(model ShadingModel) (*Material, error) {
+	program, err := l.NewForwardProgram(model)
+	if err != nil {
+		return nil, err
+	}
+	m := newLitMaterial(program, model)
+	m.Transparent = true
+	return m, nil
+}
+
+// Create material owning lit program, with default parameters of model
+func newLitMaterial(program uint32, model ShadingModel) *Material {
+	m := NewMaterial(program)
+	m.ownsProgram = true
+	if model == ShadingPBR {
+		m.SetParam("baseColorFactor", mgl32.Vec4{1, 1, 1, 1})
+		m.SetParam("metallicFactor", float32(0))
+		m.SetParam("roughnessFactor", float32(0.5))
+		m.SetParam("normalScale", float32(1))
+		m.SetParam("occlusionStrength", float32(1))
+	} else {
+		m.SetParam("diffuseColor", mgl32.Vec4{1, 1, 1, 1})
+		m.SetParam("specularColor", mgl32.Vec3{0.5, 0.5, 0.5})
+		m.SetParam("shininess", float32(32))
+	}
+	return m
+}
+
+// Dispose cleans up the resources.
+func (l *Lighting) Dispose() {
+	l.buffer.Dispose()
+}
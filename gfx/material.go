@@ -0,0 +1,475 @@
+package gfx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of transform uniforms set when drawing scene
+const (
+	UniformProjection = "projection"
+	UniformView       = "camera"
+	UniformModel      = "model"
+
+	// Position of camera in world space
+	UniformCameraPosition = "cameraPosition"
+)
+
+// Texture bound to sampler uniform of material
+type materialTexture struct {
+	name    string
+	target  uint32
+	texture uint32
+}
+
+// Material describes how mesh is shaded: program, textures bound to its
+// samplers and values of its uniforms. Textures are assigned to texture
+// units in order of sampler names.
+type Material struct {
+	Name    string
+	Program uint32
+
+	// Transparent material is drawn after opaque ones, back to front with
+	// alpha blending and without writing depth
+	Transparent bool
+
+	textures []materialTexture
+	params   map[string]interface{}
+	uniforms map[string]int32
+	version  int
+
+	ownsProgram  bool
+	ownsTextures bool
+}
+
+// Last bound state, used for skipping redundant state changes
+var glState struct {
+	program  uint32
+	textures map[uint32]uint32             // texture unit -> texture
+	applied  map[uint32]materialAssignment // program -> last uniforms assigned
+}
+
+// Material whose uniforms are assigned to program
+type materialAssignment struct {
+	material *Material
+	version  int
+}
+
+// NewMaterial creates material using program, textures are bound to
+// texture units in order (sampler uniforms are left alone).
+func NewMaterial(program uint32, textures ...uint32) *Material {
+	m := &Material{
+		Program:  program,
+		params:   map[string]interface{}{},
+		uniforms: map[string]int32{},
+	}
+	for _, tex := range textures {
+		m.textures = append(m.textures, materialTexture{target: gl.TEXTURE_2D, texture: tex})
+	}
+	return m
+}
+
+// SetTexture binds 2d texture to sampler uniform name.
+func (m *Material) SetTexture(name string, texture uint32) {
+	m.SetTextureTarget(name, gl.TEXTURE_2D, texture)
+}
+
+// SetTextureTarget binds texture of target (e.g. gl.TEXTURE_CUBE_MAP) to
+// sampler uniform name.
+func (m *Material) SetTextureTarget(name string, target, texture uint32) {
+	m.version++
+	for i, t := range m.textures {
+		if t.name == name && name != "" {
+			m.textures[i].target, m.textures[i].texture = target, texture
+			return
+		}
+	}
+	m.textures = append(m.textures, materialTexture{name: name, target: target, texture: texture})
+	sort.SliceStable(m.textures, func(i, j int) bool {
+		return m.textures[i].name < m.textures[j].name
+	})
+}
+
+// Texture returns texture bound to sampler uniform name, 0 if absent.
+func (m *Material) Texture(name string) uint32 {
+	for _, t := range m.textures {
+		if t.name == name {
+			return t.texture
+		}
+	}
+	return 0
+}
+
+// SetParam sets value of uniform name, which could be float32, int32,
+// uint32, bool, mgl32.Vec2/3/4, mgl32.Mat3/4 or []float32.
+func (m *Material) SetParam(name string, value interface{}) error {
+	switch value.(type) {
+	case float32, int32, uint32, bool,
+		mgl32.Vec2, mgl32.Vec3, mgl32.Vec4, mgl32.Mat3, mgl32.Mat4, []float32:
+	default:
+		return fmt.Errorf("unsupported type %T of parameter %q", value, name)
+	}
+	m.params[name] = value
+	m.version++
+	return nil
+}
+
+// Param returns value of uniform name, nil if absent.
+func (m *Material) Param(name string) interface{} {
+	return m.params[name]
+}
+
+// Use makes material current, skipping state which is already set.
+func (m *Material) Use() {
+	if glState.program != m.Program {
+		gl.UseProgram(m.Program)
+		glState.program = m.Program
+	}
+	if glState.textures == nil {
+		glState.textures = map[uint32]uint32{}
+	}
+	if glState.applied == nil {
+		glState.applied = map[uint32]materialAssignment{}
+	}
+	for i, t := range m.textures {
+		unit := uint32(i)
+		if glState.textures[unit] != t.texture {
+			gl.ActiveTexture(gl.TEXTURE0 + unit)
+			gl.BindTexture(t.target, t.texture)
+			glState.textures[unit] = t.texture
+		}
+	}
+
+	// Uniforms are state of program, which may have been set by this material
+	applied := materialAssignment{material: m, version: m.version}
+	if glState.applied[m.Program] == applied {
+		return
+	}
+	glState.applied[m.Program] = applied
+	for i, t := range m.textures {
+		if t.name != "" {
+			gl.Uniform1i(m.UniformLocation(t.name), int32(i))
+		}
+	}
+	for name, value := range m.params {
+		setUniform(m.UniformLocation(name), value)
+	}
+}
+
+// ResetStateCache forgets bound state remembered by materials, it should
+// be called after program, textures or uniforms are changed directly.
+func ResetStateCache() {
+	forgetBindings()
+	glState.applied = nil
+}
+
+// Forget bound program and textures, which are easily changed by others
+func forgetBindings() {
+	glState.program = 0
+	glState.textures = nil
+}
+
+// UniformLocation returns location of uniform in program, -1 if absent.
+func (m *Material) UniformLocation(name string) int32 {
+	location, ok := m.uniforms[name]
+	if !ok {
+		location = gl.GetUniformLocation(m.Program, gl.Str(name+"\x00"))
+		m.uniforms[name] = location
+	}
+	return location
+}
+
+// Dispose cleans up the resources created along with material (e.g. by
+// LoadMaterial), program and textures given by caller are left alone.
+func (m *Material) Dispose() {
+	if m.ownsTextures {
+		for _, t := range m.textures {
+			gl.DeleteTextures(1, &t.texture)
+		}
+		m.textures = nil
+	}
+	if m.ownsProgram {
+		gl.DeleteProgram(m.Program)
+		m.Program = 0
+	}
+	m.ownsProgram, m.ownsTextures = false, false
+}
+
+// Set transform uniforms of current program, camera uniforms are skipped
+// when view is nil
+func (m *Material) setTransforms(projection, view *mgl32.Mat4, model *mgl32.Mat4) {
+	if view != nil {
+		eye := view.Inv().Col(3).Vec3()
+		gl.UniformMatrix4fv(m.UniformLocation(UniformProjection), 1, false, &projection[0])
+		gl.UniformMatrix4fv(m.UniformLocation(UniformView), 1, false, &view[0])
+		gl.Uniform3fv(m.UniformLocation(UniformCameraPosition), 1, &eye[0])
+	}
+	gl.UniformMatrix4fv(m.UniformLocation(UniformModel), 1, false, &model[0])
+}
+
+// Assign value to uniform of current program
+func setUniform(location int32, value interface{}) {
+	if location < 0 {
+		return
+	}
+	switch v := value.(type) {
+	case float32:
+		gl.Uniform1f(location, v)
+	case int32:
+		gl.Uniform1i(location, v)
+	case uint32:
+		gl.Uniform1ui(location, v)
+	case bool:
+		if v {
+			gl.Uniform1i(location, 1)
+		} else {
+			gl.Uniform1i(location, 0)
+		}
+	case mgl32.Vec2:
+		gl.Uniform2fv(location, 1, &v[0])
+	case mgl32.Vec3:
+		gl.Uniform3fv(location, 1, &v[0])
+	case mgl32.Vec4:
+		gl.Uniform4fv(location, 1, &v[0])
+	case mgl32.Mat3:
+		gl.UniformMatrix3fv(location, 1, false, &v[0])
+	case mgl32.Mat4:
+		gl.UniformMatrix4fv(location, 1, false, &v[0])
+	case []float32:
+		if len(v) > 0 {
+			gl.Uniform1fv(location, int32(len(v)), &v[0])
+		}
+	}
+}
+
+// Description of material in JSON or YAML file, paths are relative to
+// the file:
+//
+//	{
+//	  "name": "crate",
+//	  "shaders": {"vertex": "basic.vert", "fragment": "basic.frag"},
+//	  "textures": {"tex": "crate.png"},
+//	  "params": {"tint": [1, 0.8, 0.8, 1], "shininess": 32}
+//	}
+type materialFile struct {
+	Name     string                     `json:"name"`
+	Shaders  map[string]string          `json:"shaders"`
+	Textures map[string]string          `json:"textures"`
+	Params   map[string]json.RawMessage `json:"params"`
+}
+
+// Shader stages by name used in material file
+var shaderStages = map[string]uint32{
+	"vertex":          gl.VERTEX_SHADER,
+	"fragment":        gl.FRAGMENT_SHADER,
+	"geometry":        gl.GEOMETRY_SHADER,
+	"tess_control":    gl.TESS_CONTROL_SHADER,
+	"tess_evaluation": gl.TESS_EVALUATION_SHADER,
+}
+
+// LoadMaterial loads material described by JSON or YAML file (by .yaml or
+// .yml extension), compiling its shaders and loading its textures.
+// Parameters are converted to types of uniforms declared in program.
+func LoadMaterial(file string) (*Material, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("material %q not found on disk: %v", file, err)
+	}
+	dir := filepath.Dir(file)
+	return parseMaterial(data, file,
+		func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		},
+		func(name string) (uint32, error) {
+			return LoadTexture(filepath.Join(dir, filepath.FromSlash(name)))
+		})
+}
+
+// LoadMaterialFS loads material like LoadMaterial from filesystem fsys.
+func LoadMaterialFS(fsys fs.FS, name string) (*Material, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("material %q not found in filesystem: %v", name, err)
+	}
+	dir := path.Dir(name)
+	return parseMaterial(data, name,
+		func(name string) ([]byte, error) {
+			return fs.ReadFile(fsys, path.Join(dir, name))
+		},
+		func(name string) (uint32, error) {
+			return LoadTextureFS(fsys, path.Join(dir, name))
+		})
+}
+
+// Create material from description in file, reading shaders and loading
+// textures by their paths in it
+func parseMaterial(data []byte, file string, readShader func(string) ([]byte, error),
+	loadTexture func(string) (uint32, error)) (*Material, error) {
+	desc, err := decodeMaterialFile(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	var shaders []Shader
+	for stage, name := range desc.Shaders {
+		xtype, ok := shaderStages[stage]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown shader stage %q", file, stage)
+		}
+		source, err := readShader(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: shader %q not found: %v", file, name, err)
+		}
+		shaders = append(shaders, Shader{Source: string(source) + "\x00", Type: xtype})
+	}
+	program, err := LoadShaders(shaders)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	m := NewMaterial(program)
+	m.Name = desc.Name
+	m.ownsProgram, m.ownsTextures = true, true
+	for uniform, name := range desc.Textures {
+		texture, err := loadTexture(name)
+		if err != nil {
+			m.Dispose()
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		m.SetTexture(uniform, texture)
+	}
+
+	types := activeUniforms(program)
+	for name, raw := range desc.Params {
+		xtype, ok := types[name]
+		if !ok {
+			// Unused uniforms are optimized out, so just skip them
+			continue
+		}
+		value, err := decodeParam(raw, xtype)
+		if err == nil {
+			err = m.SetParam(name, value)
+		}
+		if err != nil {
+			m.Dispose()
+			return nil, fmt.Errorf("%s: parameter %q: %v", file, name, err)
+		}
+	}
+	return m, nil
+}
+
+// Decode description of material, YAML is converted to JSON it mirrors,
+// so parameters are decoded the same way
+func decodeMaterialFile(data []byte, file string) (*materialFile, error) {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".yaml", ".yml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+	var desc materialFile
+	if err := json.Unmarshal(data, &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+// Get types of active uniforms of program by name
+func activeUniforms(program uint32) map[string]uint32 {
+	var count, maxLength int32
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+	types := make(map[string]uint32, count)
+	buf := make([]uint8, maxLength+1)
+	for i := int32(0); i < count; i++ {
+		var length, size int32
+		var xtype uint32
+		gl.GetActiveUniform(program, uint32(i), maxLength+1, &length, &size, &xtype, &buf[0])
+		// Arrays are reported as "name[0]"
+		name := strings.TrimSuffix(string(buf[:length]), "[0]")
+		types[name] = xtype
+	}
+	return types
+}
+
+// Decode JSON value of parameter according to type of uniform
+func decodeParam(raw json.RawMessage, xtype uint32) (interface{}, error) {
+	var floats []float32
+	var single float32
+	var flag bool
+	switch {
+	case json.Unmarshal(raw, &floats) == nil:
+	case json.Unmarshal(raw, &single) == nil:
+		floats = []float32{single}
+	case json.Unmarshal(raw, &flag) == nil:
+		if flag {
+			floats = []float32{1}
+		} else {
+			floats = []float32{0}
+		}
+	default:
+		return nil, fmt.Errorf("expect number, bool or array of numbers")
+	}
+
+	want := 1
+	switch xtype {
+	case gl.FLOAT_VEC2:
+		want = 2
+	case gl.FLOAT_VEC3:
+		want = 3
+	case gl.FLOAT_VEC4:
+		want = 4
+	case gl.FLOAT_MAT3:
+		want = 9
+	case gl.FLOAT_MAT4:
+		want = 16
+	case gl.FLOAT:
+		if len(floats) > 1 {
+			return floats, nil
+		}
+	case gl.INT, gl.BOOL, gl.UNSIGNED_INT:
+	default:
+		return nil, fmt.Errorf("unsupported uniform type 0x%x", xtype)
+	}
+	if len(floats) != want {
+		return nil, fmt.Errorf("expect %d numbers, got %d", want, len(floats))
+	}
+
+	switch xtype {
+	case gl.FLOAT_VEC2:
+		return mgl32.Vec2{floats[0], floats[1]}, nil
+	case gl.FLOAT_VEC3:
+		return mgl32.Vec3{floats[0], floats[1], floats[2]}, nil
+	case gl.FLOAT_VEC4:
+		return mgl32.Vec4{floats[0], floats[1], floats[2], floats[3]}, nil
+	case gl.FLOAT_MAT3:
+		var mat mgl32.Mat3
+		copy(mat[:], floats)
+		return mat, nil
+	case gl.FLOAT_MAT4:
+		var mat mgl32.Mat4
+		copy(mat[:], floats)
+		return mat, nil
+	case gl.INT, gl.BOOL:
+		return int32(floats[0]), nil
+	case gl.UNSIGNED_INT:
+		return uint32(floats[0]), nil
+	}
+	return floats[0], nil
+}
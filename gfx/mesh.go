@@ -0,0 +1,408 @@
+package gfx
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Description of a vertex attribute
+type VertexAttrib struct {
+	Name       string // name of attribute in shader program
+	Components int32  // number of components, 1-4, or 4/9/16 for matrices
+	Type       uint32 // component type, e.g. gl.FLOAT, gl.UNSIGNED_BYTE
+	Normalized bool   // whether fixed-point values are normalized when accessed
+}
+
+// Size of attribute in bytes
+func (a VertexAttrib) Size() int32 {
+	return a.Components * glTypeSize(a.Type)
+}
+
+// Layout of interleaved vertex attributes
+type VertexLayout []VertexAttrib
+
+// Stride returns distance in bytes between consecutive vertices.
+func (l VertexLayout) Stride() int32 {
+	var stride int32
+	for _, a := range l {
+		stride += a.Size()
+	}
+	return stride
+}
+
+// Check that attributes have supported types and numbers of components
+func (l VertexLayout) validate() error {
+	for _, a := range l {
+		if glTypeSize(a.Type) == 0 {
+			return fmt.Errorf("attribute %q has unsupported type 0x%x", a.Name, a.Type)
+		}
+		switch a.Components {
+		case 1, 2, 3, 4, 9, 16:
+		default:
+			return fmt.Errorf("attribute %q has invalid number of components %d", a.Name, a.Components)
+		}
+	}
+	return nil
+}
+
+// Offset returns offset in bytes of i-th attribute.
+func (l VertexLayout) Offset(i int) int32 {
+	var offset int32
+	for _, a := range l[:i] {
+		offset += a.Size()
+	}
+	return offset
+}
+
+// Vertex data stored in one buffer, interleaved according to layout.
+// Data must be a slice of plain values (e.g. []float32, []mgl32.Vec3).
+// Divisor 0 means per-vertex data, otherwise attributes advance once per
+// Divisor instances.
+type VertexStream struct {
+	Layout  VertexLayout
+	Data    interface{}
+	Divisor uint32
+}
+
+// Mesh is a vertex array object together with its buffers
+type Mesh struct {
+	vao         uint32
+	vbos        []*Buffer
+	ebo         *Buffer
+	indirect    *Buffer
+	primitive   uint32
+	indexType   uint32
+	vertexCount int32
+	indexCount  int32
+	attribs     map[string]programAttrib
+	bounds      AABB
+}
+
+// Parameters of one draw in multi-draw-indirect call, BaseVertex is
+// ignored by non-indexed mesh.
+type DrawCommand struct {
+	Count         uint32
+	InstanceCount uint32
+	First         uint32
+	BaseVertex    int32
+	BaseInstance  uint32
+}
+
+// Active vertex attribute of shader program
+type programAttrib struct {
+	location int32
+	xtype    uint32
+}
+
+// Reflect active vertex attributes of program
+func programAttribs(program uint32) map[string]programAttrib {
+	var count, maxLength int32
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTES, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &maxLength)
+
+	attribs := map[string]programAttrib{}
+	buf := make([]uint8, maxLength+1)
+	for i := int32(0); i < count; i++ {
+		var (
+			length, size int32
+			xtype        uint32
+		)
+		gl.GetActiveAttrib(program, uint32(i), int32(len(buf)), &length, &size, &xtype, &buf[0])
+		name := string(buf[:length])
+		if strings.HasPrefix(name, "gl_") {
+			continue
+		}
+		attribs[name] = programAttrib{
+			location: gl.GetAttribLocation(program, gl.Str(name+"\x00")),
+			xtype:    xtype,
+		}
+	}
+	return attribs
+}
+
+// Number of columns of matrix attribute, 1 for non-matrix types
+func matrixColumns(xtype uint32) int32 {
+	switch xtype {
+	case gl.FLOAT_MAT2, gl.FLOAT_MAT2x3, gl.FLOAT_MAT2x4:
+		return 2
+	case gl.FLOAT_MAT3, gl.FLOAT_MAT3x2, gl.FLOAT_MAT3x4:
+		return 3
+	case gl.FLOAT_MAT4, gl.FLOAT_MAT4x2, gl.FLOAT_MAT4x3:
+		return 4
+	}
+	return 1
+}
+
+// Whether shader attribute type is integral
+func isIntegerAttrib(xtype uint32) bool {
+	switch xtype {
+	case gl.INT, gl.INT_VEC2, gl.INT_VEC3, gl.INT_VEC4,
+		gl.UNSIGNED_INT, gl.UNSIGNED_INT_VEC2, gl.UNSIGNED_INT_VEC3, gl.UNSIGNED_INT_VEC4:
+		return true
+	}
+	return false
+}
+
+// NewMesh creates mesh from vertex streams, attributes are matched to program's
+// inputs by name, attributes unused by program are ignored.
+// Indices could be nil, []uint16 or []uint32.
+func NewMesh(program uint32, primitive uint32, streams []VertexStream, indices interface{}) (*Mesh, error) {
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("at least one vertex stream is needed")
+	}
+
+	m := &Mesh{
+		primitive:   primitive,
+		vertexCount: -1,
+		bounds:      EmptyAABB(),
+	}
+	for i, s := range streams {
+		if err := s.Layout.validate(); err != nil {
+			return nil, fmt.Errorf("vertex stream %d: %v", i, err)
+		}
+		stride := s.Layout.Stride()
+		if stride == 0 {
+			return nil, fmt.Errorf("vertex stream %d has empty layout", i)
+		}
+		_, size, err := sliceData(s.Data)
+		if err != nil {
+			return nil, fmt.Errorf("vertex stream %d: %v", i, err)
+		}
+		if size%int(stride) != 0 {
+			return nil, fmt.Errorf("vertex stream %d: data size %d isn't multiple of stride %d", i, size, stride)
+		}
+		if s.Divisor != 0 {
+			continue
+		}
+		count := int32(size / int(stride))
+		if m.vertexCount >= 0 && m.vertexCount != count {
+			return nil, fmt.Errorf("vertex stream %d: vertex count %d mismatches with %d", i, count, m.vertexCount)
+		}
+		m.vertexCount = count
+	}
+	if m.vertexCount < 0 {
+		return nil, fmt.Errorf("at least one per-vertex stream is needed")
+	}
+
+	var (
+		indexData unsafe.Pointer
+		indexSize int
+	)
+	switch idx := indices.(type) {
+	case nil:
+	case []uint16:
+		m.indexType = gl.UNSIGNED_SHORT
+		m.indexCount = int32(len(idx))
+	case []uint32:
+		m.indexType = gl.UNSIGNED_INT
+		m.indexCount = int32(len(idx))
+	default:
+		return nil, fmt.Errorf("unsupported index type %T", indices)
+	}
+	if m.indexType != 0 {
+		indexData, indexSize, _ = sliceData(indices)
+	}
+
+	m.attribs = programAttribs(program)
+
+	gl.GenVertexArrays(1, &m.vao)
+	gl.BindVertexArray(m.vao)
+	for i, s := range streams {
+		data, size, _ := sliceData(s.Data)
+		vbo := NewBuffer(gl.ARRAY_BUFFER, BufferStatic, size)
+		vbo.UploadPointer(data, size)
+		vbo.Bind()
+		m.vbos = append(m.vbos, vbo)
+		if err := m.setupAttribs(s.Layout, s.Divisor); err != nil {
+			gl.BindVertexArray(0)
+			gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+			m.Dispose()
+			return nil, fmt.Errorf("vertex stream %d: %v", i, err)
+		}
+	}
+	if m.indexType != 0 {
+		m.ebo = NewBuffer(gl.ELEMENT_ARRAY_BUFFER, BufferStatic, indexSize)
+		m.ebo.UploadPointer(indexData, indexSize)
+		m.ebo.Bind()
+	}
+	gl.BindVertexArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+
+	return m, nil
+}
+
+// Setup attribute pointers for buffer bound to gl.ARRAY_BUFFER, VAO must be bound.
+// Nothing is changed when layout doesn't match inputs of program.
+func (m *Mesh) setupAttribs(layout VertexLayout, divisor uint32) error {
+	// Matrices occupy one location per column, each holding rows components
+	for _, a := range layout {
+		pa, ok := m.attribs[a.Name]
+		if !ok || pa.location < 0 {
+			continue
+		}
+		columns := matrixColumns(pa.xtype)
+		rows := a.Components / columns
+		if rows < 1 || rows > 4 || rows*columns != a.Components {
+			return fmt.Errorf("attribute %q has %d components, which don't match its type 0x%x in program",
+				a.Name, a.Components, pa.xtype)
+		}
+	}
+
+	stride := layout.Stride()
+	for i, a := range layout {
+		pa, ok := m.attribs[a.Name]
+		if !ok || pa.location < 0 {
+			continue
+		}
+
+		columns := matrixColumns(pa.xtype)
+		rows := a.Components / columns
+		offset := uintptr(layout.Offset(i))
+		for c := int32(0); c < columns; c++ {
+			location := uint32(pa.location + c)
+			columnOffset := offset + uintptr(c*rows*glTypeSize(a.Type))
+			gl.EnableVertexAttribArray(location)
+			if isIntegerAttrib(pa.xtype) && !a.Normalized && a.Type != gl.FLOAT {
+				gl.VertexAttribIPointer(location, rows, a.Type, stride, gl.PtrOffset(int(columnOffset)))
+			} else {
+				gl.VertexAttribPointerWithOffset(location, rows, a.Type, a.Normalized, stride, columnOffset)
+			}
+			gl.VertexAttribDivisor(location, divisor)
+		}
+	}
+	return nil
+}
+
+// AttachInstances sources per-instance attributes of mesh from buffer,
+// replacing attributes of the same names previously attached.
+func (m *Mesh) AttachInstances(b *InstanceBuffer) error {
+	gl.BindVertexArray(m.vao)
+	b.buffer.Bind()
+	err := m.setupAttribs(b.layout, 1)
+	gl.BindVertexArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	return err
+}
+
+// VertexCount returns number of vertices.
+func (m *Mesh) VertexCount() int32 {
+	return m.vertexCount
+}
+
+// IndexCount returns number of indices, 0 if mesh isn't indexed.
+func (m *Mesh) IndexCount() int32 {
+	return m.indexCount
+}
+
+// Bounds returns bounding box of vertex positions, which is empty when
+// unknown (e.g. mesh created by NewMesh), such mesh is never culled.
+func (m *Mesh) Bounds() AABB {
+	return m.bounds
+}
+
+// SetBounds sets bounding box of vertex positions.
+func (m *Mesh) SetBounds(bounds AABB) {
+	m.bounds = bounds
+}
+
+// Location of attribute in program mesh was set up for, -1 if not active
+func (m *Mesh) attribLocation(name string) int32 {
+	if a, ok := m.attribs[name]; ok {
+		return a.location
+	}
+	return -1
+}
+
+// Draw renders the mesh once.
+func (m *Mesh) Draw() {
+	gl.BindVertexArray(m.vao)
+	if m.indexType != 0 {
+		gl.DrawElementsWithOffset(m.primitive, m.indexCount, m.indexType, 0)
+	} else {
+		gl.DrawArrays(m.primitive, 0, m.vertexCount)
+	}
+}
+
+// DrawInstanced renders multiple instances of the mesh.
+func (m *Mesh) DrawInstanced(instanceCount int32) {
+	gl.BindVertexArray(m.vao)
+	if m.indexType != 0 {
+		gl.DrawElementsInstanced(m.primitive, m.indexCount, m.indexType, nil, instanceCount)
+	} else {
+		gl.DrawArraysInstanced(m.primitive, 0, m.vertexCount, instanceCount)
+	}
+}
+
+// DrawInstances renders one instance of the mesh per element of
+// buffer, which must be attached to mesh.
+func (m *Mesh) DrawInstances(b *InstanceBuffer) {
+	if b.count > 0 {
+		m.DrawInstanced(b.count)
+	}
+}
+
+// MultiDrawIndirect issues multiple draws of (parts of) the mesh with
+// one call, falls back to separate draws when context doesn't support
+// ARB_multi_draw_indirect.
+func (m *Mesh) MultiDrawIndirect(commands []DrawCommand) {
+	if len(commands) == 0 {
+		return
+	}
+
+	gl.BindVertexArray(m.vao)
+	if !VersionAtLeast(4, 3) && !HasExtension("GL_ARB_multi_draw_indirect") {
+		for _, c := range commands {
+			if m.indexType != 0 {
+				offset := gl.PtrOffset(int(c.First) * int(glTypeSize(m.indexType)))
+				gl.DrawElementsInstancedBaseVertexBaseInstance(m.primitive, int32(c.Count), m.indexType,
+					offset, int32(c.InstanceCount), c.BaseVertex, c.BaseInstance)
+			} else {
+				gl.DrawArraysInstancedBaseInstance(m.primitive, int32(c.First), int32(c.Count),
+					int32(c.InstanceCount), c.BaseInstance)
+			}
+		}
+		return
+	}
+
+	if m.indirect == nil {
+		m.indirect = NewBuffer(gl.DRAW_INDIRECT_BUFFER, BufferDynamic, 0)
+	}
+	if m.indexType != 0 {
+		m.indirect.Upload(commands)
+		m.indirect.Bind()
+		gl.MultiDrawElementsIndirect(m.primitive, m.indexType, nil, int32(len(commands)), 0)
+	} else {
+		// Arrays commands have no base vertex
+		arrays := make([][4]uint32, len(commands))
+		for i, c := range commands {
+			arrays[i] = [4]uint32{c.Count, c.InstanceCount, c.First, c.BaseInstance}
+		}
+		m.indirect.Upload(arrays)
+		m.indirect.Bind()
+		gl.MultiDrawArraysIndirect(m.primitive, nil, int32(len(commands)), 0)
+	}
+	gl.BindBuffer(gl.DRAW_INDIRECT_BUFFER, 0)
+}
+
+// Dispose cleans up the resources.
+func (m *Mesh) Dispose() {
+	if m.vao != 0 {
+		gl.DeleteVertexArrays(1, &m.vao)
+	}
+	m.vao = 0
+	for _, vbo := range m.vbos {
+		vbo.Dispose()
+	}
+	m.vbos = nil
+	if m.ebo != nil {
+		m.ebo.Dispose()
+	}
+	m.ebo = nil
+	if m.indirect != nil {
+		m.indirect.Dispose()
+	}
+	m.indirect = nil
+}
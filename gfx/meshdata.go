@@ -0,0 +1,105 @@
+package gfx
+
+import (
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Names of vertex attributes used when uploading MeshData
+const (
+	AttribPosition  = "vert"
+	AttribNormal    = "vertNormal"
+	AttribTexCoord  = "vertTexCoord"
+	AttribTangent   = "vertTangent"
+	AttribColor     = "vertColor"
+	AttribTexCoord1 = "vertTexCoord1"
+	AttribJoints    = "vertJoints"
+	AttribWeights   = "vertWeights"
+)
+
+// Mesh data living in main memory, optional attributes are either empty
+// or have the same length as Positions.
+type MeshData struct {
+	Positions []mgl32.Vec3
+	Normals   []mgl32.Vec3
+	TexCoords []mgl32.Vec2
+	Tangents  []mgl32.Vec4 // xyz is tangent, w is handedness of bitangent
+	Colors    []mgl32.Vec4
+	Indices   []uint32 // triangle list, could be empty
+}
+
+// VertexCount returns number of vertices.
+func (d *MeshData) VertexCount() int {
+	return len(d.Positions)
+}
+
+// Upload creates mesh from data, every attribute is stored in separate stream.
+func (d *MeshData) Upload(program uint32) (*Mesh, error) {
+	streams, indices := d.vertexStreams()
+	m, err := NewMesh(program, gl.TRIANGLES, streams, indices)
+	if err != nil {
+		return nil, err
+	}
+	m.bounds = d.Bounds()
+	return m, nil
+}
+
+// UploadPoints creates mesh drawing vertices as points, indices are ignored.
+func (d *MeshData) UploadPoints(program uint32) (*Mesh, error) {
+	streams, _ := d.vertexStreams()
+	m, err := NewMesh(program, gl.POINTS, streams, nil)
+	if err != nil {
+		return nil, err
+	}
+	m.bounds = d.Bounds()
+	return m, nil
+}
+
+// Get vertex streams and indices of data
+func (d *MeshData) vertexStreams() ([]VertexStream, interface{}) {
+	streams := []VertexStream{
+		{
+			Layout: VertexLayout{{Name: AttribPosition, Components: 3, Type: gl.FLOAT}},
+			Data:   d.Positions,
+		},
+	}
+	if len(d.Normals) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribNormal, Components: 3, Type: gl.FLOAT}},
+			Data:   d.Normals,
+		})
+	}
+	if len(d.TexCoords) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribTexCoord, Components: 2, Type: gl.FLOAT}},
+			Data:   d.TexCoords,
+		})
+	}
+	if len(d.Tangents) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribTangent, Components: 4, Type: gl.FLOAT}},
+			Data:   d.Tangents,
+		})
+	}
+	if len(d.Colors) > 0 {
+		streams = append(streams, VertexStream{
+			Layout: VertexLayout{{Name: AttribColor, Components: 4, Type: gl.FLOAT}},
+			Data:   d.Colors,
+		})
+	}
+
+	var indices interface{}
+	if len(d.Indices) > 0 {
+		if len(d.Positions) <= 1<<16 {
+			indices16 := make([]uint16, len(d.Indices))
+			for i, idx := range d.Indices {
+				indices16[i] = uint16(idx)
+			}
+			indices = indices16
+		} else {
+			indices = d.Indices
+		}
+	}
+
+	return streams, indices
+}
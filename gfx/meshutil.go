@@ -0,0 +1,542 @@
+package gfx
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Get triangle indices, non-indexed data is treated as triangle list
+func (d *MeshData) triangleIndices() []uint32 {
+	if len(d.Indices) > 0 {
+		return d.Indices
+	}
+	indices := make([]uint32, len(d.Positions)/3*3)
+	for i := range indices {
+		indices[i] = uint32(i)
+	}
+	return indices
+}
+
+// Check that indices form whole triangles of existing vertices
+func (d *MeshData) checkTriangles() error {
+	if len(d.Indices)%3 != 0 {
+		return fmt.Errorf("index count %d isn't a multiple of 3", len(d.Indices))
+	}
+	for _, idx := range d.Indices {
+		if int(idx) >= len(d.Positions) {
+			return fmt.Errorf("vertex index %d out of range", idx)
+		}
+	}
+	return nil
+}
+
+// Check that optional attributes are either empty or have one element per vertex
+func (d *MeshData) checkAttributes() error {
+	attributes := []struct {
+		name  string
+		count int
+	}{
+		{"normals", len(d.Normals)},
+		{"texture coordinates", len(d.TexCoords)},
+		{"tangents", len(d.Tangents)},
+		{"colors", len(d.Colors)},
+	}
+	for _, a := range attributes {
+		if a.count > 0 && a.count != len(d.Positions) {
+			return fmt.Errorf("%d %s for %d vertices", a.count, a.name, len(d.Positions))
+		}
+	}
+	return nil
+}
+
+// Rebuild vertex attributes, i-th new vertex is copied from sources[i]
+func (d *MeshData) rebuild(sources []uint32) {
+	positions := make([]mgl32.Vec3, len(sources))
+	for i, s := range sources {
+		positions[i] = d.Positions[s]
+	}
+	d.Positions = positions
+	if len(d.Normals) > 0 {
+		normals := make([]mgl32.Vec3, len(sources))
+		for i, s := range sources {
+			normals[i] = d.Normals[s]
+		}
+		d.Normals = normals
+	}
+	if len(d.TexCoords) > 0 {
+		texCoords := make([]mgl32.Vec2, len(sources))
+		for i, s := range sources {
+			texCoords[i] = d.TexCoords[s]
+		}
+		d.TexCoords = texCoords
+	}
+	if len(d.Tangents) > 0 {
+		tangents := make([]mgl32.Vec4, len(sources))
+		for i, s := range sources {
+			tangents[i] = d.Tangents[s]
+		}
+		d.Tangents = tangents
+	}
+	if len(d.Colors) > 0 {
+		colors := make([]mgl32.Vec4, len(sources))
+		for i, s := range sources {
+			colors[i] = d.Colors[s]
+		}
+		d.Colors = colors
+	}
+}
+
+// Angle of triangle at vertex a, measured in plane perpendicular to normal n
+func cornerAngle(a, b, c, n mgl32.Vec3) float32 {
+	u, v := b.Sub(a), c.Sub(a)
+	u, v = u.Sub(n.Mul(n.Dot(u))), v.Sub(n.Mul(n.Dot(v)))
+	if u.Len() == 0 || v.Len() == 0 {
+		return 0
+	}
+	return float32(math.Acos(float64(mgl32.Clamp(u.Normalize().Dot(v.Normalize()), -1, 1))))
+}
+
+// ComputeNormals replaces normals with ones computed from faces. Faces sharing
+// a vertex position are smoothed when angle between them is no bigger than
+// creaseAngle (in radians), vertices are split where necessary. Use 0 for
+// flat shading and math.Pi for smoothing everything.
+func (d *MeshData) ComputeNormals(creaseAngle float32) error {
+	if err := d.checkTriangles(); err != nil {
+		return err
+	}
+	if err := d.checkAttributes(); err != nil {
+		return err
+	}
+
+	indices := d.triangleIndices()
+	triCount := len(indices) / 3
+
+	// Area-weighted and unit normals of faces
+	faceNormals := make([]mgl32.Vec3, triCount)
+	unitNormals := make([]mgl32.Vec3, triCount)
+	corners := map[mgl32.Vec3][]int{}
+	for t := 0; t < triCount; t++ {
+		a, b, c := d.Positions[indices[t*3]], d.Positions[indices[t*3+1]], d.Positions[indices[t*3+2]]
+		faceNormals[t] = b.Sub(a).Cross(c.Sub(a))
+		if l := faceNormals[t].Len(); l > 0 {
+			unitNormals[t] = faceNormals[t].Mul(1 / l)
+		}
+		for k := 0; k < 3; k++ {
+			p := d.Positions[indices[t*3+k]]
+			corners[p] = append(corners[p], t)
+		}
+	}
+
+	// Normal of every corner, vertex is split when its corners get different normals
+	cosThreshold := float32(math.Cos(float64(creaseAngle))) - 1e-6
+	type vertexKey struct {
+		vertex uint32
+		normal mgl32.Vec3
+	}
+	newVertices := map[vertexKey]uint32{}
+	sources := make([]uint32, 0, len(d.Positions))
+	normals := make([]mgl32.Vec3, 0, len(d.Positions))
+	newIndices := make([]uint32, len(indices))
+	for t := 0; t < triCount; t++ {
+		for k := 0; k < 3; k++ {
+			v := indices[t*3+k]
+			var n mgl32.Vec3
+			visited := map[int]bool{}
+			for _, other := range corners[d.Positions[v]] {
+				if visited[other] {
+					continue
+				}
+				visited[other] = true
+				if other == t || unitNormals[t].Dot(unitNormals[other]) >= cosThreshold {
+					n = n.Add(faceNormals[other])
+				}
+			}
+			if n.Len() > 0 {
+				n = n.Normalize()
+			}
+
+			key := vertexKey{vertex: v, normal: n}
+			idx, ok := newVertices[key]
+			if !ok {
+				idx = uint32(len(sources))
+				sources = append(sources, v)
+				normals = append(normals, n)
+				newVertices[key] = idx
+			}
+			newIndices[t*3+k] = idx
+		}
+	}
+
+	d.rebuild(sources)
+	d.Normals = normals
+	d.Indices = newIndices
+	return nil
+}
+
+// ComputeTangents computes tangents from normals and texture coordinates.
+// Tangents are angle-weighted averages orthogonalized against normal,
+// bitangent is w*cross(normal, tangent) and vertices shared by faces of
+// different handedness are split. Vertices with equal position, normal and
+// texture coordinates share tangent, faces with degenerated texture
+// coordinates don't contribute. This is close to MikkTSpace but not an
+// exact port of it, normal maps baked by MikkTSpace tools may show small
+// differences.
+func (d *MeshData) ComputeTangents() error {
+	if len(d.Normals) != len(d.Positions) || len(d.TexCoords) != len(d.Positions) {
+		return fmt.Errorf("normals and texture coordinates are needed to compute tangents")
+	}
+	if err := d.checkTriangles(); err != nil {
+		return err
+	}
+	if err := d.checkAttributes(); err != nil {
+		return err
+	}
+
+	indices := d.triangleIndices()
+	type vertexKey struct {
+		vertex   uint32
+		positive bool
+	}
+	type groupKey struct {
+		position, normal mgl32.Vec3
+		texCoord         mgl32.Vec2
+		positive         bool
+	}
+	newVertices := map[vertexKey]uint32{}
+	groups := map[groupKey]int{}
+	sources := make([]uint32, 0, len(d.Positions))
+	vertexGroups := make([]int, 0, len(d.Positions))
+	signs := make([]float32, 0, len(d.Positions))
+	sums := make([]mgl32.Vec3, 0, len(d.Positions))
+	vertex := func(v uint32, positive bool) uint32 {
+		key := vertexKey{vertex: v, positive: positive}
+		if idx, ok := newVertices[key]; ok {
+			return idx
+		}
+		gk := groupKey{d.Positions[v], d.Normals[v], d.TexCoords[v], positive}
+		g, ok := groups[gk]
+		if !ok {
+			g = len(sums)
+			sums = append(sums, mgl32.Vec3{})
+			groups[gk] = g
+		}
+		idx := uint32(len(sources))
+		sources = append(sources, v)
+		vertexGroups = append(vertexGroups, g)
+		if positive {
+			signs = append(signs, 1)
+		} else {
+			signs = append(signs, -1)
+		}
+		newVertices[key] = idx
+		return idx
+	}
+
+	newIndices := make([]uint32, len(indices))
+	var degenerated []int
+	for t := 0; t+2 < len(indices); t += 3 {
+		i0, i1, i2 := indices[t], indices[t+1], indices[t+2]
+		p0, p1, p2 := d.Positions[i0], d.Positions[i1], d.Positions[i2]
+		w0, w1, w2 := d.TexCoords[i0], d.TexCoords[i1], d.TexCoords[i2]
+
+		// Handedness is sign of area in texture space, direction of
+		// increasing u is tangent of face
+		e1, e2 := p1.Sub(p0), p2.Sub(p0)
+		d1, d2 := w1.Sub(w0), w2.Sub(w0)
+		det := d1[0]*d2[1] - d2[0]*d1[1]
+		var sdir mgl32.Vec3
+		if det != 0 {
+			sdir = e1.Mul(d2[1]).Sub(e2.Mul(d1[1])).Mul(1 / det)
+		}
+		l := sdir.Len()
+		if l == 0 {
+			degenerated = append(degenerated, t)
+			continue
+		}
+		sdir = sdir.Mul(1 / l)
+
+		for k, v := range [3]uint32{i0, i1, i2} {
+			idx := vertex(v, det > 0)
+			newIndices[t+k] = idx
+			n := d.Normals[v]
+			tangent := sdir.Sub(n.Mul(n.Dot(sdir)))
+			if l := tangent.Len(); l > 0 {
+				pb, pc := d.Positions[indices[t+(k+1)%3]], d.Positions[indices[t+(k+2)%3]]
+				g := vertexGroups[idx]
+				sums[g] = sums[g].Add(tangent.Mul(cornerAngle(d.Positions[v], pb, pc, n) / l))
+			}
+		}
+	}
+
+	// Faces with degenerated texture coordinates reuse vertices of neighbours
+	for _, t := range degenerated {
+		for k, v := range indices[t : t+3] {
+			idx, ok := newVertices[vertexKey{vertex: v, positive: true}]
+			if !ok {
+				idx, ok = newVertices[vertexKey{vertex: v, positive: false}]
+			}
+			if !ok {
+				idx = vertex(v, true)
+			}
+			newIndices[t+k] = idx
+		}
+	}
+
+	d.rebuild(sources)
+	d.Tangents = make([]mgl32.Vec4, len(sources))
+	for i, g := range vertexGroups {
+		n := d.Normals[i]
+		tangent := sums[g].Sub(n.Mul(n.Dot(sums[g])))
+		if tangent.Len() < 1e-12 {
+			// Only degenerated faces, any direction perpendicular to normal will do
+			tangent = mgl32.Vec3{1, 0, 0}
+			if math.Abs(float64(n[0])) > 0.9 {
+				tangent = mgl32.Vec3{0, 1, 0}
+			}
+			tangent = tangent.Sub(n.Mul(n.Dot(tangent)))
+		}
+		d.Tangents[i] = tangent.Normalize().Vec4(signs[i])
+	}
+	d.Indices = newIndices
+	return nil
+}
+
+// Weld merges vertices whose attributes all differ less than epsilon,
+// returns number of removed vertices.
+func (d *MeshData) Weld(epsilon float32) (int, error) {
+	if err := d.checkTriangles(); err != nil {
+		return 0, err
+	}
+	if err := d.checkAttributes(); err != nil {
+		return 0, err
+	}
+	if epsilon <= 0 {
+		epsilon = 1e-6
+	}
+	near := func(a, b []float32) bool {
+		for i := range a {
+			if float32(math.Abs(float64(a[i]-b[i]))) > epsilon {
+				return false
+			}
+		}
+		return true
+	}
+	same := func(i, j uint32) bool {
+		if !near(d.Positions[i][:], d.Positions[j][:]) {
+			return false
+		}
+		if len(d.Normals) > 0 && !near(d.Normals[i][:], d.Normals[j][:]) {
+			return false
+		}
+		if len(d.TexCoords) > 0 && !near(d.TexCoords[i][:], d.TexCoords[j][:]) {
+			return false
+		}
+		if len(d.Tangents) > 0 && !near(d.Tangents[i][:], d.Tangents[j][:]) {
+			return false
+		}
+		if len(d.Colors) > 0 && !near(d.Colors[i][:], d.Colors[j][:]) {
+			return false
+		}
+		return true
+	}
+
+	// Spatial hashing of positions, neighbour cells are checked as well
+	type cell [3]int64
+	cellOf := func(p mgl32.Vec3) cell {
+		return cell{
+			int64(math.Floor(float64(p[0] / epsilon))),
+			int64(math.Floor(float64(p[1] / epsilon))),
+			int64(math.Floor(float64(p[2] / epsilon))),
+		}
+	}
+	grid := map[cell][]uint32{}
+	remap := make([]uint32, len(d.Positions))
+	sources := make([]uint32, 0, len(d.Positions))
+	for i, p := range d.Positions {
+		c := cellOf(p)
+		found := false
+	SEARCH:
+		for x := c[0] - 1; x <= c[0]+1; x++ {
+			for y := c[1] - 1; y <= c[1]+1; y++ {
+				for z := c[2] - 1; z <= c[2]+1; z++ {
+					for _, j := range grid[cell{x, y, z}] {
+						if same(uint32(i), sources[j]) {
+							remap[i] = j
+							found = true
+							break SEARCH
+						}
+					}
+				}
+			}
+		}
+		if !found {
+			remap[i] = uint32(len(sources))
+			grid[c] = append(grid[c], uint32(len(sources)))
+			sources = append(sources, uint32(i))
+		}
+	}
+
+	removed := len(d.Positions) - len(sources)
+	indices := d.triangleIndices()
+	newIndices := make([]uint32, len(indices))
+	for i, idx := range indices {
+		newIndices[i] = remap[idx]
+	}
+	d.rebuild(sources)
+	d.Indices = newIndices
+	return removed, nil
+}
+
+// Parameters of vertex cache optimization
+const (
+	vertexCacheSize         = 32
+	vertexCacheDecayPower   = 1.5
+	vertexCacheLastTriScore = 0.75
+	vertexValenceBoostScale = 2.0
+	vertexValenceBoostPower = 0.5
+)
+
+// OptimizeVertexCache reorders triangles to improve hit rate of
+// post-transform vertex cache, using Tom Forsyth's algorithm. Indices must
+// form whole triangles.
+func (d *MeshData) OptimizeVertexCache() error {
+	if err := d.checkTriangles(); err != nil {
+		return err
+	}
+	indices := d.triangleIndices()
+	triCount := len(indices) / 3
+	if triCount == 0 {
+		return nil
+	}
+
+	type vertexState struct {
+		cachePos  int
+		score     float32
+		triangles []int // triangles not added yet
+	}
+	vertices := make([]vertexState, len(d.Positions))
+	for i := range vertices {
+		vertices[i].cachePos = -1
+	}
+	for t := 0; t < triCount; t++ {
+		for k := 0; k < 3; k++ {
+			v := &vertices[indices[t*3+k]]
+			v.triangles = append(v.triangles, t)
+		}
+	}
+	vertexScore := func(v *vertexState) float32 {
+		if len(v.triangles) == 0 {
+			return -1
+		}
+		var score float64
+		if v.cachePos >= 0 {
+			if v.cachePos < 3 {
+				score = vertexCacheLastTriScore
+			} else {
+				scale := 1.0 / (vertexCacheSize - 3)
+				score = math.Pow(1-float64(v.cachePos-3)*scale, vertexCacheDecayPower)
+			}
+		}
+		score += vertexValenceBoostScale * math.Pow(float64(len(v.triangles)), -vertexValenceBoostPower)
+		return float32(score)
+	}
+	for i := range vertices {
+		vertices[i].score = vertexScore(&vertices[i])
+	}
+	triScore := func(t int) float32 {
+		return vertices[indices[t*3]].score + vertices[indices[t*3+1]].score + vertices[indices[t*3+2]].score
+	}
+
+	added := make([]bool, triCount)
+	result := make([]uint32, 0, len(indices))
+	cache := make([]uint32, 0, vertexCacheSize+3)
+	nextUnadded := 0
+	best := -1
+	for len(result) < len(indices) {
+		if best < 0 {
+			// No candidate in cache, pick first unadded triangle
+			for added[nextUnadded] {
+				nextUnadded++
+			}
+			best = nextUnadded
+		}
+
+		added[best] = true
+		tri := indices[best*3 : best*3+3]
+		result = append(result, tri...)
+		for _, idx := range tri {
+			v := &vertices[idx]
+			for i, t := range v.triangles {
+				if t == best {
+					v.triangles = append(v.triangles[:i], v.triangles[i+1:]...)
+					break
+				}
+			}
+		}
+
+		// Move vertices of triangle to front of cache
+		newCache := make([]uint32, 0, vertexCacheSize+3)
+		newCache = append(newCache, tri...)
+		for _, idx := range cache {
+			if idx != tri[0] && idx != tri[1] && idx != tri[2] {
+				newCache = append(newCache, idx)
+			}
+		}
+		for i, idx := range newCache {
+			if i < vertexCacheSize {
+				vertices[idx].cachePos = i
+			} else {
+				vertices[idx].cachePos = -1
+			}
+			vertices[idx].score = vertexScore(&vertices[idx])
+		}
+		if len(newCache) > vertexCacheSize {
+			newCache = newCache[:vertexCacheSize]
+		}
+		cache = newCache
+
+		// Best next triangle is one using vertices in cache
+		best = -1
+		var bestScore float32 = -1
+		for _, idx := range cache {
+			for _, t := range vertices[idx].triangles {
+				if s := triScore(t); s > bestScore {
+					best, bestScore = t, s
+				}
+			}
+		}
+	}
+	d.Indices = result
+	return nil
+}
+
+// OptimizeVertexFetch reorders vertices by order of first use, which
+// improves memory locality of vertex fetching. Unreferenced vertices
+// are removed. Indices must form whole triangles.
+func (d *MeshData) OptimizeVertexFetch() error {
+	if err := d.checkTriangles(); err != nil {
+		return err
+	}
+	if err := d.checkAttributes(); err != nil {
+		return err
+	}
+	indices := d.triangleIndices()
+	remap := make([]int64, len(d.Positions))
+	for i := range remap {
+		remap[i] = -1
+	}
+	sources := make([]uint32, 0, len(d.Positions))
+	newIndices := make([]uint32, len(indices))
+	for i, idx := range indices {
+		if remap[idx] < 0 {
+			remap[idx] = int64(len(sources))
+			sources = append(sources, idx)
+		}
+		newIndices[i] = uint32(remap[idx])
+	}
+	d.rebuild(sources)
+	d.Indices = newIndices
+	return nil
+}
@@ -0,0 +1,385 @@
+package gfx
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Two triangles sharing edge 0-1, folded by 90 degrees
+func foldedMesh() *MeshData {
+	return &MeshData{
+		Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+		Indices:   []uint32{0, 1, 2, 0, 3, 1},
+	}
+}
+
+func TestComputeNormals(t *testing.T) {
+	diagonal := mgl32.Vec3{0, 1, 1}.Normalize()
+	tests := []struct {
+		name        string
+		mesh        *MeshData
+		creaseAngle float32
+		normals     []mgl32.Vec3
+		indices     []uint32
+	}{
+		{
+			name: "flat quad keeps vertices",
+			mesh: &MeshData{
+				Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+				Indices:   []uint32{0, 1, 2, 0, 2, 3},
+			},
+			normals: []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+			indices: []uint32{0, 1, 2, 0, 2, 3},
+		},
+		{
+			name:    "crease splits shared vertices",
+			mesh:    foldedMesh(),
+			normals: []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 1, 0}, {0, 1, 0}, {0, 1, 0}},
+			indices: []uint32{0, 1, 2, 3, 4, 5},
+		},
+		{
+			name:        "smoothing averages shared vertices",
+			mesh:        foldedMesh(),
+			creaseAngle: math.Pi,
+			normals:     []mgl32.Vec3{diagonal, diagonal, {0, 0, 1}, {0, 1, 0}},
+			indices:     []uint32{0, 1, 2, 0, 3, 1},
+		},
+		{
+			name:    "non-indexed triangle",
+			mesh:    &MeshData{Positions: []mgl32.Vec3{{0, 0, 0}, {0, 1, 0}, {1, 0, 0}}},
+			normals: []mgl32.Vec3{{0, 0, -1}, {0, 0, -1}, {0, 0, -1}},
+			indices: []uint32{0, 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.mesh
+			if err := d.ComputeNormals(tt.creaseAngle); err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Positions) != len(tt.normals) || len(d.Normals) != len(tt.normals) {
+				t.Fatalf("got %d positions and %d normals, want %d", len(d.Positions), len(d.Normals), len(tt.normals))
+			}
+			for i, n := range tt.normals {
+				if !d.Normals[i].ApproxEqual(n) {
+					t.Errorf("got normals %v, want %v", d.Normals, tt.normals)
+					break
+				}
+			}
+			if !equalIndices(d.Indices, tt.indices) {
+				t.Errorf("got indices %v, want %v", d.Indices, tt.indices)
+			}
+		})
+	}
+}
+
+func TestComputeTangents(t *testing.T) {
+	tests := []struct {
+		name    string
+		uScale  float32
+		tangent mgl32.Vec4
+	}{
+		{"right-handed", 1, mgl32.Vec4{1, 0, 0, 1}},
+		{"mirrored", -1, mgl32.Vec4{-1, 0, 0, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &MeshData{
+				Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+				Normals:   []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+				TexCoords: []mgl32.Vec2{{0, 0}, {tt.uScale, 0}, {tt.uScale, 1}, {0, 1}},
+				Indices:   []uint32{0, 1, 2, 0, 2, 3},
+			}
+			if err := d.ComputeTangents(); err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Tangents) != 4 || len(d.Positions) != 4 {
+				t.Fatalf("got %d tangents of %d vertices, want 4", len(d.Tangents), len(d.Positions))
+			}
+			for _, tangent := range d.Tangents {
+				if !tangent.ApproxEqual(tt.tangent) {
+					t.Errorf("got tangents %v, want %v", d.Tangents, tt.tangent)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestComputeTangentsSharing(t *testing.T) {
+	// Faces have different tangents, vertices 1 and 2 are on both
+	positions := []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}}
+	texCoords := []mgl32.Vec2{{0, 0}, {1, 0}, {0, 1}, {1, 2}}
+	shared := &MeshData{Indices: []uint32{0, 1, 2, 1, 3, 2}}
+	split := &MeshData{Indices: []uint32{0, 1, 2, 3, 4, 5}}
+	for _, idx := range shared.Indices {
+		split.Positions = append(split.Positions, positions[idx])
+		split.TexCoords = append(split.TexCoords, texCoords[idx])
+	}
+	shared.Positions, shared.TexCoords = positions, texCoords
+	for _, d := range []*MeshData{shared, split} {
+		for range d.Positions {
+			d.Normals = append(d.Normals, mgl32.Vec3{0, 0, 1})
+		}
+		if err := d.ComputeTangents(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := range shared.Indices {
+		a, b := shared.Tangents[shared.Indices[i]], split.Tangents[split.Indices[i]]
+		if !a.ApproxEqual(b) {
+			t.Errorf("corner %d: got tangent %v of split vertex, want %v", i, b, a)
+		}
+	}
+	if tangent := shared.Tangents[shared.Indices[1]]; tangent.ApproxEqual(mgl32.Vec4{1, 0, 0, 1}) {
+		t.Errorf("tangent %v of shared vertex isn't averaged", tangent)
+	}
+}
+
+func TestComputeTangentsDegenerated(t *testing.T) {
+	// Mirrored quad and face with collinear texture coordinates on top of it
+	d := &MeshData{
+		Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0.5, 2, 0}},
+		Normals:   []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+		TexCoords: []mgl32.Vec2{{0, 0}, {-1, 0}, {-1, 1}, {0, 1}, {-0.5, 1}},
+		Indices:   []uint32{0, 1, 2, 0, 2, 3, 3, 2, 4},
+	}
+	if err := d.ComputeTangents(); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Positions) != 5 {
+		t.Fatalf("got %d vertices, want 5", len(d.Positions))
+	}
+	for i, tangent := range d.Tangents {
+		if !tangent.ApproxEqual(mgl32.Vec4{-1, 0, 0, -1}) && i != int(d.Indices[8]) {
+			t.Errorf("got tangents %v, want them of mirrored quad", d.Tangents)
+			break
+		}
+	}
+	if tangent := d.Tangents[d.Indices[8]]; tangent.Vec3().Dot(mgl32.Vec3{0, 0, 1}) != 0 || tangent.Vec3().Len() < 0.99 {
+		t.Errorf("got tangent %v of vertex used only by degenerated face", tangent)
+	}
+}
+
+func TestMeshUtilErrors(t *testing.T) {
+	quad := func(indices ...uint32) *MeshData {
+		return &MeshData{
+			Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+			Normals:   []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 0, 1}},
+			TexCoords: []mgl32.Vec2{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+			Indices:   indices,
+		}
+	}
+	operations := []struct {
+		name     string
+		run      func(d *MeshData) error
+		rebuilds bool // whether vertex attributes are rebuilt and checked
+	}{
+		{"ComputeNormals", func(d *MeshData) error { return d.ComputeNormals(0) }, true},
+		{"ComputeTangents", (*MeshData).ComputeTangents, true},
+		{"Weld", func(d *MeshData) error { _, err := d.Weld(0); return err }, true},
+		{"OptimizeVertexCache", (*MeshData).OptimizeVertexCache, false},
+		{"OptimizeVertexFetch", (*MeshData).OptimizeVertexFetch, true},
+	}
+	shortColors := quad(0, 1, 2)
+	shortColors.Colors = []mgl32.Vec4{{1, 1, 1, 1}}
+	tests := []struct {
+		name       string
+		mesh       *MeshData
+		err        string
+		attributes bool
+	}{
+		{"partial triangle", quad(0, 1, 2, 0), "index count 4 isn't a multiple of 3", false},
+		{"index out of range", quad(0, 1, 4), "vertex index 4 out of range", false},
+		{"short attribute", shortColors, "1 colors for 4 vertices", true},
+	}
+	for _, op := range operations {
+		for _, tt := range tests {
+			if tt.attributes && !op.rebuilds {
+				continue
+			}
+			t.Run(op.name+"/"+tt.name, func(t *testing.T) {
+				d := *tt.mesh
+				err := op.run(&d)
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				if !equalIndices(d.Indices, tt.mesh.Indices) || len(d.Positions) != 4 || len(d.Normals) != 4 {
+					t.Errorf("mesh was modified: %v", d.Indices)
+				}
+			})
+		}
+	}
+
+	d := quad(0, 1, 2)
+	d.TexCoords = nil
+	if err := d.ComputeTangents(); err == nil {
+		t.Error("got no error for mesh without texture coordinates")
+	}
+}
+
+func TestWeld(t *testing.T) {
+	tests := []struct {
+		name      string
+		mesh      *MeshData
+		epsilon   float32
+		removed   int
+		positions int
+		indices   []uint32
+	}{
+		{
+			name: "duplicated positions",
+			mesh: &MeshData{Positions: []mgl32.Vec3{
+				{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 0, 0}, {1, 1, 0}, {0, 1, 0},
+			}},
+			removed:   2,
+			positions: 4,
+			indices:   []uint32{0, 1, 2, 0, 2, 3},
+		},
+		{
+			name: "within epsilon",
+			mesh: &MeshData{
+				Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0.001, 0, 0}},
+				Indices:   []uint32{0, 1, 2, 3, 1, 2},
+			},
+			epsilon:   0.01,
+			removed:   1,
+			positions: 3,
+			indices:   []uint32{0, 1, 2, 0, 1, 2},
+		},
+		{
+			name: "different normals are kept",
+			mesh: &MeshData{
+				Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 0}},
+				Normals:   []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 1, 0}},
+				Indices:   []uint32{0, 1, 2, 3, 1, 2},
+			},
+			positions: 4,
+			indices:   []uint32{0, 1, 2, 3, 1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.mesh
+			removed, err := d.Weld(tt.epsilon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != tt.removed {
+				t.Errorf("got %d removed vertices, want %d", removed, tt.removed)
+			}
+			if len(d.Positions) != tt.positions || (len(d.Normals) > 0 && len(d.Normals) != tt.positions) {
+				t.Errorf("got %d positions and %d normals, want %d", len(d.Positions), len(d.Normals), tt.positions)
+			}
+			if !equalIndices(d.Indices, tt.indices) {
+				t.Errorf("got indices %v, want %v", d.Indices, tt.indices)
+			}
+		})
+	}
+}
+
+// Count misses of FIFO vertex cache
+func cacheMisses(indices []uint32, size int) int {
+	var cache []uint32
+	misses := 0
+	for _, idx := range indices {
+		hit := false
+		for _, c := range cache {
+			if c == idx {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			misses++
+			cache = append(cache, idx)
+			if len(cache) > size {
+				cache = cache[1:]
+			}
+		}
+	}
+	return misses
+}
+
+// Triangles as sorted list of index triples
+func sortedTriangles(indices []uint32) [][3]uint32 {
+	tris := make([][3]uint32, len(indices)/3)
+	for i := range tris {
+		copy(tris[i][:], indices[i*3:i*3+3])
+	}
+	sort.Slice(tris, func(i, j int) bool {
+		for k := 0; k < 3; k++ {
+			if tris[i][k] != tris[j][k] {
+				return tris[i][k] < tris[j][k]
+			}
+		}
+		return false
+	})
+	return tris
+}
+
+func TestOptimizeVertexCache(t *testing.T) {
+	d := GenPlane(1, 1, 16, 16)
+	rng := rand.New(rand.NewSource(1))
+	triCount := len(d.Indices) / 3
+	shuffled := make([]uint32, 0, len(d.Indices))
+	for _, tri := range rng.Perm(triCount) {
+		shuffled = append(shuffled, d.Indices[tri*3:tri*3+3]...)
+	}
+	d.Indices = shuffled
+	vertexCount := len(d.Positions)
+
+	if err := d.OptimizeVertexCache(); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Positions) != vertexCount {
+		t.Errorf("got %d vertices, want %d", len(d.Positions), vertexCount)
+	}
+	got, want := sortedTriangles(d.Indices), sortedTriangles(shuffled)
+	if len(got) != len(want) {
+		t.Fatalf("got %d triangles, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("triangle %v isn't in input", got[i])
+		}
+	}
+	if before, after := cacheMisses(shuffled, 16), cacheMisses(d.Indices, 16); after >= before {
+		t.Errorf("got %d cache misses, had %d before", after, before)
+	}
+
+	empty := &MeshData{}
+	if err := empty.OptimizeVertexCache(); err != nil || len(empty.Indices) != 0 {
+		t.Errorf("got error %v and indices %v for empty mesh", err, empty.Indices)
+	}
+}
+
+func TestOptimizeVertexFetch(t *testing.T) {
+	d := &MeshData{
+		Positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}, {3, 0, 0}, {4, 0, 0}},
+		Colors:    []mgl32.Vec4{{0, 0, 0, 1}, {1, 0, 0, 1}, {2, 0, 0, 1}, {3, 0, 0, 1}, {4, 0, 0, 1}},
+		Indices:   []uint32{2, 0, 1, 2, 1, 3},
+	}
+	if err := d.OptimizeVertexFetch(); err != nil {
+		t.Fatal(err)
+	}
+	positions := []mgl32.Vec3{{2, 0, 0}, {0, 0, 0}, {1, 0, 0}, {3, 0, 0}}
+	if len(d.Positions) != len(positions) || len(d.Colors) != len(positions) {
+		t.Fatalf("got positions %v and %d colors, want %v", d.Positions, len(d.Colors), positions)
+	}
+	for i, p := range positions {
+		if d.Positions[i] != p || d.Colors[i][0] != p[0] {
+			t.Errorf("got positions %v and colors %v, want %v", d.Positions, d.Colors, positions)
+			break
+		}
+	}
+	if want := []uint32{0, 1, 2, 0, 2, 3}; !equalIndices(d.Indices, want) {
+		t.Errorf("got indices %v, want %v", d.Indices, want)
+	}
+}
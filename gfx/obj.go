@@ -0,0 +1,578 @@
+package gfx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Material described in wavefront MTL file, texture paths are
+// relative to the directory of OBJ file.
+type ObjMaterial struct {
+	Name         string
+	Ambient      mgl32.Vec3
+	Diffuse      mgl32.Vec3
+	Specular     mgl32.Vec3
+	Emissive     mgl32.Vec3
+	Shininess    float32
+	Opacity      float32
+	Illumination int
+	AmbientMap   string
+	DiffuseMap   string
+	SpecularMap  string
+	EmissiveMap  string
+	ShininessMap string
+	AlphaMap     string
+	BumpMap      string
+	NormalMap    string
+}
+
+// Part of OBJ model sharing the same object, group and material
+type ObjMesh struct {
+	Object   string
+	Group    string
+	Material string
+	Data     MeshData
+}
+
+// Model loaded from wavefront OBJ file
+type ObjModel struct {
+	Meshes    []*ObjMesh
+	Materials map[string]*ObjMaterial
+}
+
+// LoadObj loads OBJ model and its material libraries from disk.
+func LoadObj(file string) (*ObjModel, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found on disk: %v", file, err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(file)
+	return parseObj(f, file, func(name string) (io.ReadCloser, string, error) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		r, err := os.Open(p)
+		return r, p, err
+	})
+}
+
+// LoadObjFS loads OBJ model and its material libraries from filesystem fsys.
+func LoadObjFS(fsys fs.FS, name string) (*ObjModel, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found in filesystem: %v", name, err)
+	}
+	defer f.Close()
+
+	dir := path.Dir(name)
+	return parseObj(f, name, func(name string) (io.ReadCloser, string, error) {
+		p := path.Join(dir, name)
+		r, err := fsys.Open(p)
+		return r, p, err
+	})
+}
+
+// ReadObj parses OBJ model from r, material libraries are ignored.
+func ReadObj(r io.Reader) (*ObjModel, error) {
+	return parseObj(r, "<obj>", nil)
+}
+
+// Opens file referenced by OBJ/MTL file, returns reader and resolved path
+type objOpener func(name string) (io.ReadCloser, string, error)
+
+// Index of vertex attributes referenced by face, -1 means absent
+type objVertex struct {
+	v, vt, vn int
+}
+
+// State of OBJ parser
+type objParser struct {
+	name      string
+	line      int
+	model     *ObjModel
+	positions []mgl32.Vec3
+	colors    []mgl32.Vec4
+	texCoords []mgl32.Vec2
+	normals   []mgl32.Vec3
+	object    string
+	group     string
+	material  string
+	current   *objMeshBuilder
+	builders  []*objMeshBuilder
+}
+
+// Builder of indexed mesh, deduplicating vertices
+type objMeshBuilder struct {
+	mesh         *ObjMesh
+	indices      map[objVertex]uint32
+	hasTexCoords bool
+	hasNormals   bool
+	hasColors    bool
+}
+
+func (p *objParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.line, fmt.Sprintf(format, args...))
+}
+
+func parseObj(r io.Reader, name string, open objOpener) (*ObjModel, error) {
+	p := &objParser{
+		name: name,
+		model: &ObjModel{
+			Materials: map[string]*ObjMaterial{},
+		},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		p.line++
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		switch fields[0] {
+		case "v":
+			err = p.parseVertex(fields[1:])
+		case "vt":
+			var vt []float32
+			vt, err = p.parseFloats(fields[1:], 1, 3)
+			if err == nil {
+				uv := mgl32.Vec2{vt[0]}
+				if len(vt) > 1 {
+					uv[1] = vt[1]
+				}
+				p.texCoords = append(p.texCoords, uv)
+			}
+		case "vn":
+			var vn []float32
+			vn, err = p.parseFloats(fields[1:], 3, 3)
+			if err == nil {
+				p.normals = append(p.normals, mgl32.Vec3{vn[0], vn[1], vn[2]})
+			}
+		case "f":
+			err = p.parseFace(fields[1:])
+		case "o":
+			p.object = strings.Join(fields[1:], " ")
+			p.group = ""
+			p.current = nil
+		case "g":
+			p.group = strings.Join(fields[1:], " ")
+			p.current = nil
+		case "usemtl":
+			if len(fields) < 2 {
+				err = p.errorf("missing material name")
+				break
+			}
+			p.material = strings.Join(fields[1:], " ")
+			p.current = nil
+		case "mtllib":
+			if len(fields) < 2 {
+				err = p.errorf("missing material library name")
+				break
+			}
+			if open == nil {
+				break
+			}
+			for _, lib := range fields[1:] {
+				if err = p.loadMtl(lib, open); err != nil {
+					break
+				}
+			}
+		default:
+			// Smoothing groups, lines, points and free-form geometry aren't supported
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	for _, b := range p.builders {
+		if !b.hasTexCoords {
+			b.mesh.Data.TexCoords = nil
+		}
+		if !b.hasNormals {
+			b.mesh.Data.Normals = nil
+		}
+		if !b.hasColors {
+			b.mesh.Data.Colors = nil
+		}
+		if len(b.mesh.Data.Indices) > 0 {
+			p.model.Meshes = append(p.model.Meshes, b.mesh)
+		}
+	}
+	return p.model, nil
+}
+
+// Remove trailing comment from line
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func (p *objParser) parseFloats(fields []string, min, max int) ([]float32, error) {
+	if len(fields) < min || len(fields) > max {
+		if min == max {
+			return nil, p.errorf("expect %d numbers, got %d", min, len(fields))
+		}
+		return nil, p.errorf("expect %d to %d numbers, got %d", min, max, len(fields))
+	}
+	values := make([]float32, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", f)
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+// Parse vertex position, with optional weight or vertex color
+func (p *objParser) parseVertex(fields []string) error {
+	v, err := p.parseFloats(fields, 3, 7)
+	if err != nil {
+		return err
+	}
+	p.positions = append(p.positions, mgl32.Vec3{v[0], v[1], v[2]})
+	if len(v) >= 6 {
+		c := mgl32.Vec4{v[3], v[4], v[5], 1}
+		if len(v) == 7 {
+			c = mgl32.Vec4{v[4], v[5], v[6], 1}
+		}
+		for len(p.colors) < len(p.positions)-1 {
+			p.colors = append(p.colors, mgl32.Vec4{1, 1, 1, 1})
+		}
+		p.colors = append(p.colors, c)
+	}
+	return nil
+}
+
+// Resolve (possibly negative) index of OBJ element
+func (p *objParser) resolveIndex(s string, count int, kind string) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, p.errorf("invalid %s index %q", kind, s)
+	}
+	switch {
+	case i > 0 && i <= count:
+		return i - 1, nil
+	case i < 0 && -i <= count:
+		return count + i, nil
+	}
+	return 0, p.errorf("%s index %d out of range [1, %d]", kind, i, count)
+}
+
+// Parse face vertex in format of v, v/vt, v//vn or v/vt/vn
+func (p *objParser) parseFaceVertex(s string) (objVertex, error) {
+	vert := objVertex{v: -1, vt: -1, vn: -1}
+	parts := strings.Split(s, "/")
+	if len(parts) > 3 || parts[0] == "" {
+		return vert, p.errorf("invalid face vertex %q", s)
+	}
+
+	var err error
+	if vert.v, err = p.resolveIndex(parts[0], len(p.positions), "vertex"); err != nil {
+		return vert, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if vert.vt, err = p.resolveIndex(parts[1], len(p.texCoords), "texture coordinate"); err != nil {
+			return vert, err
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if vert.vn, err = p.resolveIndex(parts[2], len(p.normals), "normal"); err != nil {
+			return vert, err
+		}
+	}
+	return vert, nil
+}
+
+func (p *objParser) parseFace(fields []string) error {
+	if len(fields) < 3 {
+		return p.errorf("face needs at least 3 vertices, got %d", len(fields))
+	}
+	verts := make([]objVertex, len(fields))
+	points := make([]mgl32.Vec3, len(fields))
+	for i, f := range fields {
+		v, err := p.parseFaceVertex(f)
+		if err != nil {
+			return err
+		}
+		verts[i] = v
+		points[i] = p.positions[v.v]
+	}
+
+	b := p.builder()
+	for _, tri := range triangulatePolygon(points) {
+		for _, i := range tri {
+			b.mesh.Data.Indices = append(b.mesh.Data.Indices, p.addVertex(b, verts[i]))
+		}
+	}
+	return nil
+}
+
+// Get builder of current object/group/material, create one if necessary
+func (p *objParser) builder() *objMeshBuilder {
+	if p.current != nil {
+		return p.current
+	}
+	for _, b := range p.builders {
+		m := b.mesh
+		if m.Object == p.object && m.Group == p.group && m.Material == p.material {
+			p.current = b
+			return b
+		}
+	}
+	p.current = &objMeshBuilder{
+		mesh: &ObjMesh{
+			Object:   p.object,
+			Group:    p.group,
+			Material: p.material,
+		},
+		indices: map[objVertex]uint32{},
+	}
+	p.builders = append(p.builders, p.current)
+	return p.current
+}
+
+// Add vertex to mesh if not added yet, return its index
+func (p *objParser) addVertex(b *objMeshBuilder, v objVertex) uint32 {
+	if idx, ok := b.indices[v]; ok {
+		return idx
+	}
+
+	d := &b.mesh.Data
+	idx := uint32(len(d.Positions))
+	d.Positions = append(d.Positions, p.positions[v.v])
+	if v.vt >= 0 {
+		d.TexCoords = append(d.TexCoords, p.texCoords[v.vt])
+		b.hasTexCoords = true
+	} else {
+		d.TexCoords = append(d.TexCoords, mgl32.Vec2{})
+	}
+	if v.vn >= 0 {
+		d.Normals = append(d.Normals, p.normals[v.vn])
+		b.hasNormals = true
+	} else {
+		d.Normals = append(d.Normals, mgl32.Vec3{})
+	}
+	if v.v < len(p.colors) {
+		d.Colors = append(d.Colors, p.colors[v.v])
+		b.hasColors = true
+	} else {
+		d.Colors = append(d.Colors, mgl32.Vec4{1, 1, 1, 1})
+	}
+	b.indices[v] = idx
+	return idx
+}
+
+// Load MTL file and merge its materials into model
+func (p *objParser) loadMtl(name string, open objOpener) error {
+	r, file, err := open(name)
+	if err != nil {
+		return p.errorf("material library %q not found: %v", name, err)
+	}
+	defer r.Close()
+
+	mp := &objParser{name: file}
+	mtlDir := path.Dir(filepath.ToSlash(name))
+	var mtl *ObjMaterial
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		mp.line++
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "newmtl" {
+			if len(fields) < 2 {
+				return mp.errorf("missing material name")
+			}
+			mtl = &ObjMaterial{
+				Name:      strings.Join(fields[1:], " "),
+				Diffuse:   mgl32.Vec3{1, 1, 1},
+				Shininess: 1,
+				Opacity:   1,
+			}
+			p.model.Materials[mtl.Name] = mtl
+			continue
+		}
+		if mtl == nil {
+			return mp.errorf("statement %q before newmtl", fields[0])
+		}
+
+		args := fields[1:]
+		color := func(c *mgl32.Vec3) error {
+			if len(args) > 0 && (args[0] == "spectral" || args[0] == "xyz") {
+				return mp.errorf("unsupported color format %q", args[0])
+			}
+			v, err := mp.parseFloats(args, 1, 3)
+			if err != nil {
+				return err
+			}
+			if len(v) == 1 {
+				*c = mgl32.Vec3{v[0], v[0], v[0]}
+			} else if len(v) == 3 {
+				*c = mgl32.Vec3{v[0], v[1], v[2]}
+			} else {
+				return mp.errorf("expect 1 or 3 numbers, got %d", len(v))
+			}
+			return nil
+		}
+		scalar := func(f *float32) error {
+			v, err := mp.parseFloats(args, 1, 1)
+			if err != nil {
+				return err
+			}
+			*f = v[0]
+			return nil
+		}
+		texture := func(s *string) error {
+			// Texture options (e.g. -s 1 1 1) are skipped, file name is the last argument
+			if len(args) == 0 {
+				return mp.errorf("missing texture file name")
+			}
+			*s = path.Join(mtlDir, filepath.ToSlash(args[len(args)-1]))
+			return nil
+		}
+
+		var err error
+		switch fields[0] {
+		case "Ka":
+			err = color(&mtl.Ambient)
+		case "Kd":
+			err = color(&mtl.Diffuse)
+		case "Ks":
+			err = color(&mtl.Specular)
+		case "Ke":
+			err = color(&mtl.Emissive)
+		case "Ns":
+			err = scalar(&mtl.Shininess)
+		case "d":
+			if len(args) > 0 && args[0] == "-halo" {
+				args = args[1:]
+			}
+			err = scalar(&mtl.Opacity)
+		case "Tr":
+			var tr float32
+			if err = scalar(&tr); err == nil {
+				mtl.Opacity = 1 - tr
+			}
+		case "illum":
+			var v []float32
+			if v, err = mp.parseFloats(args, 1, 1); err == nil {
+				mtl.Illumination = int(v[0])
+			}
+		case "map_Ka":
+			err = texture(&mtl.AmbientMap)
+		case "map_Kd":
+			err = texture(&mtl.DiffuseMap)
+		case "map_Ks":
+			err = texture(&mtl.SpecularMap)
+		case "map_Ke":
+			err = texture(&mtl.EmissiveMap)
+		case "map_Ns":
+			err = texture(&mtl.ShininessMap)
+		case "map_d":
+			err = texture(&mtl.AlphaMap)
+		case "map_bump", "map_Bump", "bump":
+			err = texture(&mtl.BumpMap)
+		case "norm", "map_Kn":
+			err = texture(&mtl.NormalMap)
+		default:
+			// Unsupported statements are ignored
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	return nil
+}
+
+// Split simple polygon into triangles by ear clipping, returns indices of points
+func triangulatePolygon(points []mgl32.Vec3) [][3]int {
+	n := len(points)
+	if n == 3 {
+		return [][3]int{{0, 1, 2}}
+	}
+
+	// Newell's method for polygon normal
+	var normal mgl32.Vec3
+	for i := range points {
+		a, b := points[i], points[(i+1)%n]
+		normal[0] += (a[1] - b[1]) * (a[2] + b[2])
+		normal[1] += (a[2] - b[2]) * (a[0] + b[0])
+		normal[2] += (a[0] - b[0]) * (a[1] + b[1])
+	}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	triangles := make([][3]int, 0, n-2)
+	if normal.Len() > 0 {
+		for len(remaining) > 3 {
+			found := false
+			for i := range remaining {
+				prev := remaining[(i+len(remaining)-1)%len(remaining)]
+				cur := remaining[i]
+				next := remaining[(i+1)%len(remaining)]
+				if !isEar(points, remaining, prev, cur, next, normal) {
+					continue
+				}
+				triangles = append(triangles, [3]int{prev, cur, next})
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				found = true
+				break
+			}
+			if !found {
+				break
+			}
+		}
+	}
+
+	// Degenerated polygon, fall back to fan triangulation
+	for i := 1; i+1 < len(remaining); i++ {
+		triangles = append(triangles, [3]int{remaining[0], remaining[i], remaining[i+1]})
+	}
+	return triangles
+}
+
+// Whether triangle (prev, cur, next) is an ear of polygon
+func isEar(points []mgl32.Vec3, remaining []int, prev, cur, next int, normal mgl32.Vec3) bool {
+	a, b, c := points[prev], points[cur], points[next]
+	if b.Sub(a).Cross(c.Sub(b)).Dot(normal) <= 0 {
+		return false
+	}
+	for _, i := range remaining {
+		if i == prev || i == cur || i == next {
+			continue
+		}
+		p := points[i]
+		if b.Sub(a).Cross(p.Sub(a)).Dot(normal) >= 0 &&
+			c.Sub(b).Cross(p.Sub(b)).Dot(normal) >= 0 &&
+			a.Sub(c).Cross(p.Sub(c)).Dot(normal) >= 0 {
+			return false
+		}
+	}
+	return true
+}
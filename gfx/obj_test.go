@@ -0,0 +1,198 @@
+package gfx
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestReadObj(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		meshes   int
+		vertices int
+		indices  []uint32
+		normals  bool
+		uvs      bool
+	}{
+		{
+			name:     "triangle",
+			source:   "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n",
+			meshes:   1,
+			vertices: 3,
+			indices:  []uint32{0, 1, 2},
+		},
+		{
+			name:     "quad is triangulated",
+			source:   "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nf 1 2 3 4\n",
+			meshes:   1,
+			vertices: 4,
+			indices:  []uint32{0, 1, 2, 2, 3, 0},
+		},
+		{
+			name:     "negative indices",
+			source:   "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -3 -2 -1\n",
+			meshes:   1,
+			vertices: 3,
+			indices:  []uint32{0, 1, 2},
+		},
+		{
+			name: "shared vertices are deduplicated",
+			source: "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nvn 0 0 1\nvt 0 0\nvt 1 1\n" +
+				"f 1/1/1 2/1/1 3/2/1\nf 1/1/1 3/2/1 4/2/1\n",
+			meshes:   1,
+			vertices: 4,
+			indices:  []uint32{0, 1, 2, 0, 2, 3},
+			normals:  true,
+			uvs:      true,
+		},
+		{
+			name:     "groups split meshes",
+			source:   "v 0 0 0\nv 1 0 0\nv 0 1 0\ng a\nf 1 2 3\ng b\nf 1 2 3\n",
+			meshes:   2,
+			vertices: 3,
+			indices:  []uint32{0, 1, 2},
+		},
+		{
+			name:     "comments and unsupported statements",
+			source:   "# comment\nv 0 0 0\nv 1 0 0\nv 0 1 0 # trailing\ns 1\nl 1 2\nf 1 2 3\n",
+			meshes:   1,
+			vertices: 3,
+			indices:  []uint32{0, 1, 2},
+		},
+		{
+			name:   "no faces",
+			source: "v 0 0 0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ReadObj(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Meshes) != tt.meshes {
+				t.Fatalf("got %d meshes, want %d", len(m.Meshes), tt.meshes)
+			}
+			if tt.meshes == 0 {
+				return
+			}
+			d := m.Meshes[0].Data
+			if len(d.Positions) != tt.vertices {
+				t.Errorf("got %d vertices, want %d", len(d.Positions), tt.vertices)
+			}
+			if !equalIndices(d.Indices, tt.indices) {
+				t.Errorf("got indices %v, want %v", d.Indices, tt.indices)
+			}
+			if (len(d.Normals) > 0) != tt.normals {
+				t.Errorf("got %d normals, want them %v", len(d.Normals), tt.normals)
+			}
+			if (len(d.TexCoords) > 0) != tt.uvs {
+				t.Errorf("got %d texture coordinates, want them %v", len(d.TexCoords), tt.uvs)
+			}
+		})
+	}
+}
+
+func TestReadObjErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{"vertex index out of range", "v 0 0 0\nv 1 0 0\nf 1 2 3\n", "vertex index 3 out of range"},
+		{"zero index", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2\n", "vertex index 0 out of range"},
+		{"negative index out of range", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -4 1 2\n", "vertex index -4 out of range"},
+		{"normal index out of range", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1//1 2//1 3//1\n", "normal index 1 out of range"},
+		{"texture index out of range", "v 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0 0\nf 1/2 2/1 3/1\n", "texture coordinate index 2 out of range"},
+		{"too few face vertices", "v 0 0 0\nv 1 0 0\nf 1 2\n", "face needs at least 3 vertices"},
+		{"malformed number", "v 0 x 0\n", ":1:"},
+		{"truncated vertex", "v 0 0\n", ":1:"},
+		{"truncated normal", "vn 0 1\n", ":1:"},
+		{"malformed face vertex", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1/1/1/1 2 3\n", "invalid face vertex"},
+		{"missing material name", "usemtl\n", "missing material name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadObj(strings.NewReader(tt.source))
+			if err == nil {
+				t.Fatalf("got no error, want %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadObjFSMaterials(t *testing.T) {
+	fsys := fstest.MapFS{
+		"models/box.obj": {Data: []byte("mtllib box.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\nusemtl red\nf 1 2 3\n")},
+		"models/box.mtl": {Data: []byte("newmtl red\nKd 1 0 0\nKs 0.5\nNs 32\nd 0.5\nmap_Kd -s 1 1 1 textures/red.png\n")},
+	}
+	m, err := LoadObjFS(fsys, "models/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Meshes) != 1 || m.Meshes[0].Material != "red" {
+		t.Fatalf("got meshes %+v, want one of material red", m.Meshes)
+	}
+	mtl := m.Materials["red"]
+	if mtl == nil {
+		t.Fatal("material red is missing")
+	}
+	if mtl.Diffuse != (mgl32.Vec3{1, 0, 0}) || mtl.Specular != (mgl32.Vec3{0.5, 0.5, 0.5}) {
+		t.Errorf("got diffuse %v and specular %v", mtl.Diffuse, mtl.Specular)
+	}
+	if mtl.Shininess != 32 || mtl.Opacity != 0.5 {
+		t.Errorf("got shininess %v and opacity %v", mtl.Shininess, mtl.Opacity)
+	}
+	if mtl.DiffuseMap != "textures/red.png" {
+		t.Errorf("got diffuse map %q", mtl.DiffuseMap)
+	}
+}
+
+func TestLoadObjFSMaterialErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		mtl  string
+		err  string
+	}{
+		{"statement before newmtl", "Kd 1 0 0\n", "before newmtl"},
+		{"truncated color", "newmtl a\nKd 1 0\n", "expect 1 or 3 numbers"},
+		{"unsupported color", "newmtl a\nKd spectral a.rfl\n", "unsupported color format"},
+		{"missing texture", "newmtl a\nmap_Kd\n", "missing texture file name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"a.obj": {Data: []byte("mtllib a.mtl\n")},
+				"a.mtl": {Data: []byte(tt.mtl)},
+			}
+			_, err := LoadObjFS(fsys, "a.obj")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	_, err := LoadObjFS(fstest.MapFS{"a.obj": {Data: []byte("mtllib missing.mtl\n")}}, "a.obj")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("got error %v for missing library", err)
+	}
+}
+
+func equalIndices(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
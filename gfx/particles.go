@@ -0,0 +1,598 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/particles/simulate.glsl
+var particleSimulateShader string
+
+//go:embed shaders/particles/update.comp
+var particleUpdateComputeShader string
+
+//go:embed shaders/particles/update.vert
+var particleUpdateVertexShader string
+
+//go:embed shaders/particles/sort.comp
+var particleSortComputeShader string
+
+//go:embed shaders/particles/particle.vert
+var particleVertexShader string
+
+//go:embed shaders/particles/particle.frag
+var particleFragmentShader string
+
+const (
+	// Maximum number of emitters of particle system, the rest is ignored
+	MaxParticleEmitters = 8
+
+	// Size of work groups of compute shaders
+	particleGroupSize = 256
+
+	// Binding points of emitters uniform block, and of particle and sort key
+	// storage buffers, which don't collide with lights and shadows
+	particleEmitterBinding = 2
+	particleBinding        = 2
+	particleKeyBinding     = 3
+
+	// Number of samples of baked curves
+	particleCurveSamples = 64
+)
+
+// Shape of volume where particles are spawned
+type EmitterShape int32
+
+const (
+	EmitPoint  EmitterShape = iota
+	EmitSphere              // Extent[0] is radius
+	EmitBox                 // Extent is half size
+)
+
+// How particle colors are combined with scene
+type ParticleBlend int
+
+const (
+	ParticleBlendAdditive ParticleBlend = iota // glowing sparks, order doesn't matter
+	ParticleBlendAlpha                         // smoke, should be sorted back to front
+)
+
+// Order in which particles are drawn
+type ParticleSort int
+
+const (
+	ParticleSortNone ParticleSort = iota
+	ParticleSortBackToFront
+	ParticleSortFrontToBack
+)
+
+// Backend of particle simulation
+type ParticleSimulation int
+
+const (
+	// Compute shaders where supported, otherwise transform feedback
+	SimulateAuto ParticleSimulation = iota
+	SimulateCompute
+	SimulateTransformFeedback
+)
+
+// ParticleEmitter spawns particles at constant rate or in bursts.
+type ParticleEmitter struct {
+	Position mgl32.Vec3
+	Shape    EmitterShape
+	Extent   mgl32.Vec3
+
+	// Initial velocity is Direction (could be zero) within cone of Spread
+	// half angle in radians, Speed varies by fraction SpeedVariation
+	Direction      mgl32.Vec3
+	Spread         float32
+	Speed          float32
+	SpeedVariation float32
+
+	Rate              float32 // particles per second
+	Lifetime          float32 // seconds
+	LifetimeVariation float32 // fraction of lifetime
+
+	accumulated float32
+	burst       int
+}
+
+// Burst spawns count particles at next update.
+func (e *ParticleEmitter) Burst(count int) {
+	e.burst += count
+}
+
+// Number of particles spawned over elapsed seconds
+func (e *ParticleEmitter) spawn(elapsed float32) int {
+	e.accumulated += e.Rate * elapsed
+	count := int(e.accumulated)
+	e.accumulated -= float32(count)
+	count += e.burst
+	e.burst = 0
+	return count
+}
+
+// Key of curve, value at time in [0, 1] of particle lifetime
+type CurveKey struct {
+	Time  float32
+	Value float32
+}
+
+// Curve interpolates linearly between keys ordered by time, it's constant
+// before the first key and after the last one.
+type Curve []CurveKey
+
+// Evaluate returns value of curve at t, 0 for empty curve.
+func (c Curve) Evaluate(t float32) float32 {
+	if len(c) == 0 {
+		return 0
+	}
+	i := sort.Search(len(c), func(i int) bool { return c[i].Time > t })
+	if i == 0 {
+		return c[0].Value
+	}
+	if i == len(c) {
+		return c[i-1].Value
+	}
+	a, b := c[i-1], c[i]
+	return a.Value + (b.Value-a.Value)*(t-a.Time)/(b.Time-a.Time)
+}
+
+// Key of color curve, value at time in [0, 1] of particle lifetime
+type ColorKey struct {
+	Time  float32
+	Color mgl32.Vec4 // linear color and alpha
+}
+
+// ColorCurve is like Curve with colors.
+type ColorCurve []ColorKey
+
+// Evaluate returns color of curve at t, white for empty curve.
+func (c ColorCurve) Evaluate(t float32) mgl32.Vec4 {
+	if len(c) == 0 {
+		return mgl32.Vec4{1, 1, 1, 1}
+	}
+	i := sort.Search(len(c), func(i int) bool { return c[i].Time > t })
+	if i == 0 {
+		return c[0].Color
+	}
+	if i == len(c) {
+		return c[i-1].Color
+	}
+	a, b := c[i-1], c[i]
+	return a.Color.Add(b.Color.Sub(a.Color).Mul((t - a.Time) / (b.Time - a.Time)))
+}
+
+// Must match layout of Emitter in simulate.glsl
+type gpuEmitter struct {
+	Position          mgl32.Vec3
+	Shape             int32
+	Extent            mgl32.Vec3
+	Spread            float32
+	Direction         mgl32.Vec3
+	Speed             float32
+	SpeedVariation    float32
+	Lifetime          float32
+	LifetimeVariation float32
+	End               int32
+}
+
+// Must match layout of SortKey in sort.comp
+type particleSortKey struct {
+	Key   float32
+	Index uint32
+}
+
+// ParticleSystem simulates particles on gpu, by compute shaders or
+// transform feedback where they aren't available. Particles live in a
+// ring buffer, spawned ones replace the oldest, so capacity should exceed
+// rate of emitters times lifetime. They are drawn as billboards colored
+// and sized by curves over their lifetime.
+type ParticleSystem struct {
+	Emitters []*ParticleEmitter
+	Gravity  mgl32.Vec3
+	Drag     float32 // fraction of velocity lost per second, roughly
+
+	Color   ColorCurve
+	Size    Curve  // world size of billboard
+	Texture uint32 // sprite multiplied by color, 0 for soft disc
+	Blend   ParticleBlend
+	Sort    ParticleSort
+
+	// Distance over which particles fade out in front of scene surfaces,
+	// 0 disables soft particles
+	Softness float32
+
+	capacity int
+	compute  bool
+	head     int
+	frame    uint32
+
+	particles [2]*Buffer // the second one is output of transform feedback
+	textures  [2]uint32  // buffer textures of particles
+	current   int
+	keys      *Buffer
+	keyCount  int
+	emitters  *Buffer
+	curves    uint32
+
+	update     *Material
+	sort       *Material
+	draw       *Material
+	updateVAOs [2]uint32
+	drawVAO    uint32
+
+	sortSize     int32
+	sortDistance int32
+}
+
+// NewParticleSystem creates system of capacity particles, which are all
+// dead, simulated by backend.
+func NewParticleSystem(capacity int, backend ParticleSimulation) (*ParticleSystem, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("invalid capacity %d of particle system", capacity)
+	}
+	computeSupported := VersionAtLeast(4, 3)
+	if backend == SimulateCompute && !computeSupported {
+		return nil, fmt.Errorf("compute shaders aren't supported")
+	}
+	p := &ParticleSystem{
+		Gravity:  mgl32.Vec3{0, -9.81, 0},
+		Color:    ColorCurve{{0, mgl32.Vec4{1, 1, 1, 1}}, {1, mgl32.Vec4{1, 1, 1, 0}}},
+		Size:     Curve{{0, 0.1}},
+		Softness: 0.5,
+		capacity: capacity,
+		compute:  backend != SimulateTransformFeedback && computeSupported,
+	}
+
+	// Two vec4 per particle, zeros are dead particles
+	zeros := make([]float32, capacity*8)
+	buffers := 1
+	if !p.compute {
+		buffers = 2
+	}
+	for i := 0; i < buffers; i++ {
+		p.particles[i] = NewBuffer(gl.ARRAY_BUFFER, BufferStatic, 0)
+		if _, err := p.particles[i].Upload(zeros); err != nil {
+			p.Dispose()
+			return nil, err
+		}
+		gl.GenTextures(1, &p.textures[i])
+		gl.BindTexture(gl.TEXTURE_BUFFER, p.textures[i])
+		gl.TexBuffer(gl.TEXTURE_BUFFER, gl.RGBA32F, p.particles[i].ID())
+	}
+	gl.BindTexture(gl.TEXTURE_BUFFER, 0)
+
+	// Sorted order, padded to power of two for bitonic sort
+	p.keyCount = 1
+	for p.keyCount < capacity {
+		p.keyCount *= 2
+	}
+	p.keys = NewBuffer(gl.ARRAY_BUFFER, BufferDynamic, p.keyCount*int(unsafe.Sizeof(particleSortKey{})))
+	p.emitters = NewBuffer(gl.UNIFORM_BUFFER, BufferDynamic, MaxParticleEmitters*int(unsafe.Sizeof(gpuEmitter{})))
+
+	gl.GenTextures(1, &p.curves)
+	gl.BindTexture(gl.TEXTURE_2D, p.curves)
+	gl.TexStorage2D(gl.TEXTURE_2D, 1, gl.RGBA16F, particleCurveSamples, 2)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	forgetBindings()
+
+	if err := p.loadPrograms(); err != nil {
+		p.Dispose()
+		return nil, err
+	}
+
+	// Update of transform feedback reads attributes of current buffer
+	if !p.compute {
+		gl.GenVertexArrays(2, &p.updateVAOs[0])
+		for i, vao := range p.updateVAOs {
+			gl.BindVertexArray(vao)
+			gl.BindBuffer(gl.ARRAY_BUFFER, p.particles[i].ID())
+			gl.EnableVertexAttribArray(0)
+			gl.VertexAttribPointer(0, 4, gl.FLOAT, false, 32, gl.PtrOffset(0))
+			gl.EnableVertexAttribArray(1)
+			gl.VertexAttribPointer(1, 4, gl.FLOAT, false, 32, gl.PtrOffset(16))
+		}
+	}
+
+	// Billboards are instances, sorted index is an instanced attribute
+	gl.GenVertexArrays(1, &p.drawVAO)
+	gl.BindVertexArray(p.drawVAO)
+	gl.BindBuffer(gl.ARRAY_BUFFER, p.keys.ID())
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribIPointer(0, 1, gl.UNSIGNED_INT, int32(unsafe.Sizeof(particleSortKey{})),
+		gl.PtrOffset(int(unsafe.Offsetof(particleSortKey{}.Index))))
+	gl.VertexAttribDivisor(0, 1)
+	gl.BindVertexArray(0)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	return p, nil
+}
+
+// Compile programs of simulation, sorting and drawing
+func (p *ParticleSystem) loadPrograms() error {
+	defines := fmt.Sprintf("#define PI 3.14159265359\n#define MAX_EMITTERS %d\n#define PARTICLE_GROUP_SIZE %d\n"+
+		"#define PARTICLE_BINDING %d\n#define KEY_BINDING %d\n",
+		MaxParticleEmitters, particleGroupSize, particleBinding, particleKeyBinding)
+	var update, sorting uint32
+	var err error
+	if p.compute {
+		update, err = LoadComputeShader("#version 430 core\n" + defines + particleSimulateShader +
+			particleUpdateComputeShader + "\x00")
+		if err != nil {
+			return fmt.Errorf("particle update: %v", err)
+		}
+		p.update = newOwningMaterial(update)
+		sorting, err = LoadComputeShader("#version 430 core\n" + defines + particleSortComputeShader + "\x00")
+		if err != nil {
+			return fmt.Errorf("particle sort: %v", err)
+		}
+		p.sort = newOwningMaterial(sorting)
+		p.sortSize = gl.GetUniformLocation(sorting, gl.Str("sortSize\x00"))
+		p.sortDistance = gl.GetUniformLocation(sorting, gl.Str("sortDistance\x00"))
+	} else {
+		update, err = LoadTransformFeedbackShader("#version 330 core\n"+defines+particleSimulateShader+
+			particleUpdateVertexShader+"\x00", "outPositionAge", "outVelocityLifetime")
+		if err != nil {
+			return fmt.Errorf("particle update: %v", err)
+		}
+		p.update = newOwningMaterial(update)
+	}
+	gl.UniformBlockBinding(update, gl.GetUniformBlockIndex(update, gl.Str("Emitters\x00")), particleEmitterBinding)
+
+	draw, err := LoadShaders([]Shader{
+		{Source: "#version 330 core\n" + particleVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + particleFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		return fmt.Errorf("particle drawing: %v", err)
+	}
+	p.draw = newOwningMaterial(draw)
+	p.draw.SetTexture("curves", p.curves)
+	return nil
+}
+
+func newOwningMaterial(program uint32) *Material {
+	m := NewMaterial(program)
+	m.ownsProgram = true
+	return m
+}
+
+// Capacity returns maximum number of live particles.
+func (p *ParticleSystem) Capacity() int {
+	return p.capacity
+}
+
+// UsesCompute reports whether particles are simulated by compute shaders.
+func (p *ParticleSystem) UsesCompute() bool {
+	return p.compute
+}
+
+// Update spawns particles of emitters and advances all particles by
+// elapsed seconds.
+func (p *ParticleSystem) Update(elapsed float32) {
+	emitters := make([]gpuEmitter, MaxParticleEmitters)
+	count, total := 0, 0
+	for _, e := range p.Emitters {
+		if count == MaxParticleEmitters {
+			break
+		}
+		spawned := e.spawn(elapsed)
+		if spawned > p.capacity-total {
+			spawned = p.capacity - total
+		}
+		total += spawned
+		emitters[count] = gpuEmitter{
+			Position:          e.Position,
+			Shape:             int32(e.Shape),
+			Extent:            e.Extent,
+			Spread:            e.Spread,
+			Direction:         e.Direction,
+			Speed:             e.Speed,
+			SpeedVariation:    e.SpeedVariation,
+			Lifetime:          e.Lifetime,
+			LifetimeVariation: e.LifetimeVariation,
+			End:               int32(total),
+		}
+		count++
+	}
+	p.emitters.Upload(emitters)
+	p.emitters.BindRange(particleEmitterBinding, 0, p.emitters.Capacity())
+	p.bakeCurves()
+
+	m := p.update
+	m.SetParam("emitterCount", int32(count))
+	m.SetParam("capacity", uint32(p.capacity))
+	m.SetParam("spawnStart", uint32(p.head))
+	m.SetParam("frame", p.frame)
+	m.SetParam("deltaTime", elapsed)
+	m.SetParam("gravity", p.Gravity)
+	m.SetParam("drag", p.Drag)
+	m.Use()
+	p.head = (p.head + total) % p.capacity
+	p.frame++
+
+	if p.compute {
+		gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, particleBinding, p.particles[0].ID())
+		gl.DispatchCompute(uint32((p.capacity+particleGroupSize-1)/particleGroupSize), 1, 1)
+		gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT | gl.TEXTURE_FETCH_BARRIER_BIT)
+		return
+	}
+
+	var lastVertexArray int32
+	gl.GetIntegerv(gl.VERTEX_ARRAY_BINDING, &lastVertexArray)
+	next := 1 - p.current
+	gl.Enable(gl.RASTERIZER_DISCARD)
+	gl.BindVertexArray(p.updateVAOs[p.current])
+	gl.BindBufferBase(gl.TRANSFORM_FEEDBACK_BUFFER, 0, p.particles[next].ID())
+	gl.BeginTransformFeedback(gl.POINTS)
+	gl.DrawArrays(gl.POINTS, 0, int32(p.capacity))
+	gl.EndTransformFeedback()
+	gl.BindBufferBase(gl.TRANSFORM_FEEDBACK_BUFFER, 0, 0)
+	gl.Disable(gl.RASTERIZER_DISCARD)
+	gl.BindVertexArray(uint32(lastVertexArray))
+	p.current = next
+}
+
+// Draw renders live particles seen by camera into bound framebuffer, with
+// depth test but without writing depth. Depth is texture of scene depth
+// of the size of framebuffer used by soft particles, it could be 0.
+func (p *ParticleSystem) Draw(camera Camera, depth uint32) {
+	projection, view := camera.Projection(), camera.View()
+	sorted := p.Sort != ParticleSortNone
+	if sorted {
+		p.sortParticles(view)
+	}
+
+	lastEnableBlend := gl.IsEnabled(gl.BLEND)
+	lastEnableCullFace := gl.IsEnabled(gl.CULL_FACE)
+	lastBlend := saveBlend()
+	var lastVertexArray int32
+	gl.GetIntegerv(gl.VERTEX_ARRAY_BINDING, &lastVertexArray)
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	gl.Enable(gl.BLEND)
+	gl.BlendEquation(gl.FUNC_ADD)
+	if p.Blend == ParticleBlendAdditive {
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE)
+	} else {
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	}
+	gl.Disable(gl.CULL_FACE)
+	gl.DepthMask(false)
+
+	m := p.draw
+	m.SetTextureTarget("particles", gl.TEXTURE_BUFFER, p.textures[p.current])
+	m.SetTexture("sprite", p.Texture)
+	m.SetParam("useSprite", p.Texture != 0)
+	m.SetTexture("depthMap", depth)
+	m.SetParam("softParticles", depth != 0 && p.Softness > 0)
+	m.SetParam("softness", p.Softness)
+	m.SetParam("sorted", sorted)
+	m.SetParam("capacity", int32(p.capacity))
+	m.SetParam(UniformProjection, projection)
+	m.SetParam(UniformView, view)
+	m.SetParam("inverseProjection", projection.Inv())
+	m.Use()
+	gl.BindVertexArray(p.drawVAO)
+	gl.DrawArraysInstanced(gl.TRIANGLE_STRIP, 0, 4, int32(p.capacity))
+
+	gl.BindVertexArray(uint32(lastVertexArray))
+	gl.DepthMask(lastDepthMask)
+	setEnabled(gl.CULL_FACE, lastEnableCullFace)
+	setEnabled(gl.BLEND, lastEnableBlend)
+	lastBlend.restore()
+}
+
+// Write order of particles by view depth into keys, by bitonic sort in
+// compute shaders or on cpu when they aren't available
+func (p *ParticleSystem) sortParticles(view mgl32.Mat4) {
+	direction := float32(1)
+	if p.Sort == ParticleSortFrontToBack {
+		direction = -1
+	}
+
+	if !p.compute {
+		data := make([]float32, p.capacity*8)
+		p.particles[p.current].Bind()
+		gl.GetBufferSubData(gl.ARRAY_BUFFER, 0, len(data)*4, gl.Ptr(data))
+		keys := make([]particleSortKey, p.capacity)
+		for i := range keys {
+			particle := data[i*8:]
+			keys[i] = particleSortKey{Key: 3.0e38, Index: uint32(i)}
+			if particle[3] < particle[7] {
+				position := mgl32.Vec4{particle[0], particle[1], particle[2], 1}
+				keys[i].Key = view.Mul4x1(position).Z() * direction
+			}
+		}
+		sort.SliceStable(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+		p.keys.UpdateRange(0, keys)
+		return
+	}
+
+	m := p.sort
+	m.SetParam("capacity", uint32(p.capacity))
+	m.SetParam("count", uint32(p.keyCount))
+	m.SetParam(UniformView, view)
+	m.SetParam("direction", direction)
+	m.SetParam("writeKeys", true)
+	m.Use()
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, particleBinding, p.particles[0].ID())
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, particleKeyBinding, p.keys.ID())
+	groups := uint32((p.keyCount + particleGroupSize - 1) / particleGroupSize)
+	gl.DispatchCompute(groups, 1, 1)
+	gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT)
+
+	m.SetParam("writeKeys", false)
+	m.Use()
+	for size := 2; size <= p.keyCount; size *= 2 {
+		for distance := size / 2; distance > 0; distance /= 2 {
+			gl.Uniform1ui(p.sortSize, uint32(size))
+			gl.Uniform1ui(p.sortDistance, uint32(distance))
+			gl.DispatchCompute(groups, 1, 1)
+			gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT)
+		}
+	}
+	gl.MemoryBarrier(gl.VERTEX_ATTRIB_ARRAY_BARRIER_BIT)
+}
+
+// Sample curves into rows of curve texture, color and size
+func (p *ParticleSystem) bakeCurves() {
+	data := make([]float32, particleCurveSamples*2*4)
+	for i := 0; i < particleCurveSamples; i++ {
+		t := float32(i) / (particleCurveSamples - 1)
+		color := p.Color.Evaluate(t)
+		copy(data[i*4:], color[:])
+		data[(particleCurveSamples+i)*4] = p.Size.Evaluate(t)
+	}
+	gl.BindTexture(gl.TEXTURE_2D, p.curves)
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, particleCurveSamples, 2, gl.RGBA, gl.FLOAT, gl.Ptr(data))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	forgetBindings()
+}
+
+// Dispose cleans up the resources.
+func (p *ParticleSystem) Dispose() {
+	for i, b := range p.particles {
+		if b != nil {
+			b.Dispose()
+		}
+		p.particles[i] = nil
+		if p.textures[i] != 0 {
+			gl.DeleteTextures(1, &p.textures[i])
+		}
+		p.textures[i] = 0
+	}
+	for _, b := range []*Buffer{p.keys, p.emitters} {
+		if b != nil {
+			b.Dispose()
+		}
+	}
+	p.keys, p.emitters = nil, nil
+	if p.curves != 0 {
+		gl.DeleteTextures(1, &p.curves)
+	}
+	p.curves = 0
+	for _, m := range []*Material{p.update, p.sort, p.draw} {
+		if m != nil {
+			m.Dispose()
+		}
+	}
+	p.update, p.sort, p.draw = nil, nil, nil
+	if p.updateVAOs[0] != 0 {
+		gl.DeleteVertexArrays(2, &p.updateVAOs[0])
+	}
+	p.updateVAOs = [2]uint32{}
+	if p.drawVAO != 0 {
+		gl.DeleteVertexArrays(1, &p.drawVAO)
+	}
+	p.drawVAO = 0
+}
@@ -0,0 +1,355 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+//go:embed shaders/pick.vert
+var pickVertexShader string
+
+//go:embed shaders/pick.frag
+var pickFragmentShader string
+
+// Distance in pixels mouse may travel between press and release of a click
+const clickSlop = 3
+
+// Outcome of picking at a point of framebuffer
+type PickResult struct {
+	X, Y     int32      // pixel of request, origin at top left
+	Node     *Node      // nil when nothing was hit
+	Position mgl32.Vec3 // world position of hit surface
+	Depth    float32    // window depth of hit surface, 1 when nothing was hit
+}
+
+// Program rendering IDs of meshes whose position is at location
+type pickProgram struct {
+	id             uint32
+	viewProjection int32
+	model          int32
+	objectID       int32
+}
+
+// Readback of picked pixel which is in flight
+type pickReadback struct {
+	x, y    int32
+	pbo     uint32
+	fence   uintptr
+	nodes   []*Node    // indexed by ID-1
+	inverse mgl32.Mat4 // clip to world space at the time of rendering
+	width   int32
+	height  int32
+}
+
+// Picker finds scene nodes under points of view by rendering node IDs
+// into an integer framebuffer. Pixels are read back asynchronously
+// through pixel buffers, so results arrive a frame or two after request,
+// which avoids stalling the pipeline.
+type Picker struct {
+	// OnPick is called from Update with results of finished requests
+	OnPick func(result PickResult)
+
+	ui       InputCapturer
+	fb       *Framebuffer
+	programs map[int32]pickProgram
+	requests [][2]int32
+	inFlight []pickReadback
+	freePBOs []uint32
+	pressed  bool
+	pressX   int32
+	pressY   int32
+}
+
+// NewPicker creates picker with ID framebuffer of size, which should
+// match drawable size of window. Clicks claimed by ui (could be nil) are
+// ignored.
+func NewPicker(width, height int32, ui InputCapturer) (*Picker, error) {
+	fb, err := NewFramebuffer(width, height, FramebufferSpec{
+		Colors: []uint32{gl.R32UI},
+		Depth:  gl.DEPTH_COMPONENT32F,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Picker{
+		ui:       ui,
+		fb:       fb,
+		programs: map[int32]pickProgram{},
+	}, nil
+}
+
+// Framebuffer returns framebuffer holding IDs of nodes, 0 for none,
+// rendered around the last requested pixels.
+func (p *Picker) Framebuffer() *Framebuffer {
+	return p.fb
+}
+
+// Pick requests node at pixel of framebuffer, with origin at top left.
+// Result is passed to OnPick by one of following updates.
+func (p *Picker) Pick(x, y int32) {
+	p.requests = append(p.requests, [2]int32{x, y})
+}
+
+// ProcessEvent follows window size and requests picking on left clicks,
+// which aren't claimed by ui and don't drag the mouse.
+func (p *Picker) ProcessEvent(event sdl.Event) error {
+	switch e := event.(type) {
+	case *sdl.WindowEvent:
+		return p.fb.ProcessEvent(event)
+	case *sdl.MouseButtonEvent:
+		if e.Button != sdl.BUTTON_LEFT {
+			return nil
+		}
+		if e.Type == sdl.MOUSEBUTTONDOWN {
+			p.pressed = wantMouse(p.ui)
+			p.pressX, p.pressY = e.X, e.Y
+			return nil
+		}
+		if !p.pressed {
+			return nil
+		}
+		p.pressed = false
+		if abs32(e.X-p.pressX) > clickSlop || abs32(e.Y-p.pressY) > clickSlop {
+			return nil
+		}
+
+		// Mouse is in window coordinates, which differ from pixels on
+		// high density displays
+		window, err := sdl.GetWindowFromID(e.WindowID)
+		if err != nil {
+			return err
+		}
+		windowWidth, windowHeight := window.GetSize()
+		if windowWidth <= 0 || windowHeight <= 0 {
+			return nil
+		}
+		width, height := p.fb.Size()
+		p.Pick(e.X*width/windowWidth, e.Y*height/windowHeight)
+	}
+	return nil
+}
+
+// Update renders IDs of visible meshes of scene viewed by camera at
+// requested pixels, and reports results of readbacks which have finished.
+// It should be called once per frame.
+func (p *Picker) Update(scene *Node, camera Camera) error {
+	if len(p.requests) > 0 {
+		if err := p.render(scene, camera); err != nil {
+			return err
+		}
+	}
+
+	// Readbacks finish in order of submission
+	for len(p.inFlight) > 0 {
+		r := p.inFlight[0]
+		status := gl.ClientWaitSync(r.fence, 0, 0)
+		if status == gl.TIMEOUT_EXPIRED {
+			break
+		}
+		p.inFlight = p.inFlight[1:]
+		gl.DeleteSync(r.fence)
+		if status == gl.WAIT_FAILED {
+			p.freePBOs = append(p.freePBOs, r.pbo)
+			return fmt.Errorf("waiting for pick readback failed")
+		}
+		result := p.resolve(r)
+		p.freePBOs = append(p.freePBOs, r.pbo)
+		if p.OnPick != nil {
+			p.OnPick(result)
+		}
+	}
+	return nil
+}
+
+// Dispose cleans up the resources.
+func (p *Picker) Dispose() {
+	for _, r := range p.inFlight {
+		gl.DeleteSync(r.fence)
+		p.freePBOs = append(p.freePBOs, r.pbo)
+	}
+	p.inFlight = nil
+	for _, pbo := range p.freePBOs {
+		gl.DeleteBuffers(1, &pbo)
+	}
+	p.freePBOs = nil
+	for _, program := range p.programs {
+		gl.DeleteProgram(program.id)
+	}
+	p.programs = map[int32]pickProgram{}
+	p.fb.Dispose()
+}
+
+// Render IDs around requested pixels and start their readbacks
+func (p *Picker) render(scene *Node, camera Camera) error {
+	viewProjection := camera.Projection().Mul4(camera.View())
+	width, height := p.fb.Size()
+
+	// Node IDs are indices into list of visible meshes, starting at 1
+	var nodes []*Node
+	scene.Traverse(func(node *Node) bool {
+		if !node.Visible {
+			return false
+		}
+		if node.Mesh != nil {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+
+	var lastFramebuffer int32
+	var lastViewport [4]int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastFramebuffer)
+	gl.GetIntegerv(gl.VIEWPORT, &lastViewport[0])
+	lastEnableDepthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	lastEnableScissorTest := gl.IsEnabled(gl.SCISSOR_TEST)
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	p.fb.Bind()
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthMask(true)
+	gl.Enable(gl.SCISSOR_TEST)
+	defer func() {
+		setEnabled(gl.DEPTH_TEST, lastEnableDepthTest)
+		setEnabled(gl.SCISSOR_TEST, lastEnableScissorTest)
+		gl.DepthMask(lastDepthMask)
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(lastFramebuffer))
+		gl.Viewport(lastViewport[0], lastViewport[1], lastViewport[2], lastViewport[3])
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+		forgetBindings()
+	}()
+
+	// Only requested pixels are rendered, framebuffer rows go upwards
+	for _, request := range p.requests {
+		x, y := request[0], height-1-request[1]
+		if x < 0 || y < 0 || x >= width || y >= height {
+			continue
+		}
+		gl.Scissor(x, y, 1, 1)
+		var noID [4]uint32
+		farDepth := float32(1)
+		gl.ClearBufferuiv(gl.COLOR, 0, &noID[0])
+		gl.ClearBufferfv(gl.DEPTH, 0, &farDepth)
+		if err := p.drawIDs(nodes, viewProjection); err != nil {
+			return err
+		}
+
+		r := pickReadback{
+			x:       request[0],
+			y:       request[1],
+			pbo:     p.newPBO(),
+			nodes:   nodes,
+			inverse: viewProjection.Inv(),
+			width:   width,
+			height:  height,
+		}
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, r.pbo)
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+		gl.ReadPixels(x, y, 1, 1, gl.RED_INTEGER, gl.UNSIGNED_INT, gl.PtrOffset(0))
+		gl.ReadPixels(x, y, 1, 1, gl.DEPTH_COMPONENT, gl.FLOAT, gl.PtrOffset(4))
+		r.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+		p.inFlight = append(p.inFlight, r)
+	}
+	p.requests = p.requests[:0]
+	return nil
+}
+
+// Draw IDs of nodes
+func (p *Picker) drawIDs(nodes []*Node, viewProjection mgl32.Mat4) error {
+	var current uint32
+	for i, node := range nodes {
+		program, err := p.pickProgram(node.Mesh.attribLocation(AttribPosition))
+		if err != nil {
+			return fmt.Errorf("pick node %q: %v", node.Name, err)
+		}
+		if program.id != current {
+			current = program.id
+			gl.UseProgram(program.id)
+			gl.UniformMatrix4fv(program.viewProjection, 1, false, &viewProjection[0])
+		}
+		model := node.WorldMatrix()
+		gl.UniformMatrix4fv(program.model, 1, false, &model[0])
+		gl.Uniform1ui(program.objectID, uint32(i+1))
+		node.Mesh.Draw()
+	}
+	return nil
+}
+
+// Decode ID and depth of finished readback
+func (p *Picker) resolve(r pickReadback) PickResult {
+	var pixel struct {
+		ID    uint32
+		Depth float32
+	}
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, r.pbo)
+	gl.GetBufferSubData(gl.PIXEL_PACK_BUFFER, 0, int(unsafe.Sizeof(pixel)), unsafe.Pointer(&pixel))
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+
+	result := PickResult{X: r.x, Y: r.y, Depth: pixel.Depth}
+	if pixel.ID == 0 || int(pixel.ID) > len(r.nodes) {
+		result.Depth = 1
+		return result
+	}
+	result.Node = r.nodes[pixel.ID-1]
+
+	// Center of pixel is unprojected from normalized device coordinates
+	ndc := mgl32.Vec3{
+		2*(float32(r.x)+0.5)/float32(r.width) - 1,
+		1 - 2*(float32(r.y)+0.5)/float32(r.height),
+		2*pixel.Depth - 1,
+	}
+	result.Position = mgl32.TransformCoordinate(ndc, r.inverse)
+	return result
+}
+
+// Get pixel buffer for readback, reusing finished ones
+func (p *Picker) newPBO() uint32 {
+	if n := len(p.freePBOs); n > 0 {
+		pbo := p.freePBOs[n-1]
+		p.freePBOs = p.freePBOs[:n-1]
+		return pbo
+	}
+	var pbo uint32
+	gl.GenBuffers(1, &pbo)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, pbo)
+	gl.BufferData(gl.PIXEL_PACK_BUFFER, 8, nil, gl.STREAM_READ)
+	return pbo
+}
+
+// Get program matching location of position attribute of mesh, VAO
+// of mesh is set up for program of its material
+func (p *Picker) pickProgram(location int32) (pickProgram, error) {
+	if location < 0 {
+		return pickProgram{}, fmt.Errorf("mesh has no position")
+	}
+	if program, ok := p.programs[location]; ok {
+		return program, nil
+	}
+	header := fmt.Sprintf("#version 330 core\n#define POSITION_LOCATION %d\n", location)
+	id, err := LoadShaders([]Shader{
+		{Source: header + pickVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + pickFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		return pickProgram{}, err
+	}
+	program := pickProgram{
+		id:             id,
+		viewProjection: gl.GetUniformLocation(id, gl.Str("viewProjection\x00")),
+		model:          gl.GetUniformLocation(id, gl.Str("model\x00")),
+		objectID:       gl.GetUniformLocation(id, gl.Str("objectID\x00")),
+	}
+	p.programs[location] = program
+	return program, nil
+}
+
+func abs32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,477 @@
+package gfx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// LoadPly loads mesh or point cloud from PLY file on disk, point cloud has no indices.
+func LoadPly(file string) (*MeshData, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found on disk: %v", file, err)
+	}
+	defer f.Close()
+	return parsePly(f, file)
+}
+
+// LoadPlyFS loads mesh or point cloud from PLY file in filesystem fsys.
+func LoadPlyFS(fsys fs.FS, name string) (*MeshData, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found in filesystem: %v", name, err)
+	}
+	defer f.Close()
+	return parsePly(f, name)
+}
+
+// ReadPly parses mesh or point cloud in PLY format from r.
+func ReadPly(r io.Reader) (*MeshData, error) {
+	return parsePly(r, "<ply>")
+}
+
+// WritePly writes mesh in PLY format to w, in binary (little endian) or
+// ascii. Data without indices is written as point cloud.
+func WritePly(w io.Writer, d *MeshData, binaryFormat bool) error {
+	if err := d.checkTriangles(); err != nil {
+		return err
+	}
+	if err := d.checkAttributes(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	format := "ascii"
+	if binaryFormat {
+		format = "binary_little_endian"
+	}
+	fmt.Fprintf(bw, "ply\nformat %s 1.0\ncomment generated by glapp\n", format)
+	fmt.Fprintf(bw, "element vertex %d\n", len(d.Positions))
+	fmt.Fprintf(bw, "property float x\nproperty float y\nproperty float z\n")
+	if len(d.Normals) > 0 {
+		fmt.Fprintf(bw, "property float nx\nproperty float ny\nproperty float nz\n")
+	}
+	if len(d.TexCoords) > 0 {
+		fmt.Fprintf(bw, "property float s\nproperty float t\n")
+	}
+	if len(d.Colors) > 0 {
+		fmt.Fprintf(bw, "property uchar red\nproperty uchar green\nproperty uchar blue\nproperty uchar alpha\n")
+	}
+	if len(d.Indices) > 0 {
+		fmt.Fprintf(bw, "element face %d\n", len(d.Indices)/3)
+		fmt.Fprintf(bw, "property list uchar uint vertex_indices\n")
+	}
+	fmt.Fprintf(bw, "end_header\n")
+
+	var buf []byte
+	for i, p := range d.Positions {
+		floats := append([]float32(nil), p[:]...)
+		if len(d.Normals) > 0 {
+			floats = append(floats, d.Normals[i][:]...)
+		}
+		if len(d.TexCoords) > 0 {
+			floats = append(floats, d.TexCoords[i][:]...)
+		}
+		var color [4]uint8
+		if len(d.Colors) > 0 {
+			for j, c := range d.Colors[i] {
+				color[j] = uint8(mgl32.Clamp(c, 0, 1)*255 + 0.5)
+			}
+		}
+
+		if binaryFormat {
+			buf = buf[:0]
+			for _, f := range floats {
+				buf = appendUint32(buf, math.Float32bits(f))
+			}
+			if len(d.Colors) > 0 {
+				buf = append(buf, color[:]...)
+			}
+			bw.Write(buf)
+			continue
+		}
+		fields := make([]string, 0, len(floats)+4)
+		for _, f := range floats {
+			fields = append(fields, strconv.FormatFloat(float64(f), 'g', -1, 32))
+		}
+		if len(d.Colors) > 0 {
+			for _, c := range color {
+				fields = append(fields, strconv.Itoa(int(c)))
+			}
+		}
+		fmt.Fprintln(bw, strings.Join(fields, " "))
+	}
+
+	for i := 0; i+2 < len(d.Indices); i += 3 {
+		if binaryFormat {
+			buf = append(buf[:0], 3)
+			for _, idx := range d.Indices[i : i+3] {
+				buf = appendUint32(buf, idx)
+			}
+			bw.Write(buf)
+			continue
+		}
+		fmt.Fprintf(bw, "3 %d %d %d\n", d.Indices[i], d.Indices[i+1], d.Indices[i+2])
+	}
+	return bw.Flush()
+}
+
+// Append little endian uint32 to buf
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// Property of PLY element
+type plyProperty struct {
+	name      string
+	valueType string
+	countType string // empty if property isn't a list
+}
+
+// Element declared in PLY header
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// Size in bytes of PLY scalar type, 0 if unknown
+func plyTypeSize(t string) int {
+	switch t {
+	case "char", "uchar", "int8", "uint8":
+		return 1
+	case "short", "ushort", "int16", "uint16":
+		return 2
+	case "int", "uint", "float", "int32", "uint32", "float32":
+		return 4
+	case "double", "float64":
+		return 8
+	}
+	return 0
+}
+
+// Maximum value of PLY integer type, used for normalizing colors
+func plyTypeMax(t string) float64 {
+	switch t {
+	case "char", "int8":
+		return math.MaxInt8
+	case "uchar", "uint8":
+		return math.MaxUint8
+	case "short", "int16":
+		return math.MaxInt16
+	case "ushort", "uint16":
+		return math.MaxUint16
+	case "int", "int32":
+		return math.MaxInt32
+	case "uint", "uint32":
+		return math.MaxUint32
+	}
+	return 1
+}
+
+// State of PLY parser
+type plyParser struct {
+	name  string
+	line  int
+	r     *bufio.Reader
+	order binary.ByteOrder // nil for ascii
+	buf   [8]byte
+}
+
+func (p *plyParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *plyParser) readLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", p.errorf("unexpected end of file")
+		}
+		return "", err
+	}
+	p.line++
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Read binary scalar of given type
+func (p *plyParser) readBinary(t string) (float64, error) {
+	size := plyTypeSize(t)
+	if _, err := io.ReadFull(p.r, p.buf[:size]); err != nil {
+		return 0, fmt.Errorf("%s: unexpected end of binary data", p.name)
+	}
+	b := p.buf[:size]
+	switch t {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(p.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(p.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(p.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(p.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(p.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(p.order.Uint64(b)), nil
+	}
+}
+
+// Read properties of one element instance, lists are returned as extra values
+func (p *plyParser) readElement(e *plyElement, values [][]float64) error {
+	if p.order != nil {
+		for i, prop := range e.properties {
+			if prop.countType == "" {
+				v, err := p.readBinary(prop.valueType)
+				if err != nil {
+					return err
+				}
+				values[i] = append(values[i][:0], v)
+				continue
+			}
+			n, err := p.readBinary(prop.countType)
+			if err != nil {
+				return err
+			}
+			values[i] = values[i][:0]
+			for j := 0; j < int(n); j++ {
+				v, err := p.readBinary(prop.valueType)
+				if err != nil {
+					return err
+				}
+				values[i] = append(values[i], v)
+			}
+		}
+		return nil
+	}
+
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	next := func() (float64, error) {
+		if len(fields) == 0 {
+			return 0, p.errorf("too few values for element %s", e.name)
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, p.errorf("invalid number %q", fields[0])
+		}
+		fields = fields[1:]
+		return v, nil
+	}
+	for i, prop := range e.properties {
+		if prop.countType == "" {
+			v, err := next()
+			if err != nil {
+				return err
+			}
+			values[i] = append(values[i][:0], v)
+			continue
+		}
+		n, err := next()
+		if err != nil {
+			return err
+		}
+		values[i] = values[i][:0]
+		for j := 0; j < int(n); j++ {
+			v, err := next()
+			if err != nil {
+				return err
+			}
+			values[i] = append(values[i], v)
+		}
+	}
+	if len(fields) > 0 {
+		return p.errorf("too many values for element %s", e.name)
+	}
+	return nil
+}
+
+func parsePly(r io.Reader, name string) (*MeshData, error) {
+	p := &plyParser{
+		name: name,
+		r:    bufio.NewReader(r),
+	}
+
+	// Header
+	line, err := p.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line != "ply" {
+		return nil, p.errorf("not a PLY file")
+	}
+	var (
+		elements []*plyElement
+		format   string
+	)
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "end_header" {
+			break
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 || fields[2] != "1.0" {
+				return nil, p.errorf("invalid format line")
+			}
+			format = fields[1]
+			switch format {
+			case "ascii":
+			case "binary_little_endian":
+				p.order = binary.LittleEndian
+			case "binary_big_endian":
+				p.order = binary.BigEndian
+			default:
+				return nil, p.errorf("unknown format %q", format)
+			}
+		case "element":
+			if len(fields) != 3 {
+				return nil, p.errorf("invalid element line")
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, p.errorf("invalid element count %q", fields[2])
+			}
+			elements = append(elements, &plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, p.errorf("property before element")
+			}
+			e := elements[len(elements)-1]
+			var prop plyProperty
+			if len(fields) == 5 && fields[1] == "list" {
+				prop = plyProperty{name: fields[4], valueType: fields[3], countType: fields[2]}
+				if plyTypeSize(prop.countType) == 0 {
+					return nil, p.errorf("unknown type %q", prop.countType)
+				}
+			} else if len(fields) == 3 {
+				prop = plyProperty{name: fields[2], valueType: fields[1]}
+			} else {
+				return nil, p.errorf("invalid property line")
+			}
+			if plyTypeSize(prop.valueType) == 0 {
+				return nil, p.errorf("unknown type %q", prop.valueType)
+			}
+			e.properties = append(e.properties, prop)
+		case "comment", "obj_info":
+		default:
+			return nil, p.errorf("unknown header keyword %q", fields[0])
+		}
+	}
+	if format == "" {
+		return nil, p.errorf("missing format line")
+	}
+
+	// Body
+	d := &MeshData{}
+	for _, e := range elements {
+		values := make([][]float64, len(e.properties))
+		prop := map[string]int{}
+		for i, pr := range e.properties {
+			prop[pr.name] = i
+		}
+		lookup := func(names ...string) int {
+			for _, n := range names {
+				if i, ok := prop[n]; ok {
+					return i
+				}
+			}
+			return -1
+		}
+
+		switch e.name {
+		case "vertex":
+			x, y, z := lookup("x"), lookup("y"), lookup("z")
+			if x < 0 || y < 0 || z < 0 {
+				return nil, fmt.Errorf("%s: vertex element lacks position", name)
+			}
+			nx, ny, nz := lookup("nx"), lookup("ny"), lookup("nz")
+			s, t := lookup("s", "u", "texture_u"), lookup("t", "v", "texture_v")
+			red, green, blue := lookup("red", "r", "diffuse_red"), lookup("green", "g", "diffuse_green"), lookup("blue", "b", "diffuse_blue")
+			alpha := lookup("alpha", "a")
+			for _, i := range []int{x, y, z, nx, ny, nz, s, t, red, green, blue, alpha} {
+				if i >= 0 && e.properties[i].countType != "" {
+					return nil, fmt.Errorf("%s: vertex property %q must not be a list", name, e.properties[i].name)
+				}
+			}
+			color := func(i int) float32 {
+				return float32(values[i][0] / plyTypeMax(e.properties[i].valueType))
+			}
+			for i := 0; i < e.count; i++ {
+				if err := p.readElement(e, values); err != nil {
+					return nil, err
+				}
+				d.Positions = append(d.Positions, mgl32.Vec3{float32(values[x][0]), float32(values[y][0]), float32(values[z][0])})
+				if nx >= 0 && ny >= 0 && nz >= 0 {
+					d.Normals = append(d.Normals, mgl32.Vec3{float32(values[nx][0]), float32(values[ny][0]), float32(values[nz][0])})
+				}
+				if s >= 0 && t >= 0 {
+					d.TexCoords = append(d.TexCoords, mgl32.Vec2{float32(values[s][0]), float32(values[t][0])})
+				}
+				if red >= 0 && green >= 0 && blue >= 0 {
+					c := mgl32.Vec4{color(red), color(green), color(blue), 1}
+					if alpha >= 0 {
+						c[3] = color(alpha)
+					}
+					d.Colors = append(d.Colors, c)
+				}
+			}
+		case "face":
+			vi := lookup("vertex_indices", "vertex_index")
+			if vi < 0 || e.properties[vi].countType == "" {
+				return nil, fmt.Errorf("%s: face element lacks vertex indices", name)
+			}
+			for i := 0; i < e.count; i++ {
+				if err := p.readElement(e, values); err != nil {
+					return nil, err
+				}
+				face := values[vi]
+				if len(face) < 3 {
+					return nil, p.errorf("face needs at least 3 vertices, got %d", len(face))
+				}
+				points := make([]mgl32.Vec3, len(face))
+				for j, idx := range face {
+					if idx < 0 || int(idx) >= len(d.Positions) {
+						return nil, p.errorf("vertex index %d out of range", int(idx))
+					}
+					points[j] = d.Positions[int(idx)]
+				}
+				for _, tri := range triangulatePolygon(points) {
+					d.Indices = append(d.Indices, uint32(face[tri[0]]), uint32(face[tri[1]]), uint32(face[tri[2]]))
+				}
+			}
+		default:
+			// Other elements (e.g. edges) are skipped
+			for i := 0; i < e.count; i++ {
+				if err := p.readElement(e, values); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return d, nil
+}
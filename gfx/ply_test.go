@@ -0,0 +1,176 @@
+package gfx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestReadPly(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		positions []mgl32.Vec3
+		indices   []uint32
+		colors    []mgl32.Vec4
+	}{
+		{
+			name: "point cloud",
+			source: "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty float y\nproperty float z\nend_header\n" +
+				"1 2 3\n4 5 6\n",
+			positions: []mgl32.Vec3{{1, 2, 3}, {4, 5, 6}},
+		},
+		{
+			name: "quad is triangulated",
+			source: "ply\nformat ascii 1.0\ncomment quad\nelement vertex 4\nproperty float x\nproperty float y\nproperty float z\n" +
+				"element face 1\nproperty list uchar int vertex_indices\nend_header\n" +
+				"0 0 0\n1 0 0\n1 1 0\n0 1 0\n4 0 1 2 3\n",
+			positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+			indices:   []uint32{3, 0, 1, 1, 2, 3},
+		},
+		{
+			name: "colors are normalized",
+			source: "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\n" +
+				"property uchar red\nproperty uchar green\nproperty uchar blue\nend_header\n0 0 0 255 0 51\n",
+			positions: []mgl32.Vec3{{0, 0, 0}},
+			colors:    []mgl32.Vec4{{1, 0, 0.2, 1}},
+		},
+		{
+			name: "other elements are skipped",
+			source: "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\n" +
+				"element edge 1\nproperty int vertex1\nproperty int vertex2\nend_header\n0 0 0\n0 0\n",
+			positions: []mgl32.Vec3{{0, 0, 0}},
+		},
+		{
+			name: "binary big endian",
+			source: "ply\nformat binary_big_endian 1.0\nelement vertex 1\nproperty short x\nproperty short y\nproperty short z\nend_header\n" +
+				"\x00\x01\xff\xff\x01\x00",
+			positions: []mgl32.Vec3{{1, -1, 256}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ReadPly(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Positions) != len(tt.positions) {
+				t.Fatalf("got positions %v, want %v", d.Positions, tt.positions)
+			}
+			for i := range tt.positions {
+				if d.Positions[i] != tt.positions[i] {
+					t.Fatalf("got positions %v, want %v", d.Positions, tt.positions)
+				}
+			}
+			if !equalIndices(d.Indices, tt.indices) {
+				t.Errorf("got indices %v, want %v", d.Indices, tt.indices)
+			}
+			if len(d.Colors) != len(tt.colors) {
+				t.Fatalf("got colors %v, want %v", d.Colors, tt.colors)
+			}
+			for i := range tt.colors {
+				if !d.Colors[i].ApproxEqual(tt.colors[i]) {
+					t.Errorf("got colors %v, want %v", d.Colors, tt.colors)
+				}
+			}
+		})
+	}
+}
+
+func TestReadPlyErrors(t *testing.T) {
+	const vertexHeader = "ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\n"
+	const faceHeader = vertexHeader + "element face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n1 0 0\n0 1 0\n"
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{"not ply", "solid\n", "not a PLY file"},
+		{"empty", "", "unexpected end of file"},
+		{"missing format", "ply\nelement vertex 0\nend_header\n", "missing format line"},
+		{"unknown format", "ply\nformat binary 1.0\nend_header\n", "unknown format"},
+		{"truncated header", "ply\nformat ascii 1.0\nelement vertex 1\n", "unexpected end of file"},
+		{"negative count", "ply\nformat ascii 1.0\nelement vertex -1\nend_header\n", "invalid element count"},
+		{"unknown type", "ply\nformat ascii 1.0\nelement vertex 1\nproperty vec3 x\nend_header\n", "unknown type"},
+		{"property before element", "ply\nformat ascii 1.0\nproperty float x\nend_header\n", "property before element"},
+		{"missing position", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n0\n", "lacks position"},
+		{"list position", "ply\nformat ascii 1.0\nelement vertex 1\nproperty list uchar float x\nproperty float y\nproperty float z\nend_header\n0 0 0\n", "must not be a list"},
+		{"truncated vertices", vertexHeader + "end_header\n0 0 0\n1 0 0\n", "unexpected end of file"},
+		{"too few values", vertexHeader + "end_header\n0 0 0\n1 0\n0 1 0\n", "too few values"},
+		{"too many values", vertexHeader + "end_header\n0 0 0 0\n1 0 0\n0 1 0\n", "too many values"},
+		{"invalid number", vertexHeader + "end_header\n0 0 x\n1 0 0\n0 1 0\n", "invalid number"},
+		{"index out of range", faceHeader + "3 0 1 3\n", "vertex index 3 out of range"},
+		{"negative index", faceHeader + "3 0 -1 2\n", "vertex index -1 out of range"},
+		{"too few face vertices", faceHeader + "2 0 1\n", "at least 3 vertices"},
+		{"truncated binary", "ply\nformat binary_little_endian 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\nend_header\n\x00\x00\x00\x00", "unexpected end of binary data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadPly(strings.NewReader(tt.source))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWritePly(t *testing.T) {
+	mesh := GenCube(1, 1)
+	mesh.Colors = make([]mgl32.Vec4, len(mesh.Positions))
+	for i := range mesh.Colors {
+		mesh.Colors[i] = mgl32.Vec4{1, 0, 0, 1}
+	}
+	for _, binaryFormat := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := WritePly(&buf, mesh, binaryFormat); err != nil {
+			t.Fatal(err)
+		}
+		d, err := ReadPly(&buf)
+		if err != nil {
+			t.Fatalf("binary %v: %v", binaryFormat, err)
+		}
+		if len(d.Positions) != len(mesh.Positions) || len(d.Normals) != len(mesh.Normals) ||
+			len(d.TexCoords) != len(mesh.TexCoords) || len(d.Colors) != len(mesh.Colors) {
+			t.Fatalf("binary %v: got %d positions, %d normals, %d texture coordinates and %d colors",
+				binaryFormat, len(d.Positions), len(d.Normals), len(d.TexCoords), len(d.Colors))
+		}
+		for i := range mesh.Positions {
+			if !d.Positions[i].ApproxEqual(mesh.Positions[i]) || !d.Normals[i].ApproxEqual(mesh.Normals[i]) ||
+				!d.TexCoords[i].ApproxEqual(mesh.TexCoords[i]) || !d.Colors[i].ApproxEqual(mesh.Colors[i]) {
+				t.Fatalf("binary %v: vertex %d differs", binaryFormat, i)
+			}
+		}
+		if !equalIndices(d.Indices, mesh.Indices) {
+			t.Errorf("binary %v: got indices %v, want %v", binaryFormat, d.Indices, mesh.Indices)
+		}
+	}
+}
+
+func TestWritePlyErrors(t *testing.T) {
+	positions := []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}
+	tests := []struct {
+		name string
+		mesh *MeshData
+		err  string
+	}{
+		{"partial triangle", &MeshData{Positions: positions, Indices: []uint32{0, 1}}, "isn't a multiple of 3"},
+		{"index out of range", &MeshData{Positions: positions, Indices: []uint32{0, 1, 3}}, "vertex index 3 out of range"},
+		{"short normals", &MeshData{Positions: positions, Normals: positions[:2]}, "2 normals for 3 vertices"},
+		{"long texture coordinates", &MeshData{Positions: positions, TexCoords: make([]mgl32.Vec2, 4)}, "4 texture coordinates for 3 vertices"},
+		{"short colors", &MeshData{Positions: positions, Colors: make([]mgl32.Vec4, 1)}, "1 colors for 3 vertices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WritePly(&buf, tt.mesh, false)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("got %d bytes written", buf.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+//go:embed shaders/post/post.vert
+var postVertexShader string
+
+//go:embed shaders/post/post.glsl
+var postCommonShader string
+
+//go:embed shaders/post/copy.frag
+var copyFragmentShader string
+
+//go:embed shaders/post/gamma.frag
+var gammaFragmentShader string
+
+//go:embed shaders/post/fxaa.frag
+var fxaaFragmentShader string
+
+//go:embed shaders/post/vignette.frag
+var vignetteFragmentShader string
+
+//go:embed shaders/post/lut.frag
+var lutFragmentShader string
+
+//go:embed shaders/post/blur.frag
+var blurFragmentShader string
+
+// Names of uniforms set by PostProcess for each pass
+const (
+	UniformPostSource    = "source"
+	UniformPostTexelSize = "texelSize"
+	UniformPostStep      = "passStep"
+)
+
+// Range of tweakable parameter of post-processing pass, used by ui
+type PostParam struct {
+	Name    string   // name of parameter of pass material
+	Min     float32  // range of float32 and int32 values
+	Max     float32  //
+	Options []string // names of int32 values, which are indices
+}
+
+// PostPass is full-screen pass of post-processing, drawing a triangle with
+// material whose fragment shader reads output of previous pass.
+type PostPass struct {
+	Name     string
+	Enabled  bool
+	Material *Material
+	Params   []PostParam
+	Steps    int // number of times pass is applied, shader gets index of step
+
+	// Render auxiliary images from source of pass before it's drawn, and
+	// release them
+	prepare func(source uint32, width, height int32) error
+	dispose func()
+}
+
+// NewPostPass compiles fragment shader source into pass material, source
+// is prepended with declarations of post.glsl. Parameters should be set on
+// material before use.
+func NewPostPass(name, fragmentSource string, params ...PostParam) (*PostPass, error) {
+	program, err := LoadShaders([]Shader{
+		{Source: "#version 330 core\n" + postVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 330 core\n" + postCommonShader + fragmentSource + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("post pass %q: %v", name, err)
+	}
+	m := NewMaterial(program)
+	m.Name = name
+	m.ownsProgram = true
+	return &PostPass{Name: name, Enabled: true, Material: m, Params: params, Steps: 1}, nil
+}
+
+// NewGammaPass creates pass converting linear colors to gamma space, it
+// should follow passes working in linear space.
+func NewGammaPass() (*PostPass, error) {
+	p, err := NewPostPass("Gamma", gammaFragmentShader, PostParam{Name: "gamma", Min: 1, Max: 3})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("gamma", float32(2.2))
+	return p, nil
+}
+
+// NewFXAAPass creates antialiasing pass, which should follow gamma pass.
+func NewFXAAPass() (*PostPass, error) {
+	p, err := NewPostPass("FXAA", fxaaFragmentShader,
+		PostParam{Name: "edgeThreshold", Min: 0.063, Max: 0.333},
+		PostParam{Name: "edgeThresholdMin", Min: 0, Max: 0.1},
+		PostParam{Name: "subpixel", Min: 0, Max: 1})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("edgeThreshold", float32(0.125))
+	p.Material.SetParam("edgeThresholdMin", float32(0.0312))
+	p.Material.SetParam("subpixel", float32(0.75))
+	return p, nil
+}
+
+// NewVignettePass creates pass darkening corners of screen.
+func NewVignettePass() (*PostPass, error) {
+	p, err := NewPostPass("Vignette", vignetteFragmentShader,
+		PostParam{Name: "intensity", Min: 0, Max: 1},
+		PostParam{Name: "radius", Min: 0, Max: 1.5},
+		PostParam{Name: "softness", Min: 0.01, Max: 1})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("intensity", float32(0.5))
+	p.Material.SetParam("radius", float32(1))
+	p.Material.SetParam("softness", float32(0.6))
+	return p, nil
+}
+
+// NewColorGradingPass creates pass remapping colors by 3d lookup table
+// (e.g. from LoadLUT), which should follow gamma pass. LUT is left alone
+// by Dispose.
+func NewColorGradingPass(lut uint32) (*PostPass, error) {
+	p, err := NewPostPass("Color Grading", lutFragmentShader, PostParam{Name: "strength", Min: 0, Max: 1})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetTextureTarget("lut", gl.TEXTURE_3D, lut)
+	p.Material.SetParam("strength", float32(1))
+	return p, nil
+}
+
+// NewBlurPass creates separable gaussian blur pass, blurring each
+// direction in its own step.
+func NewBlurPass() (*PostPass, error) {
+	p, err := NewPostPass("Gaussian Blur", blurFragmentShader, PostParam{Name: "radius", Min: 1, Max: 32})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("radius", float32(4))
+	p.Steps = 2
+	return p, nil
+}
+
+// Dispose cleans up the resources.
+func (p *PostPass) Dispose() {
+	if p.dispose != nil {
+		p.dispose()
+	}
+	p.Material.Dispose()
+}
+
+// PostProcess applies enabled passes in order to image of scene, passing
+// output of each pass to the next one through a pair of framebuffers. The
+// last pass writes into target. Passes are drawn with
+// gl.FRAMEBUFFER_SRGB disabled, so conversion to gamma space is left to
+// passes (e.g. NewGammaPass).
+type PostProcess struct {
+	Passes  []*PostPass
+	buffers [2]*Framebuffer
+	copy    *PostPass
+	vao     uint32
+}
+
+// NewPostProcess creates post-processing of images of size, intermediate
+// images are stored in format (e.g. gl.RGBA16F).
+func NewPostProcess(width, height int32, format uint32) (*PostProcess, error) {
+	p := &PostProcess{}
+	for i := range p.buffers {
+		fb, err := NewFramebuffer(width, height, FramebufferSpec{Colors: []uint32{format}})
+		if err != nil {
+			p.Dispose()
+			return nil, err
+		}
+		p.buffers[i] = fb
+	}
+	copyPass, err := NewPostPass("Copy", copyFragmentShader)
+	if err != nil {
+		p.Dispose()
+		return nil, err
+	}
+	p.copy = copyPass
+	gl.GenVertexArrays(1, &p.vao)
+	return p, nil
+}
+
+// Find returns first pass with name, nil if not found.
+func (p *PostProcess) Find(name string) *PostPass {
+	for _, pass := range p.Passes {
+		if pass.Name == name {
+			return pass
+		}
+	}
+	return nil
+}
+
+// Size returns size of processed images.
+func (p *PostProcess) Size() (int32, int32) {
+	return p.buffers[0].Size()
+}
+
+// Resize changes size of processed images.
+func (p *PostProcess) Resize(width, height int32) error {
+	for _, fb := range p.buffers {
+		if err := fb.Resize(width, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProcessEvent follows drawable size of window when it changes size.
+func (p *PostProcess) ProcessEvent(event sdl.Event) error {
+	for _, fb := range p.buffers {
+		if err := fb.ProcessEvent(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply runs enabled passes on source texture and writes result into
+// target, or into default framebuffer when target is nil. Source is
+// copied when no pass is enabled.
+func (p *PostProcess) Apply(source uint32, target *Framebuffer) error {
+	type step struct {
+		pass  *PostPass
+		index int
+	}
+	var steps []step
+	for _, pass := range p.Passes {
+		if !pass.Enabled {
+			continue
+		}
+		for i := 0; i < pass.Steps; i++ {
+			steps = append(steps, step{pass, i})
+		}
+	}
+	if len(steps) == 0 {
+		steps = append(steps, step{p.copy, 0})
+	}
+
+	// Backup GL state
+	var lastFramebuffer int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastFramebuffer)
+	var lastViewport [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, &lastViewport[0])
+	var lastVertexArray int32
+	gl.GetIntegerv(gl.VERTEX_ARRAY_BINDING, &lastVertexArray)
+	lastEnableDepthTest := gl.IsEnabled(gl.DEPTH_TEST)
+	lastEnableBlend := gl.IsEnabled(gl.BLEND)
+	lastEnableCullFace := gl.IsEnabled(gl.CULL_FACE)
+	lastEnableFramebufferSRGB := gl.IsEnabled(gl.FRAMEBUFFER_SRGB)
+	lastBlend := saveBlend()
+	defer func() {
+		forgetBindings()
+		gl.BindVertexArray(uint32(lastVertexArray))
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(lastFramebuffer))
+		gl.Viewport(lastViewport[0], lastViewport[1], lastViewport[2], lastViewport[3])
+		setEnabled(gl.DEPTH_TEST, lastEnableDepthTest)
+		setEnabled(gl.BLEND, lastEnableBlend)
+		setEnabled(gl.CULL_FACE, lastEnableCullFace)
+		setEnabled(gl.FRAMEBUFFER_SRGB, lastEnableFramebufferSRGB)
+		lastBlend.restore()
+	}()
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.BLEND)
+	gl.Disable(gl.CULL_FACE)
+	gl.Disable(gl.FRAMEBUFFER_SRGB)
+	gl.BindVertexArray(p.vao)
+
+	width, height := p.Size()
+	texelSize := mgl32.Vec2{1 / float32(width), 1 / float32(height)}
+	input := source
+	for i, s := range steps {
+		if s.index == 0 && s.pass.prepare != nil {
+			if err := s.pass.prepare(input, width, height); err != nil {
+				return fmt.Errorf("post pass %q: %v", s.pass.Name, err)
+			}
+		}
+		output := p.buffers[i%2]
+		if i < len(steps)-1 {
+			output.Bind()
+		} else if target != nil {
+			target.Bind()
+		} else {
+			gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+			gl.Viewport(0, 0, width, height)
+		}
+		m := s.pass.Material
+		m.SetTexture(UniformPostSource, input)
+		m.SetParam(UniformPostTexelSize, texelSize)
+		m.SetParam(UniformPostStep, int32(s.index))
+		m.Use()
+		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+		input = output.ColorTexture(0)
+	}
+	return nil
+}
+
+// Dispose cleans up the resources, including passes.
+func (p *PostProcess) Dispose() {
+	for _, pass := range p.Passes {
+		pass.Dispose()
+	}
+	p.Passes = nil
+	if p.copy != nil {
+		p.copy.Dispose()
+	}
+	p.copy = nil
+	for i, fb := range p.buffers {
+		if fb != nil {
+			fb.Dispose()
+		}
+		p.buffers[i] = nil
+	}
+	if p.vao != 0 {
+		gl.DeleteVertexArrays(1, &p.vao)
+	}
+	p.vao = 0
+}
+
+// NewIdentityLUT creates 3d lookup table of size between 2 and 256, which
+// maps colors to themselves.
+func NewIdentityLUT(size int) (uint32, error) {
+	if size < 2 || size > 256 {
+		return 0, fmt.Errorf("lut size must be between 2 and 256, got %d", size)
+	}
+	data := make([]uint8, 0, size*size*size*4)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				data = append(data,
+					uint8(r*255/(size-1)), uint8(g*255/(size-1)), uint8(b*255/(size-1)), 255)
+			}
+		}
+	}
+	return uploadLUT(size, data), nil
+}
+
+// LoadLUT loads 3d lookup table from image of horizontal strip of size
+// square slices (e.g. 256x16), blue grows from slice to slice.
+func LoadLUT(file string) (uint32, error) {
+	return loadTextureFile(file, func(img image.Image) (uint32, error) {
+		bounds := img.Bounds()
+		size := bounds.Dy()
+		if size < 2 || bounds.Dx() != size*size {
+			return 0, fmt.Errorf("lut image must be a strip of %dx%d squares, got %dx%d",
+				size, size, bounds.Dx(), bounds.Dy())
+		}
+		rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+		// Red grows to the right and green downwards within slice
+		data := make([]uint8, 0, size*size*size*4)
+		for b := 0; b < size; b++ {
+			for g := 0; g < size; g++ {
+				row := rgba.Pix[g*rgba.Stride:]
+				data = append(data, row[b*size*4:(b+1)*size*4]...)
+			}
+		}
+		return uploadLUT(size, data), nil
+	})
+}
+
+func uploadLUT(size int, data []uint8) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_3D, texture)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.RGBA8, int32(size), int32(size), int32(size), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(data))
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+	return texture
+}
+
+func setEnabled(capability uint32, enabled bool) {
+	if enabled {
+		gl.Enable(capability)
+	} else {
+		gl.Disable(capability)
+	}
+}
+
+// Blend equation and factors, which passes and transparent draws change
+type blendState struct {
+	equationRGB, equationAlpha int32
+	srcRGB, dstRGB             int32
+	srcAlpha, dstAlpha         int32
+}
+
+func saveBlend() blendState {
+	var b blendState
+	gl.GetIntegerv(gl.BLEND_EQUATION_RGB, &b.equationRGB)
+	gl.GetIntegerv(gl.BLEND_EQUATION_ALPHA, &b.equationAlpha)
+	gl.GetIntegerv(gl.BLEND_SRC_RGB, &b.srcRGB)
+	gl.GetIntegerv(gl.BLEND_DST_RGB, &b.dstRGB)
+	gl.GetIntegerv(gl.BLEND_SRC_ALPHA, &b.srcAlpha)
+	gl.GetIntegerv(gl.BLEND_DST_ALPHA, &b.dstAlpha)
+	return b
+}
+
+func (b blendState) restore() {
+	gl.BlendEquationSeparate(uint32(b.equationRGB), uint32(b.equationAlpha))
+	gl.BlendFuncSeparate(uint32(b.srcRGB), uint32(b.dstRGB), uint32(b.srcAlpha), uint32(b.dstAlpha))
+}
@@ -0,0 +1,362 @@
+package gfx
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Generated primitives are centered at origin with Y axis pointing up,
+// front faces are counter-clockwise and texture coordinates range from
+// (0, 0) at bottom-left to (1, 1) at top-right.
+
+// GenPlane generates plane lying on XZ plane facing +Y.
+func GenPlane(width, depth float32, segmentsX, segmentsZ int) *MeshData {
+	d := &MeshData{}
+	d.appendQuad(mgl32.Vec3{}, mgl32.Vec3{width, 0, 0}, mgl32.Vec3{0, 0, -depth},
+		segmentsX, segmentsZ, mgl32.Vec2{1, 1})
+	return d
+}
+
+// GenGrid generates plane consisting of cells on XZ plane facing +Y,
+// texture coordinates repeat once per cell.
+func GenGrid(cellSize float32, columns, rows, subdivisions int) *MeshData {
+	d := &MeshData{}
+	subdivisions = maxInt(subdivisions, 1)
+	d.appendQuad(mgl32.Vec3{},
+		mgl32.Vec3{cellSize * float32(columns), 0, 0},
+		mgl32.Vec3{0, 0, -cellSize * float32(rows)},
+		columns*subdivisions, rows*subdivisions,
+		mgl32.Vec2{float32(columns), float32(rows)})
+	return d
+}
+
+// GenCube generates cube, each face has its own texture coordinates.
+func GenCube(size float32, segments int) *MeshData {
+	h := size / 2
+	faces := []struct {
+		center, u, v mgl32.Vec3
+	}{
+		{mgl32.Vec3{h, 0, 0}, mgl32.Vec3{0, 0, -size}, mgl32.Vec3{0, size, 0}},
+		{mgl32.Vec3{-h, 0, 0}, mgl32.Vec3{0, 0, size}, mgl32.Vec3{0, size, 0}},
+		{mgl32.Vec3{0, h, 0}, mgl32.Vec3{size, 0, 0}, mgl32.Vec3{0, 0, -size}},
+		{mgl32.Vec3{0, -h, 0}, mgl32.Vec3{size, 0, 0}, mgl32.Vec3{0, 0, size}},
+		{mgl32.Vec3{0, 0, h}, mgl32.Vec3{size, 0, 0}, mgl32.Vec3{0, size, 0}},
+		{mgl32.Vec3{0, 0, -h}, mgl32.Vec3{-size, 0, 0}, mgl32.Vec3{0, size, 0}},
+	}
+
+	d := &MeshData{}
+	for _, f := range faces {
+		d.appendQuad(f.center, f.u, f.v, segments, segments, mgl32.Vec2{1, 1})
+	}
+	return d
+}
+
+// GenUVSphere generates sphere made of longitude segments and latitude rings.
+func GenUVSphere(radius float32, segments, rings int) *MeshData {
+	rings = maxInt(rings, 2)
+	profile := make([]profilePoint, rings+1)
+	for i := range profile {
+		lat := -math.Pi/2 + math.Pi*float64(i)/float64(rings)
+		sin, cos := math.Sincos(lat)
+		profile[i] = profilePoint{
+			radius: radius * float32(cos),
+			y:      radius * float32(sin),
+			normal: mgl32.Vec2{float32(cos), float32(sin)},
+			v:      float32(i) / float32(rings),
+		}
+	}
+
+	d := &MeshData{}
+	d.appendRevolution(profile, segments)
+	return d
+}
+
+// GenIcosphere generates sphere by subdividing icosahedron, which has
+// evenly distributed vertices. Vertices are duplicated along the seam
+// of texture coordinates.
+func GenIcosphere(radius float32, subdivisions int) *MeshData {
+	t := float32((1 + math.Sqrt(5)) / 2)
+	positions := []mgl32.Vec3{
+		{-1, t, 0}, {1, t, 0}, {-1, -t, 0}, {1, -t, 0},
+		{0, -1, t}, {0, 1, t}, {0, -1, -t}, {0, 1, -t},
+		{t, 0, -1}, {t, 0, 1}, {-t, 0, -1}, {-t, 0, 1},
+	}
+	for i := range positions {
+		positions[i] = positions[i].Normalize()
+	}
+	indices := []uint32{
+		0, 11, 5, 0, 5, 1, 0, 1, 7, 0, 7, 10, 0, 10, 11,
+		1, 5, 9, 5, 11, 4, 11, 10, 2, 10, 7, 6, 7, 1, 8,
+		3, 9, 4, 3, 4, 2, 3, 2, 6, 3, 6, 8, 3, 8, 9,
+		4, 9, 5, 2, 4, 11, 6, 2, 10, 8, 6, 7, 9, 8, 1,
+	}
+
+	for s := 0; s < subdivisions; s++ {
+		midpoints := map[[2]uint32]uint32{}
+		midpoint := func(a, b uint32) uint32 {
+			key := [2]uint32{a, b}
+			if a > b {
+				key = [2]uint32{b, a}
+			}
+			if idx, ok := midpoints[key]; ok {
+				return idx
+			}
+			idx := uint32(len(positions))
+			positions = append(positions, positions[a].Add(positions[b]).Normalize())
+			midpoints[key] = idx
+			return idx
+		}
+
+		subdivided := make([]uint32, 0, len(indices)*4)
+		for i := 0; i < len(indices); i += 3 {
+			a, b, c := indices[i], indices[i+1], indices[i+2]
+			ab, bc, ca := midpoint(a, b), midpoint(b, c), midpoint(c, a)
+			subdivided = append(subdivided,
+				a, ab, ca,
+				b, bc, ab,
+				c, ca, bc,
+				ab, bc, ca)
+		}
+		indices = subdivided
+	}
+
+	d := &MeshData{}
+	for _, p := range positions {
+		phi := math.Atan2(float64(p[0]), float64(p[2]))
+		sin, cos := math.Sincos(phi)
+		d.Positions = append(d.Positions, p.Mul(radius))
+		d.Normals = append(d.Normals, p)
+		d.TexCoords = append(d.TexCoords, mgl32.Vec2{
+			float32(phi/(2*math.Pi) + 0.5),
+			float32(math.Asin(float64(mgl32.Clamp(p[1], -1, 1)))/math.Pi + 0.5),
+		})
+		d.Tangents = append(d.Tangents, mgl32.Vec4{float32(cos), 0, float32(-sin), 1})
+	}
+
+	// Triangles crossing the seam use duplicated vertices with wrapped u
+	wrapped := map[uint32]uint32{}
+	for i := 0; i < len(indices); i += 3 {
+		tri := indices[i : i+3]
+		minU, maxU := float32(1), float32(0)
+		for _, idx := range tri {
+			u := d.TexCoords[idx][0]
+			if u < minU {
+				minU = u
+			}
+			if u > maxU {
+				maxU = u
+			}
+		}
+		if maxU-minU <= 0.5 {
+			continue
+		}
+		for j, idx := range tri {
+			if d.TexCoords[idx][0] >= 0.5 {
+				continue
+			}
+			w, ok := wrapped[idx]
+			if !ok {
+				w = uint32(len(d.Positions))
+				d.Positions = append(d.Positions, d.Positions[idx])
+				d.Normals = append(d.Normals, d.Normals[idx])
+				d.TexCoords = append(d.TexCoords, d.TexCoords[idx].Add(mgl32.Vec2{1, 0}))
+				d.Tangents = append(d.Tangents, d.Tangents[idx])
+				wrapped[idx] = w
+			}
+			tri[j] = w
+		}
+	}
+	d.Indices = indices
+	return d
+}
+
+// GenCylinder generates cylinder along Y axis, with caps on both ends.
+func GenCylinder(radius, height float32, segments, stacks int) *MeshData {
+	stacks = maxInt(stacks, 1)
+	profile := make([]profilePoint, stacks+1)
+	for i := range profile {
+		v := float32(i) / float32(stacks)
+		profile[i] = profilePoint{
+			radius: radius,
+			y:      height * (v - 0.5),
+			normal: mgl32.Vec2{1, 0},
+			v:      v,
+		}
+	}
+
+	d := &MeshData{}
+	d.appendRevolution(profile, segments)
+	d.appendDisc(-height/2, radius, segments, false)
+	d.appendDisc(height/2, radius, segments, true)
+	return d
+}
+
+// GenCone generates cone along Y axis with apex at top, bottom is capped.
+func GenCone(radius, height float32, segments, stacks int) *MeshData {
+	stacks = maxInt(stacks, 1)
+	slant := mgl32.Vec2{height, radius}.Normalize()
+	profile := make([]profilePoint, stacks+1)
+	for i := range profile {
+		v := float32(i) / float32(stacks)
+		profile[i] = profilePoint{
+			radius: radius * (1 - v),
+			y:      height * (v - 0.5),
+			normal: slant,
+			v:      v,
+		}
+	}
+
+	d := &MeshData{}
+	d.appendRevolution(profile, segments)
+	d.appendDisc(-height/2, radius, segments, false)
+	return d
+}
+
+// GenTorus generates torus lying on XZ plane, majorRadius is distance from
+// center to center of tube.
+func GenTorus(majorRadius, minorRadius float32, majorSegments, minorSegments int) *MeshData {
+	minorSegments = maxInt(minorSegments, 3)
+	profile := make([]profilePoint, minorSegments+1)
+	for i := range profile {
+		theta := 2 * math.Pi * float64(i) / float64(minorSegments)
+		sin, cos := math.Sincos(theta)
+		profile[i] = profilePoint{
+			radius: majorRadius + minorRadius*float32(cos),
+			y:      minorRadius * float32(sin),
+			normal: mgl32.Vec2{float32(cos), float32(sin)},
+			v:      float32(i) / float32(minorSegments),
+		}
+	}
+
+	d := &MeshData{}
+	d.appendRevolution(profile, majorSegments)
+	return d
+}
+
+// GenCapsule generates capsule along Y axis, height is length of cylindrical
+// part, total height is height+2*radius. Rings are of each hemisphere.
+func GenCapsule(radius, height float32, segments, rings int) *MeshData {
+	rings = maxInt(rings, 1)
+	total := math.Pi*float64(radius) + float64(height)
+	profile := make([]profilePoint, 0, 2*(rings+1))
+	for h := 0; h < 2; h++ {
+		// Bottom hemisphere, then top one
+		from, offset := -math.Pi/2, -float64(height)/2
+		if h == 1 {
+			from, offset = 0, float64(height)/2
+		}
+		for i := 0; i <= rings; i++ {
+			lat := from + math.Pi/2*float64(i)/float64(rings)
+			sin, cos := math.Sincos(lat)
+			y := float64(radius)*sin + offset
+			arc := float64(radius)*(lat+math.Pi/2) + float64(height)*float64(h)
+			profile = append(profile, profilePoint{
+				radius: radius * float32(cos),
+				y:      float32(y),
+				normal: mgl32.Vec2{float32(cos), float32(sin)},
+				v:      float32(arc / total),
+			})
+		}
+	}
+
+	d := &MeshData{}
+	d.appendRevolution(profile, segments)
+	return d
+}
+
+// Point of profile curve revolved around Y axis, normal is (radial, y)
+type profilePoint struct {
+	radius float32
+	y      float32
+	normal mgl32.Vec2
+	v      float32
+}
+
+// Append surface made by revolving profile (ordered bottom-up) around Y axis
+func (d *MeshData) appendRevolution(profile []profilePoint, segments int) {
+	segments = maxInt(segments, 3)
+	base := uint32(len(d.Positions))
+	for _, p := range profile {
+		for s := 0; s <= segments; s++ {
+			u := float32(s) / float32(segments)
+			sin, cos := math.Sincos(2 * math.Pi * float64(u))
+			sinf, cosf := float32(sin), float32(cos)
+			d.Positions = append(d.Positions, mgl32.Vec3{p.radius * sinf, p.y, p.radius * cosf})
+			d.Normals = append(d.Normals, mgl32.Vec3{p.normal[0] * sinf, p.normal[1], p.normal[0] * cosf})
+			d.TexCoords = append(d.TexCoords, mgl32.Vec2{u, p.v})
+			d.Tangents = append(d.Tangents, mgl32.Vec4{cosf, 0, -sinf, 1})
+		}
+	}
+	d.appendGridIndices(base, segments, len(profile)-1)
+}
+
+// Append disc at height y facing +Y (up) or -Y, with planar texture coordinates
+func (d *MeshData) appendDisc(y, radius float32, segments int, up bool) {
+	segments = maxInt(segments, 3)
+	normal := mgl32.Vec3{0, -1, 0}
+	flipV := float32(-1)
+	if up {
+		normal = mgl32.Vec3{0, 1, 0}
+		flipV = 1
+	}
+
+	center := uint32(len(d.Positions))
+	d.Positions = append(d.Positions, mgl32.Vec3{0, y, 0})
+	d.Normals = append(d.Normals, normal)
+	d.TexCoords = append(d.TexCoords, mgl32.Vec2{0.5, 0.5})
+	d.Tangents = append(d.Tangents, mgl32.Vec4{1, 0, 0, 1})
+	for s := 0; s <= segments; s++ {
+		sin, cos := math.Sincos(2 * math.Pi * float64(s) / float64(segments))
+		x, z := float32(sin), float32(cos)
+		d.Positions = append(d.Positions, mgl32.Vec3{radius * x, y, radius * z})
+		d.Normals = append(d.Normals, normal)
+		d.TexCoords = append(d.TexCoords, mgl32.Vec2{0.5 + x/2, 0.5 - flipV*z/2})
+		d.Tangents = append(d.Tangents, mgl32.Vec4{1, 0, 0, 1})
+	}
+	for s := uint32(0); s < uint32(segments); s++ {
+		if up {
+			d.Indices = append(d.Indices, center, center+1+s, center+2+s)
+		} else {
+			d.Indices = append(d.Indices, center, center+2+s, center+1+s)
+		}
+	}
+}
+
+// Append subdivided quad, normal is cross product of u and v
+func (d *MeshData) appendQuad(center, u, v mgl32.Vec3, segmentsU, segmentsV int, uvScale mgl32.Vec2) {
+	segmentsU, segmentsV = maxInt(segmentsU, 1), maxInt(segmentsV, 1)
+	normal := u.Cross(v).Normalize()
+	tangent := u.Normalize().Vec4(1)
+	base := uint32(len(d.Positions))
+	for j := 0; j <= segmentsV; j++ {
+		t := float32(j) / float32(segmentsV)
+		for i := 0; i <= segmentsU; i++ {
+			s := float32(i) / float32(segmentsU)
+			d.Positions = append(d.Positions, center.Add(u.Mul(s-0.5)).Add(v.Mul(t-0.5)))
+			d.Normals = append(d.Normals, normal)
+			d.TexCoords = append(d.TexCoords, mgl32.Vec2{s * uvScale[0], t * uvScale[1]})
+			d.Tangents = append(d.Tangents, tangent)
+		}
+	}
+	d.appendGridIndices(base, segmentsU, segmentsV)
+}
+
+// Append indices of grid of (columns+1)*(rows+1) vertices starting at base
+func (d *MeshData) appendGridIndices(base uint32, columns, rows int) {
+	stride := uint32(columns + 1)
+	for j := uint32(0); j < uint32(rows); j++ {
+		for i := uint32(0); i < uint32(columns); i++ {
+			a := base + j*stride + i
+			b, c := a+1, a+stride
+			d.Indices = append(d.Indices, a, b, c+1, a, c+1, c)
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,69 @@
+package gfx
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestPrimitives(t *testing.T) {
+	tests := []struct {
+		name      string
+		mesh      *MeshData
+		vertices  int
+		triangles int
+		maxUV     mgl32.Vec2
+	}{
+		{"plane", GenPlane(2, 1, 2, 3), 12, 12, mgl32.Vec2{1, 1}},
+		{"grid", GenGrid(0.5, 4, 2, 2), 45, 64, mgl32.Vec2{4, 2}},
+		{"cube", GenCube(1, 2), 54, 48, mgl32.Vec2{1, 1}},
+		{"uv sphere", GenUVSphere(1, 8, 4), 45, 64, mgl32.Vec2{1, 1}},
+		{"icosahedron", GenIcosphere(1, 0), 15, 20, mgl32.Vec2{1.5, 1}}, // u wraps past 1 at seam
+		{"icosphere", GenIcosphere(1, 2), 172, 320, mgl32.Vec2{1.5, 1}},
+		{"cylinder", GenCylinder(1, 2, 8, 2), 47, 48, mgl32.Vec2{1, 1}},
+		{"cone", GenCone(1, 2, 8, 2), 37, 40, mgl32.Vec2{1, 1}},
+		{"torus", GenTorus(1, 0.25, 8, 6), 63, 96, mgl32.Vec2{1, 1}},
+		{"capsule", GenCapsule(0.5, 2, 8, 3), 72, 112, mgl32.Vec2{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.mesh
+			if len(d.Positions) != tt.vertices || len(d.Indices) != tt.triangles*3 {
+				t.Fatalf("got %d vertices and %d indices, want %d and %d",
+					len(d.Positions), len(d.Indices), tt.vertices, tt.triangles*3)
+			}
+			if len(d.Normals) != len(d.Positions) || len(d.TexCoords) != len(d.Positions) {
+				t.Fatalf("got %d normals and %d texture coordinates of %d vertices",
+					len(d.Normals), len(d.TexCoords), len(d.Positions))
+			}
+			if err := d.checkTriangles(); err != nil {
+				t.Fatal(err)
+			}
+			for i, n := range d.Normals {
+				if math.Abs(float64(n.Len()-1)) > 1e-5 {
+					t.Fatalf("normal %d isn't unit: %v", i, n)
+				}
+			}
+			for i, uv := range d.TexCoords {
+				if uv[0] < -1e-5 || uv[1] < -1e-5 || uv[0] > tt.maxUV[0]+1e-5 || uv[1] > tt.maxUV[1]+1e-5 {
+					t.Fatalf("texture coordinate %d out of range: %v", i, uv)
+				}
+			}
+
+			// Front faces are counter-clockwise, i.e. agree with vertex normals
+			for i := 0; i < len(d.Indices); i += 3 {
+				i0, i1, i2 := d.Indices[i], d.Indices[i+1], d.Indices[i+2]
+				p0, p1, p2 := d.Positions[i0], d.Positions[i1], d.Positions[i2]
+				face := p1.Sub(p0).Cross(p2.Sub(p0))
+				if face.Len() < 1e-6 {
+					continue
+				}
+				n := d.Normals[i0].Add(d.Normals[i1]).Add(d.Normals[i2])
+				if face.Dot(n) <= 0 {
+					t.Fatalf("triangle %d is clockwise", i/3)
+				}
+			}
+		})
+	}
+}
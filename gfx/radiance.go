@@ -0,0 +1,152 @@
+package gfx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Largest width or height of HDR image, beyond sizes of GL textures
+const maxHDRSize = 1 << 15
+
+// HDRImage holds linear RGB colors, rows run from top to bottom.
+type HDRImage struct {
+	Width  int
+	Height int
+	Pix    []float32 // 3 components per pixel
+}
+
+// LoadHDR loads image in Radiance RGBE format (.hdr).
+func LoadHDR(file string) (*HDRImage, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("hdr image %q not found on disk: %v", file, err)
+	}
+	defer f.Close()
+	img, err := ReadHDR(f)
+	if err != nil {
+		return nil, fmt.Errorf("load hdr image %q failed: %v", file, err)
+	}
+	return img, nil
+}
+
+// ReadHDR decodes image in Radiance RGBE format, with run-length encoded
+// or flat scanlines. Only the standard -Y height +X width orientation is
+// supported.
+func ReadHDR(r io.Reader) (*HDRImage, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "#?") {
+		return nil, fmt.Errorf("missing radiance signature")
+	}
+
+	// Header ends with empty line
+	for {
+		line, err = br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("unsupported format %q", line[len("FORMAT="):])
+		}
+	}
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(line, "-Y %d +X %d", &height, &width); err != nil {
+		return nil, fmt.Errorf("unsupported resolution %q", strings.TrimSpace(line))
+	}
+	if width <= 0 || height <= 0 || width > maxHDRSize || height > maxHDRSize {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+
+	// Pixels grow with scanlines read, so truncated input doesn't
+	// allocate the whole image
+	img := &HDRImage{Width: width, Height: height}
+	scanline := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		if err := readScanline(br, scanline); err != nil {
+			return nil, fmt.Errorf("scanline %d: %v", y, err)
+		}
+		img.Pix = append(img.Pix, make([]float32, width*3)...)
+		row := img.Pix[y*width*3:]
+		for x := 0; x < width; x++ {
+			rgbe := scanline[x*4 : x*4+4]
+			if rgbe[3] == 0 {
+				continue
+			}
+			scale := float32(math.Ldexp(1, int(rgbe[3])-(128+8)))
+			row[x*3] = float32(rgbe[0]) * scale
+			row[x*3+1] = float32(rgbe[1]) * scale
+			row[x*3+2] = float32(rgbe[2]) * scale
+		}
+	}
+	return img, nil
+}
+
+// Read scanline of RGBE pixels, which are either flat or run-length
+// encoded per component
+func readScanline(br *bufio.Reader, scanline []byte) error {
+	width := len(scanline) / 4
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return err
+	}
+	if width < 8 || width > 0x7fff || header[0] != 2 || header[1] != 2 || header[2]&0x80 != 0 {
+		copy(scanline, header)
+		_, err := io.ReadFull(br, scanline[4:])
+		return err
+	}
+	if int(header[2])<<8|int(header[3]) != width {
+		return fmt.Errorf("wrong scanline width")
+	}
+
+	// Components are stored one after another
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				run := int(count) - 128
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+run > width {
+					return fmt.Errorf("run exceeds scanline")
+				}
+				for ; run > 0; run-- {
+					scanline[x*4+c] = value
+					x++
+				}
+			} else {
+				if count == 0 || x+int(count) > width {
+					return fmt.Errorf("bad literal length")
+				}
+				for n := 0; n < int(count); n++ {
+					value, err := br.ReadByte()
+					if err != nil {
+						return err
+					}
+					scanline[x*4+c] = value
+					x++
+				}
+			}
+		}
+	}
+	return nil
+}
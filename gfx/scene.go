@@ -0,0 +1,329 @@
+package gfx
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Node of scene graph, transform is relative to parent. World matrices
+// are cached and recomputed lazily when node or its ancestors move.
+type Node struct {
+	Name     string
+	Mesh     *Mesh
+	Material *Material
+	Visible  bool
+
+	position   mgl32.Vec3
+	rotation   mgl32.Quat
+	scale      mgl32.Vec3
+	local      mgl32.Mat4
+	world      mgl32.Mat4
+	localDirty bool
+	worldDirty bool
+	moves      uint32 // counts transitions of world matrix to stale
+	parent     *Node
+	children   []*Node
+}
+
+// NewNode creates visible node with identity transform.
+func NewNode(name string) *Node {
+	return &Node{
+		Name:       name,
+		Visible:    true,
+		rotation:   mgl32.QuatIdent(),
+		scale:      mgl32.Vec3{1, 1, 1},
+		localDirty: true,
+		worldDirty: true,
+	}
+}
+
+// NewMeshNode creates node drawing mesh with material.
+func NewMeshNode(name string, mesh *Mesh, material *Material) *Node {
+	n := NewNode(name)
+	n.Mesh = mesh
+	n.Material = material
+	return n
+}
+
+// Position returns translation relative to parent.
+func (n *Node) Position() mgl32.Vec3 {
+	return n.position
+}
+
+// SetPosition sets translation relative to parent.
+func (n *Node) SetPosition(position mgl32.Vec3) {
+	n.position = position
+	n.invalidate()
+}
+
+// Rotation returns rotation relative to parent.
+func (n *Node) Rotation() mgl32.Quat {
+	return n.rotation
+}
+
+// SetRotation sets rotation relative to parent.
+func (n *Node) SetRotation(rotation mgl32.Quat) {
+	n.rotation = rotation
+	n.invalidate()
+}
+
+// Scale returns scale relative to parent.
+func (n *Node) Scale() mgl32.Vec3 {
+	return n.scale
+}
+
+// SetScale sets scale relative to parent.
+func (n *Node) SetScale(scale mgl32.Vec3) {
+	n.scale = scale
+	n.invalidate()
+}
+
+// SetLocalMatrix sets transform relative to parent by decomposing affine
+// matrix m into translation, rotation and scale.
+func (n *Node) SetLocalMatrix(m mgl32.Mat4) {
+	n.position, n.rotation, n.scale = decomposeMatrix(m)
+	n.invalidate()
+}
+
+// LocalMatrix returns transform relative to parent.
+func (n *Node) LocalMatrix() mgl32.Mat4 {
+	if n.localDirty {
+		n.local = mgl32.Translate3D(n.position[0], n.position[1], n.position[2]).
+			Mul4(n.rotation.Mat4()).
+			Mul4(mgl32.Scale3D(n.scale[0], n.scale[1], n.scale[2]))
+		n.localDirty = false
+	}
+	return n.local
+}
+
+// WorldMatrix returns transform relative to root of scene.
+func (n *Node) WorldMatrix() mgl32.Mat4 {
+	if n.worldDirty {
+		if n.parent != nil {
+			n.world = n.parent.WorldMatrix().Mul4(n.LocalMatrix())
+		} else {
+			n.world = n.LocalMatrix()
+		}
+		n.worldDirty = false
+	}
+	return n.world
+}
+
+// WorldPosition returns position of node relative to root of scene.
+func (n *Node) WorldPosition() mgl32.Vec3 {
+	return n.WorldMatrix().Col(3).Vec3()
+}
+
+// WorldBounds returns bounding box of mesh in world space, which is empty
+// when node has no mesh or its bounds are unknown.
+func (n *Node) WorldBounds() AABB {
+	if n.Mesh == nil {
+		return EmptyAABB()
+	}
+	return n.Mesh.Bounds().Transform(n.WorldMatrix())
+}
+
+// Parent returns parent of node, nil for root.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Children returns children of node, which shouldn't be modified.
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+// AddChild attaches child to node, detaching it from previous parent.
+// Child keeps its local transform. It fails when child is node or its
+// ancestor.
+func (n *Node) AddChild(child *Node) error {
+	return child.SetParent(n, false)
+}
+
+// RemoveChild detaches child from node.
+func (n *Node) RemoveChild(child *Node) {
+	if child.parent == n {
+		child.SetParent(nil, false)
+	}
+}
+
+// SetParent moves node under parent (nil detaches it). When keepWorld is
+// set, local transform is adjusted so node stays in place. It fails when
+// parent is node or its descendant, which would make a cycle.
+func (n *Node) SetParent(parent *Node, keepWorld bool) error {
+	if n.parent == parent {
+		return nil
+	}
+	for p := parent; p != nil; p = p.parent {
+		if p == n {
+			return fmt.Errorf("node %q can't be attached to its descendant %q", n.Name, parent.Name)
+		}
+	}
+
+	world := n.WorldMatrix()
+	if n.parent != nil {
+		siblings := n.parent.children
+		for i, c := range siblings {
+			if c == n {
+				n.parent.children = append(siblings[:i], siblings[i+1:]...)
+				break
+			}
+		}
+	}
+	n.parent = parent
+	if parent != nil {
+		parent.children = append(parent.children, n)
+	}
+
+	if keepWorld {
+		if parent != nil {
+			world = parent.WorldMatrix().Inv().Mul4(world)
+		}
+		n.SetLocalMatrix(world)
+	} else {
+		n.invalidate()
+	}
+	return nil
+}
+
+// Traverse calls fn on node and its descendants in depth-first order,
+// children of node are skipped when fn returns false.
+func (n *Node) Traverse(fn func(node *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.children {
+		c.Traverse(fn)
+	}
+}
+
+// Find returns first node with name in subtree of node, nil if not found.
+func (n *Node) Find(name string) *Node {
+	var found *Node
+	n.Traverse(func(node *Node) bool {
+		if found == nil && node.Name == name {
+			found = node
+		}
+		return found == nil
+	})
+	return found
+}
+
+// Draw renders visible meshes in subtree of node viewed by camera, subtree
+// of invisible node is skipped.
+func (n *Node) Draw(camera Camera) {
+	projection, view := camera.Projection(), camera.View()
+	var program uint32
+	forgetBindings()
+	n.Traverse(func(node *Node) bool {
+		if !node.Visible {
+			return false
+		}
+		if node.Mesh == nil || node.Material == nil {
+			return true
+		}
+
+		// Camera matrices are set once per program
+		node.Material.Use()
+		model := node.WorldMatrix()
+		if node.Material.Program != program {
+			program = node.Material.Program
+			node.Material.setTransforms(&projection, &view, &model)
+		} else {
+			node.Material.setTransforms(nil, nil, &model)
+		}
+		node.Mesh.Draw()
+		return true
+	})
+}
+
+// Draw meshes of nodes in order, camera matrices are set once per program
+func drawNodes(nodes []*Node, projection, view mgl32.Mat4) {
+	var program uint32
+	forgetBindings()
+	for _, node := range nodes {
+		node.Material.Use()
+		model := node.WorldMatrix()
+		if node.Material.Program != program {
+			program = node.Material.Program
+			node.Material.setTransforms(&projection, &view, &model)
+		} else {
+			node.Material.setTransforms(nil, nil, &model)
+		}
+		node.Mesh.Draw()
+	}
+}
+
+// Draw meshes of nodes back to front with alpha blending, depth is
+// tested but not written
+func drawTransparent(nodes []*Node, projection, view mgl32.Mat4) {
+	if len(nodes) == 0 {
+		return
+	}
+
+	// Nodes are sorted by view depth of their centers
+	depths := make(map[*Node]float32, len(nodes))
+	for _, node := range nodes {
+		center := node.WorldPosition()
+		if bounds := node.WorldBounds(); !bounds.IsEmpty() {
+			center = bounds.Center()
+		}
+		depths[node] = -mgl32.TransformCoordinate(center, view)[2]
+	}
+	sorted := append([]*Node(nil), nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return depths[sorted[i]] > depths[sorted[j]]
+	})
+
+	lastEnableBlend := gl.IsEnabled(gl.BLEND)
+	lastBlend := saveBlend()
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	gl.Enable(gl.BLEND)
+	gl.BlendEquation(gl.FUNC_ADD)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+	drawNodes(sorted, projection, view)
+	gl.DepthMask(lastDepthMask)
+	setEnabled(gl.BLEND, lastEnableBlend)
+	lastBlend.restore()
+}
+
+// Mark cached matrices of node and its descendants as stale
+func (n *Node) invalidate() {
+	n.localDirty = true
+	n.invalidateWorld()
+}
+
+func (n *Node) invalidateWorld() {
+	// Descendants of stale node are stale already
+	if n.worldDirty {
+		return
+	}
+	n.worldDirty = true
+	n.moves++
+	for _, c := range n.children {
+		c.invalidateWorld()
+	}
+}
+
+// Decompose affine matrix into translation, rotation and scale, negative
+// determinant is represented by negative scale along X axis
+func decomposeMatrix(m mgl32.Mat4) (mgl32.Vec3, mgl32.Quat, mgl32.Vec3) {
+	translation := m.Col(3).Vec3()
+	scale := mgl32.Vec3{m.Col(0).Vec3().Len(), m.Col(1).Vec3().Len(), m.Col(2).Vec3().Len()}
+	if m.Mat3().Det() < 0 {
+		scale[0] = -scale[0]
+	}
+	var rot mgl32.Mat4
+	for c := 0; c < 3; c++ {
+		if scale[c] != 0 {
+			rot.SetCol(c, m.Col(c).Mul(1/scale[c]))
+		}
+	}
+	rot[15] = 1
+	return translation, mgl32.Mat4ToQuat(rot).Normalize(), scale
+}
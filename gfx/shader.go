@@ -0,0 +1,103 @@
+package gfx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Shader stage of program, source must be terminated by "\x00"
+type Shader struct {
+	Source string
+	Type   uint32
+	id     uint32
+}
+
+// LoadShaders compiles shaders and links them into program.
+func LoadShaders(ss []Shader) (uint32, error) {
+	if len(ss) < 2 {
+		return 0, errors.New("at least vertex and fragment shaders are needed")
+	}
+	return linkShaders(ss, nil)
+}
+
+// LoadComputeShader compiles compute shader source into program.
+func LoadComputeShader(source string) (uint32, error) {
+	return linkShaders([]Shader{{Source: source, Type: gl.COMPUTE_SHADER}}, nil)
+}
+
+// LoadTransformFeedbackShader compiles vertex shader source into program
+// without rasterization, whose outputs named varyings are captured
+// interleaved into transform feedback buffer.
+func LoadTransformFeedbackShader(source string, varyings ...string) (uint32, error) {
+	return linkShaders([]Shader{{Source: source, Type: gl.VERTEX_SHADER}}, func(program uint32) {
+		names := make([]string, len(varyings))
+		for i, name := range varyings {
+			names[i] = name + "\x00"
+		}
+		cnames, free := gl.Strs(names...)
+		defer free()
+		gl.TransformFeedbackVaryings(program, int32(len(names)), cnames, gl.INTERLEAVED_ATTRIBS)
+	})
+}
+
+// Compile shaders and link them into program, beforeLink could set up
+// program before linking
+func linkShaders(ss []Shader, beforeLink func(program uint32)) (uint32, error) {
+	for i := range ss {
+		shaderID, err := compileShader(ss[i].Source, ss[i].Type)
+		if err != nil {
+			return 0, err
+		}
+		ss[i].id = shaderID
+		defer gl.DeleteShader(shaderID)
+	}
+
+	program := gl.CreateProgram()
+	for _, v := range ss {
+		gl.AttachShader(program, v.id)
+	}
+	if beforeLink != nil {
+		beforeLink(program)
+	}
+	gl.LinkProgram(program)
+
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+
+		return 0, fmt.Errorf("failed to link program: %v", log)
+	}
+
+	return program, nil
+}
+
+func compileShader(source string, shaderType uint32) (uint32, error) {
+	shader := gl.CreateShader(shaderType)
+
+	csources, free := gl.Strs(source)
+	gl.ShaderSource(shader, 1, csources, nil)
+	free()
+	gl.CompileShader(shader)
+
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+
+		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+	}
+
+	return shader, nil
+}
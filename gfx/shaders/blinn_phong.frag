@@ -0,0 +1,27 @@
+uniform vec4 diffuseColor;
+uniform sampler2D diffuseMap;
+uniform bool useDiffuseMap;
+uniform vec3 specularColor;
+uniform float shininess;
+uniform vec3 emissiveColor;
+uniform sampler2D normalMap;
+uniform bool useNormalMap;
+
+void main() {
+    vec4 albedo = diffuseColor;
+    if (useDiffuseMap) {
+        albedo *= texture(diffuseMap, fragTexCoord);
+    }
+
+    Surface s;
+    s.model = SHADING_BLINN_PHONG;
+    s.position = fragPosition;
+    s.N = surfaceNormal(useNormalMap, texture(normalMap, fragTexCoord).rgb, 1.0);
+    s.V = normalize(cameraPosition - fragPosition);
+    s.albedo = albedo.rgb;
+    s.occlusion = 1.0;
+    s.specular = specularColor;
+    s.shininess = shininess;
+    s.emissive = emissiveColor;
+    outputSurface(s, albedo.a);
+}
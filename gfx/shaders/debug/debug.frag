@@ -0,0 +1,13 @@
+in vec4 fragColor;
+layout(location = 0) out vec4 outputColor;
+
+// Alpha 0 keeps normals and indirect light of scene for screen-space
+// effects
+layout(location = 1) out vec4 outputNormal;
+layout(location = 2) out vec4 outputIndirect;
+
+void main() {
+    outputColor = fragColor;
+    outputNormal = vec4(0.0);
+    outputIndirect = vec4(0.0);
+}
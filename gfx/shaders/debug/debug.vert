@@ -0,0 +1,11 @@
+uniform mat4 viewProjection;
+
+layout(location = 0) in vec3 position;
+layout(location = 1) in vec4 color;
+
+out vec4 fragColor;
+
+void main() {
+    fragColor = color;
+    gl_Position = viewProjection * vec4(position, 1.0);
+}
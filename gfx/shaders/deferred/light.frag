@@ -0,0 +1,64 @@
+// Must match attachments of G-buffer of DeferredRenderer
+layout(binding = 0) uniform sampler2D gbufferAlbedo;
+layout(binding = 1) uniform sampler2D gbufferNormal;
+layout(binding = 2) uniform sampler2D gbufferMaterial;
+layout(binding = 3) uniform sampler2D gbufferEmissive;
+layout(binding = 4) uniform sampler2D gbufferDepth;
+
+uniform mat4 inverseViewProjection;
+uniform mat4 camera;
+
+// Light of volume being drawn, -1 for indirect light and lights without volume
+uniform int lightIndex;
+
+// Whether light is drawn by its own volume, must match lightVolume
+bool hasVolume(Light light) {
+    return light.type != LIGHT_DIRECTIONAL && light.range > 0.0;
+}
+
+void main() {
+    ivec2 pixel = ivec2(gl_FragCoord.xy);
+    float depth = texelFetch(gbufferDepth, pixel, 0).r;
+    if (depth >= 1.0) {
+        discard;
+    }
+
+    // Position is reconstructed from depth
+    vec2 uv = (vec2(pixel) + 0.5) / vec2(textureSize(gbufferDepth, 0));
+    vec4 world = inverseViewProjection * vec4(vec3(uv, depth) * 2.0 - 1.0, 1.0);
+    fragPosition = world.xyz / world.w;
+    fragViewDepth = -(camera * vec4(fragPosition, 1.0)).z;
+
+    vec4 albedo = texelFetch(gbufferAlbedo, pixel, 0);
+    vec4 normal = texelFetch(gbufferNormal, pixel, 0);
+    vec4 material = texelFetch(gbufferMaterial, pixel, 0);
+    Surface s;
+    s.model = int(normal.w + 0.5);
+    s.position = fragPosition;
+    s.N = normalize(normal.xyz);
+    s.V = normalize(cameraPosition - fragPosition);
+    s.albedo = albedo.rgb;
+    s.occlusion = albedo.a;
+    s.specular = material.rgb;
+    s.shininess = material.a;
+    s.metallic = material.r;
+    s.roughness = material.g;
+    s.emissive = texelFetch(gbufferEmissive, pixel, 0).rgb;
+
+    vec3 color = vec3(0.0);
+    vec3 indirect = vec3(0.0);
+    if (lightIndex >= 0) {
+        color = directLight(s, lights[lightIndex]);
+    } else {
+        indirect = indirectLight(s);
+        color = indirect + s.emissive;
+        for (int i = 0; i < min(lightCount, MAX_LIGHTS); i++) {
+            if (!hasVolume(lights[i])) {
+                color += directLight(s, lights[i]);
+            }
+        }
+    }
+    outputColor = vec4(color, 1.0);
+    outputNormal = vec4(0.0); // adds nothing, normals are in G-buffer
+    outputIndirect = vec4(indirect, 1.0);
+}
@@ -0,0 +1,14 @@
+layout(location = 0) in vec3 vert;
+
+uniform bool fullScreen;
+uniform mat4 volumeMatrix;
+
+void main() {
+    if (fullScreen) {
+        // Triangle covering the screen, as in post-processing
+        vec2 p = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+        gl_Position = vec4(p * 2.0 - 1.0, 0.0, 1.0);
+    } else {
+        gl_Position = volumeMatrix * vec4(vert, 1.0);
+    }
+}
@@ -0,0 +1,35 @@
+in vec2 fragTexCoord;
+out vec4 outputColor;
+
+float geometrySchlickGGX(float NdotV, float roughness) {
+    float k = roughness * roughness / 2.0;
+    return NdotV / (NdotV * (1.0 - k) + k);
+}
+
+// Scale and bias of F0 in split-sum approximation of specular lighting,
+// indexed by NdotV and roughness
+void main() {
+    float NdotV = max(fragTexCoord.x, 0.0001);
+    float roughness = fragTexCoord.y;
+    vec3 V = vec3(sqrt(1.0 - NdotV * NdotV), 0.0, NdotV);
+    vec3 N = vec3(0.0, 0.0, 1.0);
+    const uint sampleCount = 1024u;
+    float scale = 0.0;
+    float bias = 0.0;
+    for (uint i = 0u; i < sampleCount; i++) {
+        vec3 H = importanceSampleGGX(hammersley(i, sampleCount), N, roughness);
+        vec3 L = normalize(2.0 * dot(V, H) * H - V);
+        float NdotL = max(L.z, 0.0);
+        if (NdotL <= 0.0) {
+            continue;
+        }
+        float NdotH = max(H.z, 0.0);
+        float VdotH = max(dot(V, H), 0.0);
+        float G = geometrySchlickGGX(NdotL, roughness) * geometrySchlickGGX(NdotV, roughness);
+        float visibility = G * VdotH / (NdotH * NdotV);
+        float Fc = pow(1.0 - VdotH, 5.0);
+        scale += (1.0 - Fc) * visibility;
+        bias += Fc * visibility;
+    }
+    outputColor = vec4(scale / float(sampleCount), bias / float(sampleCount), 0.0, 1.0);
+}
@@ -0,0 +1,15 @@
+uniform mat4 viewProjection;
+
+out vec3 fragDirection;
+
+// Unit cube drawn as triangle strip of 14 vertices generated from index
+void main() {
+    int b = 1 << gl_VertexID;
+    vec3 p = vec3((0x287a & b) != 0, (0x02af & b) != 0, (0x31e3 & b) != 0) * 2.0 - 1.0;
+    fragDirection = p;
+    gl_Position = viewProjection * vec4(p, 1.0);
+#ifdef SKYBOX
+    // Sky lies on far plane, behind everything
+    gl_Position = gl_Position.xyww;
+#endif
+}
@@ -0,0 +1,11 @@
+uniform sampler2D equirectMap;
+
+in vec3 fragDirection;
+out vec4 outputColor;
+
+// Top row of equirectangular image is at texture coordinate 0
+void main() {
+    vec3 d = normalize(fragDirection);
+    vec2 uv = vec2(atan(d.z, d.x) / (2.0 * PI) + 0.5, 0.5 - asin(clamp(d.y, -1.0, 1.0)) / PI);
+    outputColor = vec4(texture(equirectMap, uv).rgb, 1.0);
+}
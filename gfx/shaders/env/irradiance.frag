@@ -0,0 +1,27 @@
+uniform samplerCube environmentMap;
+
+in vec3 fragDirection;
+out vec4 outputColor;
+
+// Cosine weighted integral of radiance over hemisphere around normal,
+// diffuse lighting is irradiance times albedo
+void main() {
+    vec3 N = normalize(fragDirection);
+    vec3 up = abs(N.y) < 0.999 ? vec3(0.0, 1.0, 0.0) : vec3(0.0, 0.0, 1.0);
+    vec3 right = normalize(cross(up, N));
+    up = cross(N, right);
+
+    // Lower mip of environment avoids missing small bright spots
+    const float delta = 0.025;
+    vec3 sum = vec3(0.0);
+    float count = 0.0;
+    for (float phi = 0.0; phi < 2.0 * PI; phi += delta) {
+        for (float theta = 0.0; theta < 0.5 * PI; theta += delta) {
+            vec3 t = vec3(sin(theta) * cos(phi), sin(theta) * sin(phi), cos(theta));
+            vec3 direction = t.x * right + t.y * up + t.z * N;
+            sum += textureLod(environmentMap, direction, 2.0).rgb * cos(theta) * sin(theta);
+            count += 1.0;
+        }
+    }
+    outputColor = vec4(PI * sum / count, 1.0);
+}
@@ -0,0 +1,33 @@
+uniform samplerCube environmentMap;
+uniform float roughness;
+uniform float environmentSize;
+
+in vec3 fragDirection;
+out vec4 outputColor;
+
+// Environment convolved with GGX lobe of roughness, assuming view along
+// normal. Samples are read from mip matching their solid angle, which
+// avoids bright dots.
+void main() {
+    vec3 N = normalize(fragDirection);
+    vec3 V = N;
+    const uint sampleCount = 1024u;
+    float texelSolidAngle = 4.0 * PI / (6.0 * environmentSize * environmentSize);
+    vec3 sum = vec3(0.0);
+    float weight = 0.0;
+    for (uint i = 0u; i < sampleCount; i++) {
+        vec3 H = importanceSampleGGX(hammersley(i, sampleCount), N, roughness);
+        vec3 L = normalize(2.0 * dot(V, H) * H - V);
+        float NdotL = dot(N, L);
+        if (NdotL <= 0.0) {
+            continue;
+        }
+        float NdotH = max(dot(N, H), 0.0);
+        float pdf = distributionGGX(NdotH, roughness) * 0.25 + 0.0001;
+        float sampleSolidAngle = 1.0 / (float(sampleCount) * pdf);
+        float lod = roughness == 0.0 ? 0.0 : 0.5 * log2(sampleSolidAngle / texelSolidAngle);
+        sum += textureLod(environmentMap, L, lod).rgb * NdotL;
+        weight += NdotL;
+    }
+    outputColor = vec4(sum / max(weight, 0.0001), 1.0);
+}
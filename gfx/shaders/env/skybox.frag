@@ -0,0 +1,16 @@
+uniform samplerCube environmentMap;
+uniform float lod;
+uniform float intensity;
+
+in vec3 fragDirection;
+layout(location = 0) out vec4 outputColor;
+
+// Sky has neither normal nor indirect light for screen-space effects
+layout(location = 1) out vec4 outputNormal;
+layout(location = 2) out vec4 outputIndirect;
+
+void main() {
+    outputColor = vec4(textureLod(environmentMap, fragDirection, lod).rgb * intensity, 1.0);
+    outputNormal = vec4(0.0);
+    outputIndirect = vec4(0.0);
+}
@@ -0,0 +1,147 @@
+#define PI 3.14159265359
+
+#define LIGHT_DIRECTIONAL 0
+#define LIGHT_POINT 1
+#define LIGHT_SPOT 2
+
+// Must match layout of gpuLight
+struct Light {
+    vec3 position;
+    int type;
+    vec3 direction;
+    float range;
+    vec3 color;
+    float intensity;
+    float cosInner;
+    float cosOuter;
+    int shadowLayer;
+    float shadowBias;
+    float shadowNormalBias;
+    float padding0;
+    float padding1;
+    float padding2;
+};
+
+#ifdef LIGHTS_IN_STORAGE
+layout(std430, binding = LIGHT_BINDING) readonly buffer Lights {
+#else
+layout(std140, binding = LIGHT_BINDING) uniform Lights {
+#endif
+    vec3 ambientLight;
+    int lightCount;
+    float environmentIntensity;
+    float environmentMaxLod;
+    vec2 reserved0;
+    vec4 reserved[3];
+    Light lights[MAX_LIGHTS];
+};
+
+// Must match layout of gpuShadows
+layout(std140, binding = SHADOW_BINDING) uniform Shadows {
+    mat4 shadowMatrices[MAX_SHADOW_LAYERS];
+    vec4 cascadeSplits;
+    int cascadeCount;
+};
+
+layout(binding = SHADOW_TEXTURE_UNIT) uniform sampler2DArrayShadow shadowMap;
+
+// Image based lighting, used when environment intensity is positive
+layout(binding = IRRADIANCE_TEXTURE_UNIT) uniform samplerCube irradianceMap;
+layout(binding = PREFILTERED_TEXTURE_UNIT) uniform samplerCube prefilteredMap;
+layout(binding = BRDF_TEXTURE_UNIT) uniform sampler2D brdfMap;
+
+uniform vec3 cameraPosition;
+
+#ifdef DEFERRED_LIGHTING
+// Reconstructed from G-buffer by lighting pass
+float fragViewDepth;
+vec3 fragPosition;
+vec3 fragNormal;
+vec2 fragTexCoord;
+vec4 fragTangent;
+#else
+in float fragViewDepth;
+in vec3 fragPosition;
+in vec3 fragNormal;
+in vec2 fragTexCoord;
+in vec4 fragTangent;
+#endif
+
+// Radiance arriving at position from light, L is set to direction towards light
+vec3 lightRadiance(Light light, vec3 position, out vec3 L) {
+    if (light.type == LIGHT_DIRECTIONAL) {
+        L = -light.direction;
+        return light.color * light.intensity;
+    }
+
+    vec3 toLight = light.position - position;
+    float dist = length(toLight);
+    L = toLight / dist;
+
+    // Inverse square falloff, smoothly windowed to zero at range
+    float attenuation = 1.0 / max(dist * dist, 0.0001);
+    if (light.range > 0.0) {
+        float ratio = dist / light.range;
+        float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+        attenuation *= window * window;
+    }
+    if (light.type == LIGHT_SPOT) {
+        attenuation *= smoothstep(light.cosOuter, light.cosInner, dot(-L, light.direction));
+    }
+    return light.color * light.intensity * attenuation;
+}
+
+// Normal of surface, optionally perturbed by tangent space normal
+vec3 surfaceNormal(bool useMap, vec3 mapped, float scale) {
+    vec3 N = normalize(fragNormal);
+    if (!gl_FrontFacing) {
+        N = -N;
+    }
+    if (useMap && dot(fragTangent.xyz, fragTangent.xyz) > 0.0001) {
+        vec3 T = normalize(fragTangent.xyz - N * dot(N, fragTangent.xyz));
+        vec3 B = cross(N, T) * fragTangent.w;
+        vec3 n = mapped * 2.0 - 1.0;
+        n.xy *= scale;
+        N = normalize(mat3(T, B, N) * n);
+    }
+    return N;
+}
+
+// Fraction of light reaching surface, filtered by 3x3 PCF
+float shadowFactor(Light light, vec3 N, vec3 L) {
+    if (light.shadowLayer < 0) {
+        return 1.0;
+    }
+
+    // Directional lights use cascade covering depth of fragment
+    int layer = light.shadowLayer;
+    if (light.type == LIGHT_DIRECTIONAL) {
+        int cascade = 0;
+        while (cascade < cascadeCount && fragViewDepth > cascadeSplits[cascade]) {
+            cascade++;
+        }
+        if (cascade == cascadeCount) {
+            return 1.0;
+        }
+        layer += cascade;
+    }
+
+    // Offset along normal more at grazing angles, where acne appears
+    float cosTheta = clamp(dot(N, L), 0.0, 1.0);
+    vec3 position = fragPosition + N * light.shadowNormalBias * (1.0 - cosTheta);
+    vec4 p = shadowMatrices[layer] * vec4(position, 1.0);
+    p.xyz = p.xyz / p.w * 0.5 + 0.5;
+    if (p.z >= 1.0) {
+        return 1.0;
+    }
+
+    vec2 texel = 1.0 / vec2(textureSize(shadowMap, 0).xy);
+    float depth = p.z - light.shadowBias;
+    float sum = 0.0;
+    for (int x = -1; x <= 1; x++) {
+        for (int y = -1; y <= 1; y++) {
+            sum += texture(shadowMap, vec4(p.xy + vec2(x, y) * texel, float(layer), depth));
+        }
+    }
+    return sum / 9.0;
+}
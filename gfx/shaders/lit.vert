@@ -0,0 +1,25 @@
+uniform mat4 projection;
+uniform mat4 camera;
+uniform mat4 model;
+
+in vec3 vert;
+in vec3 vertNormal;
+in vec2 vertTexCoord;
+in vec4 vertTangent;
+
+out float fragViewDepth;
+out vec3 fragPosition;
+out vec3 fragNormal;
+out vec2 fragTexCoord;
+out vec4 fragTangent;
+
+void main() {
+    vec4 worldPosition = model * vec4(vert, 1);
+    fragPosition = worldPosition.xyz;
+    fragNormal = transpose(inverse(mat3(model))) * vertNormal;
+    fragTangent = vec4(mat3(model) * vertTangent.xyz, vertTangent.w);
+    fragTexCoord = vertTexCoord;
+    vec4 viewPosition = camera * worldPosition;
+    fragViewDepth = -viewPosition.z;
+    gl_Position = projection * viewPosition;
+}
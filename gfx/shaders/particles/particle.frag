@@ -0,0 +1,40 @@
+uniform sampler2D sprite;
+uniform bool useSprite;
+uniform sampler2D depthMap;
+uniform bool softParticles;
+uniform float softness;
+uniform mat4 inverseProjection;
+
+in vec2 fragCorner;
+in vec4 fragColor;
+in float fragViewDepth;
+
+layout(location = 0) out vec4 outputColor;
+
+// Alpha 0 keeps normals and indirect light of scene for screen-space
+// effects
+layout(location = 1) out vec4 outputNormal;
+layout(location = 2) out vec4 outputIndirect;
+
+float viewDepth(float depth) {
+    vec4 p = inverseProjection * vec4(0.0, 0.0, depth * 2.0 - 1.0, 1.0);
+    return -p.z / p.w;
+}
+
+void main() {
+    vec4 color = fragColor;
+    if (useSprite) {
+        color *= texture(sprite, fragCorner * 0.5 + 0.5);
+    } else {
+        color.a *= 1.0 - smoothstep(0.5, 1.0, length(fragCorner));
+    }
+
+    // Particles fade out where they get close to surfaces of scene
+    if (softParticles) {
+        float depth = texelFetch(depthMap, ivec2(gl_FragCoord.xy), 0).r;
+        color.a *= clamp((viewDepth(depth) - fragViewDepth) / softness, 0.0, 1.0);
+    }
+    outputColor = color;
+    outputNormal = vec4(0.0);
+    outputIndirect = vec4(0.0);
+}
@@ -0,0 +1,37 @@
+// Index of particle in sorted order, used when sorted is set
+layout(location = 0) in uint sortedIndex;
+
+uniform samplerBuffer particles; // position and age, velocity and lifetime
+uniform sampler2D curves;        // color over lifetime, size over lifetime
+uniform bool sorted;
+uniform int capacity;
+uniform mat4 projection;
+uniform mat4 camera;
+
+out vec2 fragCorner;
+out vec4 fragColor;
+out float fragViewDepth;
+
+// Quad facing camera is drawn for every instance, dead particles collapse
+void main() {
+    int index = sorted ? int(sortedIndex) : gl_InstanceID;
+    if (index >= capacity) {
+        gl_Position = vec4(0.0);
+        return;
+    }
+    vec4 positionAge = texelFetch(particles, index * 2);
+    vec4 velocityLifetime = texelFetch(particles, index * 2 + 1);
+    if (positionAge.w >= velocityLifetime.w) {
+        gl_Position = vec4(0.0);
+        return;
+    }
+
+    float t = positionAge.w / velocityLifetime.w;
+    float size = texture(curves, vec2(t, 0.75)).r;
+    fragColor = texture(curves, vec2(t, 0.25));
+    fragCorner = vec2(gl_VertexID & 1, gl_VertexID >> 1) * 2.0 - 1.0;
+    vec4 position = camera * vec4(positionAge.xyz, 1.0);
+    position.xy += fragCorner * size * 0.5;
+    fragViewDepth = -position.z;
+    gl_Position = projection * position;
+}
@@ -0,0 +1,101 @@
+#define EMIT_POINT 0
+#define EMIT_SPHERE 1
+#define EMIT_BOX 2
+
+// Must match layout of gpuEmitter
+struct Emitter {
+    vec3 position;
+    int shape;
+    vec3 extent;
+    float spread;
+    vec3 direction;
+    float speed;
+    float speedVariation;
+    float lifetime;
+    float lifetimeVariation;
+    int end; // end of slots spawned by emitter, counted from spawnStart
+};
+
+layout(std140) uniform Emitters {
+    Emitter emitters[MAX_EMITTERS];
+};
+
+uniform int emitterCount;
+uniform uint capacity;
+uniform uint spawnStart; // slot of the first particle spawned this frame
+uniform uint frame;
+uniform float deltaTime;
+uniform vec3 gravity;
+uniform float drag;
+
+uint hash(uint x) {
+    x ^= x >> 16;
+    x *= 0x7feb352du;
+    x ^= x >> 15;
+    x *= 0x846ca68bu;
+    x ^= x >> 16;
+    return x;
+}
+
+// Uniform random number in [0, 1), advancing state
+float random(inout uint state) {
+    state = hash(state);
+    return float(state >> 8) / 16777216.0;
+}
+
+vec3 randomDirection(inout uint state) {
+    float z = random(state) * 2.0 - 1.0;
+    float phi = random(state) * 2.0 * PI;
+    float r = sqrt(max(1.0 - z * z, 0.0));
+    return vec3(r * cos(phi), r * sin(phi), z);
+}
+
+// Random direction within cone of half angle around axis
+vec3 randomCone(vec3 axis, float angle, inout uint state) {
+    float z = mix(1.0, cos(angle), random(state));
+    float phi = random(state) * 2.0 * PI;
+    float r = sqrt(max(1.0 - z * z, 0.0));
+    vec3 up = abs(axis.y) < 0.999 ? vec3(0.0, 1.0, 0.0) : vec3(1.0, 0.0, 0.0);
+    vec3 T = normalize(cross(up, axis));
+    vec3 B = cross(axis, T);
+    return T * r * cos(phi) + B * r * sin(phi) + axis * z;
+}
+
+// Particle is respawned when its slot is among those spawned this frame,
+// otherwise it's advanced by deltaTime. Dead particles have age of at
+// least their lifetime.
+void simulate(uint index, inout vec4 positionAge, inout vec4 velocityLifetime) {
+    uint slot = (index + capacity - spawnStart) % capacity;
+    int total = emitterCount > 0 ? emitters[emitterCount - 1].end : 0;
+    if (slot < uint(total)) {
+        int e = 0;
+        while (slot >= uint(emitters[e].end)) {
+            e++;
+        }
+        Emitter emitter = emitters[e];
+        uint state = hash(index ^ hash(frame));
+
+        vec3 offset = vec3(0.0);
+        if (emitter.shape == EMIT_SPHERE) {
+            offset = randomDirection(state) * emitter.extent.x * pow(random(state), 1.0 / 3.0);
+        } else if (emitter.shape == EMIT_BOX) {
+            offset = (vec3(random(state), random(state), random(state)) * 2.0 - 1.0) * emitter.extent;
+        }
+        vec3 direction = vec3(0.0);
+        if (dot(emitter.direction, emitter.direction) > 0.0) {
+            direction = randomCone(normalize(emitter.direction), emitter.spread, state);
+        }
+        float speed = emitter.speed * (1.0 + emitter.speedVariation * (random(state) * 2.0 - 1.0));
+        float lifetime = emitter.lifetime * (1.0 + emitter.lifetimeVariation * (random(state) * 2.0 - 1.0));
+        positionAge = vec4(emitter.position + offset, 0.0);
+        velocityLifetime = vec4(direction * speed, max(lifetime, 0.001));
+        return;
+    }
+    if (positionAge.w >= velocityLifetime.w) {
+        return;
+    }
+
+    vec3 velocity = (velocityLifetime.xyz + gravity * deltaTime) * exp(-drag * deltaTime);
+    positionAge += vec4(velocity * deltaTime, deltaTime);
+    velocityLifetime.xyz = velocity;
+}
@@ -0,0 +1,12 @@
+// Particles are advanced from one buffer into another by transform feedback
+layout(location = 0) in vec4 positionAge;
+layout(location = 1) in vec4 velocityLifetime;
+
+out vec4 outPositionAge;
+out vec4 outVelocityLifetime;
+
+void main() {
+    outPositionAge = positionAge;
+    outVelocityLifetime = velocityLifetime;
+    simulate(uint(gl_VertexID), outPositionAge, outVelocityLifetime);
+}
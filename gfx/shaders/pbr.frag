@@ -0,0 +1,48 @@
+uniform vec4 baseColorFactor;
+uniform sampler2D baseColorMap;
+uniform bool useBaseColorMap;
+uniform float metallicFactor;
+uniform float roughnessFactor;
+uniform sampler2D metallicRoughnessMap;
+uniform bool useMetallicRoughnessMap;
+uniform sampler2D normalMap;
+uniform bool useNormalMap;
+uniform float normalScale;
+uniform sampler2D occlusionMap;
+uniform bool useOcclusionMap;
+uniform float occlusionStrength;
+uniform vec3 emissiveFactor;
+uniform sampler2D emissiveMap;
+uniform bool useEmissiveMap;
+
+void main() {
+    vec4 baseColor = baseColorFactor;
+    if (useBaseColorMap) {
+        baseColor *= texture(baseColorMap, fragTexCoord);
+    }
+    float metallic = metallicFactor;
+    float roughness = roughnessFactor;
+    if (useMetallicRoughnessMap) {
+        vec4 mr = texture(metallicRoughnessMap, fragTexCoord);
+        roughness *= mr.g;
+        metallic *= mr.b;
+    }
+
+    Surface s;
+    s.model = SHADING_PBR;
+    s.position = fragPosition;
+    s.N = surfaceNormal(useNormalMap, texture(normalMap, fragTexCoord).rgb, normalScale);
+    s.V = normalize(cameraPosition - fragPosition);
+    s.albedo = baseColor.rgb;
+    s.occlusion = 1.0;
+    if (useOcclusionMap) {
+        s.occlusion = mix(1.0, texture(occlusionMap, fragTexCoord).r, occlusionStrength);
+    }
+    s.metallic = metallic;
+    s.roughness = clamp(roughness, 0.04, 1.0);
+    s.emissive = emissiveFactor;
+    if (useEmissiveMap) {
+        s.emissive *= texture(emissiveMap, fragTexCoord).rgb;
+    }
+    outputSurface(s, baseColor.a);
+}
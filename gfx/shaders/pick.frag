@@ -0,0 +1,7 @@
+uniform uint objectID;
+
+out uint outputID;
+
+void main() {
+    outputID = objectID;
+}
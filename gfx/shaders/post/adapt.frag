@@ -0,0 +1,14 @@
+// Log luminance of scene with full chain of mipmaps
+uniform sampler2D luminanceMap;
+uniform sampler2D previousLuminance;
+uniform float adaptation;
+uniform float minLuminance;
+uniform float maxLuminance;
+
+// Average luminance moves towards the one of current frame gradually
+void main() {
+    float current = exp(textureLod(luminanceMap, vec2(0.5), 16.0).r);
+    current = clamp(current, minLuminance, maxLuminance);
+    float previous = texture(previousLuminance, vec2(0.5)).r;
+    outputColor = vec4(mix(previous, current, adaptation), 0.0, 0.0, 1.0);
+}
@@ -0,0 +1,7 @@
+uniform sampler2D bloomMap;
+uniform float intensity;
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    outputColor = vec4(color.rgb + texture(bloomMap, fragTexCoord).rgb * intensity, color.a);
+}
@@ -0,0 +1,40 @@
+// Soft threshold applied when downsampling scene: threshold, threshold -
+// knee, 2 * knee, 0.25 / knee
+uniform bool prefilter;
+uniform vec4 threshold;
+
+vec3 applyThreshold(vec3 color) {
+    float brightness = max(color.r, max(color.g, color.b));
+    float soft = clamp(brightness - threshold.y, 0.0, threshold.z);
+    soft = threshold.w * soft * soft;
+    return color * max(soft, brightness - threshold.x) / max(brightness, 0.0001);
+}
+
+// 13-tap downsample of Call of Duty: Advanced Warfare, which is resistant to
+// flickering of small bright features
+void main() {
+    vec2 uv = fragTexCoord;
+    vec2 t = texelSize;
+    vec3 a = texture(source, uv + t * vec2(-2.0, 2.0)).rgb;
+    vec3 b = texture(source, uv + t * vec2(0.0, 2.0)).rgb;
+    vec3 c = texture(source, uv + t * vec2(2.0, 2.0)).rgb;
+    vec3 d = texture(source, uv + t * vec2(-2.0, 0.0)).rgb;
+    vec3 e = texture(source, uv).rgb;
+    vec3 f = texture(source, uv + t * vec2(2.0, 0.0)).rgb;
+    vec3 g = texture(source, uv + t * vec2(-2.0, -2.0)).rgb;
+    vec3 h = texture(source, uv + t * vec2(0.0, -2.0)).rgb;
+    vec3 i = texture(source, uv + t * vec2(2.0, -2.0)).rgb;
+    vec3 j = texture(source, uv + t * vec2(-1.0, 1.0)).rgb;
+    vec3 k = texture(source, uv + t * vec2(1.0, 1.0)).rgb;
+    vec3 l = texture(source, uv + t * vec2(-1.0, -1.0)).rgb;
+    vec3 m = texture(source, uv + t * vec2(1.0, -1.0)).rgb;
+    vec3 color = e * 0.125 +
+        (a + c + g + i) * 0.03125 +
+        (b + d + f + h) * 0.0625 +
+        (j + k + l + m) * 0.125;
+    if (prefilter) {
+        // Infinite and huge values would spread over whole screen
+        color = applyThreshold(min(color, vec3(65000.0)));
+    }
+    outputColor = vec4(max(color, 0.0), 1.0);
+}
@@ -0,0 +1,18 @@
+// Radius of filter in texels of source
+uniform float filterRadius;
+
+// 3x3 tent filter, result is added to larger level by blending
+void main() {
+    vec2 uv = fragTexCoord;
+    vec2 r = texelSize * filterRadius;
+    vec3 sum = texture(source, uv).rgb * 4.0;
+    sum += (texture(source, uv + vec2(0.0, r.y)).rgb +
+        texture(source, uv + vec2(0.0, -r.y)).rgb +
+        texture(source, uv + vec2(r.x, 0.0)).rgb +
+        texture(source, uv + vec2(-r.x, 0.0)).rgb) * 2.0;
+    sum += texture(source, uv + r).rgb +
+        texture(source, uv - r).rgb +
+        texture(source, uv + vec2(r.x, -r.y)).rgb +
+        texture(source, uv + vec2(-r.x, r.y)).rgb;
+    outputColor = vec4(sum / 16.0, 1.0);
+}
@@ -0,0 +1,17 @@
+uniform float radius;
+
+// Separable gaussian blur, even steps blur horizontally and odd ones vertically
+void main() {
+    vec2 direction = (passStep % 2 == 0) ? vec2(texelSize.x, 0.0) : vec2(0.0, texelSize.y);
+    int taps = int(ceil(radius));
+    float sigma = max(radius / 3.0, 0.001);
+    vec4 sum = texture(source, fragTexCoord);
+    float total = 1.0;
+    for (int i = 1; i <= taps; i++) {
+        float w = exp(-float(i * i) / (2.0 * sigma * sigma));
+        sum += w * texture(source, fragTexCoord + direction * float(i));
+        sum += w * texture(source, fragTexCoord - direction * float(i));
+        total += 2.0 * w;
+    }
+    outputColor = sum / total;
+}
@@ -0,0 +1,3 @@
+void main() {
+    outputColor = texture(source, fragTexCoord);
+}
@@ -0,0 +1,100 @@
+uniform float edgeThreshold;
+uniform float edgeThresholdMin;
+uniform float subpixel;
+
+// Simplified FXAA 3.11, input should be in perceptual (gamma) space
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    float lumaM = luminance(color.rgb);
+    float lumaN = luminance(textureOffset(source, fragTexCoord, ivec2(0, 1)).rgb);
+    float lumaS = luminance(textureOffset(source, fragTexCoord, ivec2(0, -1)).rgb);
+    float lumaE = luminance(textureOffset(source, fragTexCoord, ivec2(1, 0)).rgb);
+    float lumaW = luminance(textureOffset(source, fragTexCoord, ivec2(-1, 0)).rgb);
+    float lumaMin = min(lumaM, min(min(lumaN, lumaS), min(lumaE, lumaW)));
+    float lumaMax = max(lumaM, max(max(lumaN, lumaS), max(lumaE, lumaW)));
+    float range = lumaMax - lumaMin;
+    if (range < max(edgeThresholdMin, lumaMax * edgeThreshold)) {
+        outputColor = color;
+        return;
+    }
+
+    float lumaNE = luminance(textureOffset(source, fragTexCoord, ivec2(1, 1)).rgb);
+    float lumaNW = luminance(textureOffset(source, fragTexCoord, ivec2(-1, 1)).rgb);
+    float lumaSE = luminance(textureOffset(source, fragTexCoord, ivec2(1, -1)).rgb);
+    float lumaSW = luminance(textureOffset(source, fragTexCoord, ivec2(-1, -1)).rgb);
+
+    // Edge is horizontal when luma changes more vertically
+    float horizontal = abs(lumaNW + lumaNE - 2.0 * lumaN) +
+        2.0 * abs(lumaW + lumaE - 2.0 * lumaM) +
+        abs(lumaSW + lumaSE - 2.0 * lumaS);
+    float vertical = abs(lumaNW + lumaSW - 2.0 * lumaW) +
+        2.0 * abs(lumaN + lumaS - 2.0 * lumaM) +
+        abs(lumaNE + lumaSE - 2.0 * lumaE);
+    bool isHorizontal = horizontal >= vertical;
+
+    // Step towards neighbor across edge with larger gradient
+    float luma1 = isHorizontal ? lumaS : lumaW;
+    float luma2 = isHorizontal ? lumaN : lumaE;
+    float gradient1 = abs(luma1 - lumaM);
+    float gradient2 = abs(luma2 - lumaM);
+    float stepLength = isHorizontal ? texelSize.y : texelSize.x;
+    float lumaLocal = 0.5 * (luma2 + lumaM);
+    if (gradient1 >= gradient2) {
+        stepLength = -stepLength;
+        lumaLocal = 0.5 * (luma1 + lumaM);
+    }
+    float gradientScaled = 0.25 * max(gradient1, gradient2);
+
+    // Walk along edge in both directions until its ends
+    vec2 uv = fragTexCoord;
+    vec2 offset = isHorizontal ? vec2(texelSize.x, 0.0) : vec2(0.0, texelSize.y);
+    if (isHorizontal) {
+        uv.y += stepLength * 0.5;
+    } else {
+        uv.x += stepLength * 0.5;
+    }
+    vec2 uv1 = uv - offset;
+    vec2 uv2 = uv + offset;
+    float end1 = luminance(texture(source, uv1).rgb) - lumaLocal;
+    float end2 = luminance(texture(source, uv2).rgb) - lumaLocal;
+    bool reached1 = abs(end1) >= gradientScaled;
+    bool reached2 = abs(end2) >= gradientScaled;
+    for (int i = 0; i < 12 && !(reached1 && reached2); i++) {
+        float speed = i < 4 ? 1.0 : 2.0;
+        if (!reached1) {
+            uv1 -= offset * speed;
+            end1 = luminance(texture(source, uv1).rgb) - lumaLocal;
+            reached1 = abs(end1) >= gradientScaled;
+        }
+        if (!reached2) {
+            uv2 += offset * speed;
+            end2 = luminance(texture(source, uv2).rgb) - lumaLocal;
+            reached2 = abs(end2) >= gradientScaled;
+        }
+    }
+
+    // Pixels closer to end of edge are shifted more, when luma at that end
+    // varies in the opposite way than at center
+    float distance1 = isHorizontal ? fragTexCoord.x - uv1.x : fragTexCoord.y - uv1.y;
+    float distance2 = isHorizontal ? uv2.x - fragTexCoord.x : uv2.y - fragTexCoord.y;
+    bool closer1 = distance1 < distance2;
+    float edgeLength = distance1 + distance2;
+    float pixelOffset = -min(distance1, distance2) / edgeLength + 0.5;
+    bool centerSmaller = lumaM - lumaLocal < 0.0;
+    bool correct = ((closer1 ? end1 : end2) < 0.0) != centerSmaller;
+    float finalOffset = correct ? pixelOffset : 0.0;
+
+    // Subpixel aliasing, e.g. single bright pixels
+    float average = (2.0 * (lumaN + lumaS + lumaE + lumaW) + lumaNE + lumaNW + lumaSE + lumaSW) / 12.0;
+    float subpixelRatio = clamp(abs(average - lumaM) / range, 0.0, 1.0);
+    float subpixelOffset = smoothstep(0.0, 1.0, subpixelRatio);
+    finalOffset = max(finalOffset, subpixelOffset * subpixelOffset * 0.75 * subpixel);
+
+    vec2 finalUV = fragTexCoord;
+    if (isHorizontal) {
+        finalUV.y += finalOffset * stepLength;
+    } else {
+        finalUV.x += finalOffset * stepLength;
+    }
+    outputColor = vec4(texture(source, finalUV).rgb, color.a);
+}
@@ -0,0 +1,6 @@
+uniform float gamma;
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    outputColor = vec4(pow(max(color.rgb, 0.0), vec3(1.0 / gamma)), color.a);
+}
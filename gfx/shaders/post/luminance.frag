@@ -0,0 +1,5 @@
+// Logarithm of luminance, whose average is taken from the smallest mipmap
+void main() {
+    float l = luminance(texture(source, fragTexCoord).rgb);
+    outputColor = vec4(log(max(l, 0.0001)), 0.0, 0.0, 1.0);
+}
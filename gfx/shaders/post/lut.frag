@@ -0,0 +1,12 @@
+uniform sampler3D lut;
+uniform float strength;
+
+// Colors are looked up in 3d texture, coordinates are shifted to centers of
+// texels at both ends
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    float size = float(textureSize(lut, 0).x);
+    vec3 uvw = clamp(color.rgb, 0.0, 1.0) * ((size - 1.0) / size) + 0.5 / size;
+    vec3 graded = texture(lut, uvw).rgb;
+    outputColor = vec4(mix(color.rgb, graded, strength), color.a);
+}
@@ -0,0 +1,13 @@
+// Output of previous pass, or scene for the first one
+uniform sampler2D source;
+uniform vec2 texelSize;
+
+// Index of step of pass repeated several times (see PostPass.Steps)
+uniform int passStep;
+
+in vec2 fragTexCoord;
+out vec4 outputColor;
+
+float luminance(vec3 color) {
+    return dot(color, vec3(0.2126, 0.7152, 0.0722));
+}
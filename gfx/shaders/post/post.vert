@@ -0,0 +1,8 @@
+out vec2 fragTexCoord;
+
+// Full-screen triangle generated from vertex index, no buffers are needed
+void main() {
+    vec2 p = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    fragTexCoord = p;
+    gl_Position = vec4(p * 2.0 - 1.0, 0.0, 1.0);
+}
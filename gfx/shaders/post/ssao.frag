@@ -0,0 +1,16 @@
+uniform sampler2D occlusionMap;
+uniform sampler2D indirectMap;
+uniform bool showOcclusion;
+
+// Indirect light of scene is darkened by occlusion, or occlusion alone is
+// shown
+void main() {
+    float ao = texture(occlusionMap, fragTexCoord).r;
+    if (showOcclusion) {
+        outputColor = vec4(vec3(ao), 1.0);
+        return;
+    }
+    vec4 color = texture(source, fragTexCoord);
+    vec3 indirect = texture(indirectMap, fragTexCoord).rgb;
+    outputColor = vec4(max(color.rgb - indirect * (1.0 - ao), 0.0), color.a);
+}
@@ -0,0 +1,25 @@
+#define BLUR_RADIUS 4
+
+uniform float sharpness;
+
+// Separable bilateral blur of occlusion, even steps blur horizontally and
+// odd ones vertically. Weights fall off with difference of view depth, so
+// occlusion doesn't leak over edges.
+void main() {
+    vec2 direction = (passStep % 2 == 0) ? vec2(texelSize.x, 0.0) : vec2(0.0, texelSize.y);
+    vec2 center = texture(source, fragTexCoord).rg;
+    float sigma = float(BLUR_RADIUS) / 2.0;
+    float sum = center.r;
+    float total = 1.0;
+    for (int i = -BLUR_RADIUS; i <= BLUR_RADIUS; i++) {
+        if (i == 0) {
+            continue;
+        }
+        vec2 s = texture(source, fragTexCoord + direction * float(i)).rg;
+        float dz = abs(s.g - center.g) / max(center.g, 0.0001);
+        float w = exp(-float(i * i) / (2.0 * sigma * sigma) - dz * sharpness);
+        sum += w * s.r;
+        total += w;
+    }
+    outputColor = vec4(sum / total, center.g, 0.0, 1.0);
+}
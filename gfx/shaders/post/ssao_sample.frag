@@ -0,0 +1,63 @@
+// Must match ssaoMaxSamples
+#define MAX_SAMPLES 64
+
+uniform sampler2D depthMap;
+uniform sampler2D normalMap; // world normals
+uniform bool useNormalMap;
+uniform sampler2D noiseMap;  // rotations of kernel, tiled over screen
+uniform vec2 noiseScale;
+uniform vec3 kernel[MAX_SAMPLES];
+uniform int sampleCount;
+uniform mat4 projection;
+uniform mat4 inverseProjection;
+uniform mat4 view;
+uniform float radius;
+uniform float bias;
+uniform float intensity;
+
+vec3 viewPosition(vec2 uv) {
+    float depth = texture(depthMap, uv).r;
+    vec4 p = inverseProjection * vec4(vec3(uv, depth) * 2.0 - 1.0, 1.0);
+    return p.xyz / p.w;
+}
+
+// Hemisphere around normal is sampled, samples behind depth of scene are
+// occluded. Output has occlusion in red and view depth in green, which
+// guides blur.
+void main() {
+    // Normals are reconstructed from depth when not available, derivatives
+    // are taken before any pixel returns
+    vec3 P = viewPosition(fragTexCoord);
+    vec3 reconstructed = cross(dFdx(P), dFdy(P));
+    if (texture(depthMap, fragTexCoord).r >= 1.0) {
+        outputColor = vec4(1.0, 0.0, 0.0, 1.0);
+        return;
+    }
+    vec3 N = vec3(0.0);
+    if (useNormalMap) {
+        N = mat3(view) * texture(normalMap, fragTexCoord).xyz;
+    }
+    if (dot(N, N) < 0.01) {
+        N = reconstructed;
+    }
+    N = normalize(N);
+
+    vec3 random = vec3(texture(noiseMap, fragTexCoord * noiseScale).xy, 0.0);
+    vec3 T = normalize(random - N * dot(random, N));
+    mat3 TBN = mat3(T, cross(N, T), N);
+
+    int count = clamp(sampleCount, 1, MAX_SAMPLES);
+    float occlusion = 0.0;
+    for (int i = 0; i < count; i++) {
+        vec3 S = P + TBN * kernel[i] * radius;
+        vec4 clip = projection * vec4(S, 1.0);
+        vec2 uv = clip.xy / clip.w * 0.5 + 0.5;
+        float sceneZ = viewPosition(uv).z;
+
+        // Occluders much further than radius don't count
+        float range = smoothstep(0.0, 1.0, radius / max(abs(P.z - sceneZ), 0.0001));
+        occlusion += (sceneZ >= S.z + bias ? 1.0 : 0.0) * range;
+    }
+    float ao = pow(max(1.0 - occlusion / float(count), 0.0), intensity);
+    outputColor = vec4(ao, -P.z, 0.0, 1.0);
+}
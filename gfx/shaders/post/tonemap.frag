@@ -0,0 +1,62 @@
+#define TONEMAP_REINHARD 0
+#define TONEMAP_ACES 1
+#define TONEMAP_FILMIC 2
+
+uniform int tonemapOperator;
+uniform float exposure;
+
+// Exposure adapts to average luminance of scene, keeping it at key value
+uniform bool autoExposure;
+uniform float exposureKey;
+uniform sampler2D adaptedLuminance;
+
+// Reinhard operator applied to luminance, which keeps hue of bright colors
+vec3 reinhard(vec3 color) {
+    return color / (1.0 + luminance(color));
+}
+
+// ACES filmic curve fitted by Stephen Hill, including conversions from and
+// to sRGB primaries
+vec3 aces(vec3 color) {
+    const mat3 inputMatrix = mat3(
+        0.59719, 0.07600, 0.02840,
+        0.35458, 0.90834, 0.13383,
+        0.04823, 0.01566, 0.83777);
+    const mat3 outputMatrix = mat3(
+        1.60475, -0.10208, -0.00327,
+        -0.53108, 1.10813, -0.07276,
+        -0.07367, -0.00605, 1.07602);
+    vec3 v = inputMatrix * color;
+    vec3 a = v * (v + 0.0245786) - 0.000090537;
+    vec3 b = v * (0.983729 * v + 0.4329510) + 0.238081;
+    return clamp(outputMatrix * (a / b), 0.0, 1.0);
+}
+
+// Filmic curve of John Hable (Uncharted 2)
+vec3 hable(vec3 x) {
+    const float A = 0.15, B = 0.50, C = 0.10, D = 0.20, E = 0.02, F = 0.30;
+    return (x * (A * x + C * B) + D * E) / (x * (A * x + B) + D * F) - E / F;
+}
+
+vec3 filmic(vec3 color) {
+    const float whitePoint = 11.2;
+    return hable(color * 2.0) / hable(vec3(whitePoint));
+}
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    float e = exposure;
+    if (autoExposure) {
+        e *= exposureKey / max(texture(adaptedLuminance, vec2(0.5)).r, 0.0001);
+    }
+    vec3 hdr = color.rgb * e;
+    vec3 ldr;
+    if (tonemapOperator == TONEMAP_ACES) {
+        ldr = aces(hdr);
+    } else if (tonemapOperator == TONEMAP_FILMIC) {
+        ldr = filmic(hdr);
+    } else {
+        ldr = reinhard(hdr);
+    }
+    outputColor = vec4(ldr, color.a);
+}
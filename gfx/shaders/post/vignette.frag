@@ -0,0 +1,10 @@
+uniform float intensity;
+uniform float radius;
+uniform float softness;
+
+void main() {
+    vec4 color = texture(source, fragTexCoord);
+    float d = length(fragTexCoord - 0.5) * 1.41421356;
+    float v = smoothstep(radius, radius - softness, d);
+    outputColor = vec4(color.rgb * mix(1.0, v, intensity), color.a);
+}
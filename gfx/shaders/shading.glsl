@@ -0,0 +1,135 @@
+#define SHADING_BLINN_PHONG 0
+#define SHADING_PBR 1
+
+// Properties of shaded point, filled by material or read from G-buffer
+struct Surface {
+    int model;
+    vec3 position;
+    vec3 N;
+    vec3 V;
+    vec3 albedo;    // diffuse color of Blinn-Phong, base color of PBR
+    float occlusion;
+    vec3 specular;  // specular color of Blinn-Phong
+    float shininess;
+    float metallic;
+    float roughness;
+    vec3 emissive;
+};
+
+#ifdef GBUFFER_PASS
+// Must match attachments of G-buffer of DeferredRenderer
+layout(location = 0) out vec4 gbufferAlbedo;   // albedo, occlusion
+layout(location = 1) out vec4 gbufferNormal;   // normal, shading model
+layout(location = 2) out vec4 gbufferMaterial; // specular, shininess or metallic, roughness
+layout(location = 3) out vec4 gbufferEmissive;
+
+void writeGBuffer(Surface s) {
+    gbufferAlbedo = vec4(s.albedo, s.occlusion);
+    gbufferNormal = vec4(s.N, float(s.model));
+    if (s.model == SHADING_PBR) {
+        gbufferMaterial = vec4(s.metallic, s.roughness, 0.0, 0.0);
+    } else {
+        gbufferMaterial = vec4(s.specular, s.shininess);
+    }
+    gbufferEmissive = vec4(s.emissive, 1.0);
+}
+#else
+layout(location = 0) out vec4 outputColor;
+
+// Normal for screen-space effects, alpha 0 keeps normals behind blended
+// surfaces
+layout(location = 1) out vec4 outputNormal;
+
+// Indirect light included in color, which ambient occlusion reduces
+layout(location = 2) out vec4 outputIndirect;
+#endif
+
+// GGX/Trowbridge-Reitz normal distribution
+float distributionGGX(float NdotH, float alpha) {
+    float a2 = alpha * alpha;
+    float d = NdotH * NdotH * (a2 - 1.0) + 1.0;
+    return a2 / (PI * d * d);
+}
+
+// Height-correlated Smith visibility, including denominator of specular BRDF
+float visibilitySmithGGX(float NdotL, float NdotV, float alpha) {
+    float a2 = alpha * alpha;
+    float ggxV = NdotL * sqrt(NdotV * NdotV * (1.0 - a2) + a2);
+    float ggxL = NdotV * sqrt(NdotL * NdotL * (1.0 - a2) + a2);
+    return 0.5 / max(ggxV + ggxL, 0.0001);
+}
+
+vec3 fresnelSchlick(float cosTheta, vec3 F0) {
+    return F0 + (1.0 - F0) * pow(1.0 - cosTheta, 5.0);
+}
+
+// Fresnel of environment, where rough surfaces reflect less at grazing angles
+vec3 fresnelSchlickRoughness(float cosTheta, vec3 F0, float roughness) {
+    return F0 + (max(vec3(1.0 - roughness), F0) - F0) * pow(1.0 - cosTheta, 5.0);
+}
+
+// Light of source reflected by surface towards viewer
+vec3 directLight(Surface s, Light light) {
+    vec3 L;
+    vec3 radiance = lightRadiance(light, s.position, L);
+    float NdotL = dot(s.N, L);
+    if (NdotL <= 0.0) {
+        return vec3(0.0);
+    }
+    radiance *= shadowFactor(light, s.N, L);
+    vec3 H = normalize(L + s.V);
+
+    if (s.model == SHADING_PBR) {
+        float alpha = s.roughness * s.roughness;
+        float NdotV = max(dot(s.N, s.V), 0.0001);
+        vec3 F0 = mix(vec3(0.04), s.albedo, s.metallic);
+        vec3 F = fresnelSchlick(max(dot(s.V, H), 0.0), F0);
+        vec3 specular = F * distributionGGX(max(dot(s.N, H), 0.0), alpha) * visibilitySmithGGX(NdotL, NdotV, alpha);
+        vec3 diffuse = (1.0 - F) * s.albedo * (1.0 - s.metallic) / PI;
+        return (diffuse + specular) * radiance * NdotL;
+    }
+    float specular = pow(max(dot(s.N, H), 0.0), s.shininess);
+    return radiance * NdotL * (s.albedo + s.specular * specular);
+}
+
+// Ambient and environment light leaving surface towards viewer
+vec3 indirectLight(Surface s) {
+    if (s.model != SHADING_PBR) {
+        vec3 color = ambientLight * s.albedo;
+        if (environmentIntensity > 0.0) {
+            color += texture(irradianceMap, s.N).rgb * s.albedo * environmentIntensity;
+        }
+        return color;
+    }
+
+    vec3 color = ambientLight * s.albedo * s.occlusion;
+    if (environmentIntensity > 0.0) {
+        float NdotV = max(dot(s.N, s.V), 0.0001);
+        vec3 F0 = mix(vec3(0.04), s.albedo, s.metallic);
+        vec3 F = fresnelSchlickRoughness(NdotV, F0, s.roughness);
+        vec3 irradiance = texture(irradianceMap, s.N).rgb;
+        vec3 R = reflect(-s.V, s.N);
+        vec3 prefiltered = textureLod(prefilteredMap, R, s.roughness * environmentMaxLod).rgb;
+        vec2 brdf = texture(brdfMap, vec2(NdotV, s.roughness)).rg;
+        vec3 specular = prefiltered * (F0 * brdf.x + brdf.y);
+        vec3 diffuseColor = s.albedo * (1.0 - s.metallic);
+        color += ((1.0 - F) * diffuseColor * irradiance + specular) * s.occlusion * environmentIntensity;
+    }
+    return color;
+}
+
+// Output surface, either into G-buffer or shaded by all lights
+void outputSurface(Surface s, float alpha) {
+#ifdef GBUFFER_PASS
+    writeGBuffer(s);
+#else
+    vec3 indirect = indirectLight(s);
+    vec3 color = indirect + s.emissive;
+    for (int i = 0; i < min(lightCount, MAX_LIGHTS); i++) {
+        color += directLight(s, lights[i]);
+    }
+    outputColor = vec4(color, alpha);
+    outputNormal = vec4(s.N, 0.0);
+    outputIndirect = vec4(indirect, alpha);
+#endif
+}
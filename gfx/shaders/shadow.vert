@@ -0,0 +1,9 @@
+// Position location matches VAO of mesh set up for its lit program
+layout(location = POSITION_LOCATION) in vec3 vert;
+
+uniform mat4 lightMatrix;
+uniform mat4 model;
+
+void main() {
+    gl_Position = lightMatrix * model * vec4(vert, 1);
+}
@@ -0,0 +1,447 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/shadow.vert
+var shadowVertexShader string
+
+//go:embed shaders/shadow.frag
+var shadowFragmentShader string
+
+const (
+	// Maximum number of layers of shadow maps, each cascade takes one
+	MaxShadowLayers = 16
+
+	// Maximum number of cascades of directional lights
+	MaxShadowCascades = 4
+
+	// Binding point of uniform buffer holding shadow matrices
+	ShadowBinding = 1
+
+	// Texture unit where shadow maps are bound, materials should use lower units
+	ShadowTextureUnit = 15
+)
+
+// Layout of shadow uniforms in buffer, must match Shadows block of lights.glsl
+type gpuShadows struct {
+	Matrices     [MaxShadowLayers]mgl32.Mat4
+	Splits       [MaxShadowCascades]float32
+	CascadeCount int32
+	_            [3]int32
+}
+
+// Program rendering depth of meshes whose position is at location
+type depthProgram struct {
+	id          uint32
+	lightMatrix int32
+	model       int32
+}
+
+// Owner of a layer of shadow maps
+type shadowLayer struct {
+	light   int
+	cascade int
+}
+
+// ShadowMaps renders depth of scene from view of directional and spot lights
+// into layers of a depth texture array, which are sampled by lit programs
+// with PCF filtering. Directional lights take one layer per cascade, which
+// are fitted to slices of camera frustum, spot lights take one layer.
+// Point lights don't cast shadows.
+type ShadowMaps struct {
+	Cascades       int     // number of cascades of directional lights
+	SplitLambda    float32 // blend between logarithmic (1) and uniform (0) cascade splits
+	MaxDistance    float32 // distance from camera covered by cascades
+	CasterDistance float32 // distance behind cascades where casters are still rendered
+
+	size     int32
+	layers   int
+	texture  uint32
+	fbo      uint32
+	views    []uint32
+	owners   []shadowLayer
+	programs map[int32]depthProgram
+	buffer   *Buffer
+}
+
+// NewShadowMaps creates layers of square shadow maps of given size.
+func NewShadowMaps(size int32, layers int) (*ShadowMaps, error) {
+	if layers <= 0 || layers > MaxShadowLayers {
+		return nil, fmt.Errorf("number of shadow layers must be in [1, %d]", MaxShadowLayers)
+	}
+	s := &ShadowMaps{
+		Cascades:       3,
+		SplitLambda:    0.75,
+		MaxDistance:    50,
+		CasterDistance: 50,
+		size:           size,
+		layers:         layers,
+		programs:       map[int32]depthProgram{},
+		buffer:         NewBuffer(gl.UNIFORM_BUFFER, BufferDynamic, 0),
+	}
+
+	// Immutable storage, so that layers could be viewed separately
+	gl.GenTextures(1, &s.texture)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, s.texture)
+	gl.TexStorage3D(gl.TEXTURE_2D_ARRAY, 1, gl.DEPTH_COMPONENT32F, size, size, int32(layers))
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	border := [4]float32{1, 1, 1, 1}
+	gl.TexParameterfv(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_BORDER_COLOR, &border[0])
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_COMPARE_MODE, gl.COMPARE_REF_TO_TEXTURE)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_COMPARE_FUNC, gl.LEQUAL)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0)
+
+	gl.GenFramebuffers(1, &s.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, s.fbo)
+	gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, s.texture, 0, 0)
+	gl.DrawBuffer(gl.NONE)
+	gl.ReadBuffer(gl.NONE)
+	err := checkFramebuffer(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	if err != nil {
+		s.Dispose()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Size returns width and height of each shadow map.
+func (s *ShadowMaps) Size() int32 {
+	return s.size
+}
+
+// Layers returns number of layers.
+func (s *ShadowMaps) Layers() int {
+	return s.layers
+}
+
+// Texture returns depth texture array holding shadow maps.
+func (s *ShadowMaps) Texture() uint32 {
+	return s.texture
+}
+
+// LayerOwner returns index of light and cascade rendered into layer by
+// last Render, light is -1 if layer is unused.
+func (s *ShadowMaps) LayerOwner(layer int) (int, int) {
+	if layer >= len(s.owners) {
+		return -1, 0
+	}
+	return s.owners[layer].light, s.owners[layer].cascade
+}
+
+// LayerTexture returns grayscale 2d view of layer for debugging (e.g. to be
+// shown by imgui.Image).
+func (s *ShadowMaps) LayerTexture(layer int) uint32 {
+	if s.views == nil {
+		s.views = make([]uint32, s.layers)
+		gl.GenTextures(int32(s.layers), &s.views[0])
+		for i, view := range s.views {
+			gl.TextureView(view, gl.TEXTURE_2D, s.texture, gl.DEPTH_COMPONENT32F, 0, 1, uint32(i), 1)
+			gl.BindTexture(gl.TEXTURE_2D, view)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_COMPARE_MODE, gl.NONE)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_G, gl.RED)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_B, gl.RED)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_SWIZZLE_A, gl.ONE)
+		}
+		gl.BindTexture(gl.TEXTURE_2D, 0)
+	}
+	return s.views[layer]
+}
+
+// Get first layer of each light, -1 for lights without shadows
+func (s *ShadowMaps) assignLayers(lights []Light) []int {
+	cascades := s.cascades()
+	layers := make([]int, len(lights))
+	next := 0
+	for i, light := range lights {
+		need := 0
+		if light.CastShadows {
+			switch light.Type {
+			case LightDirectional:
+				need = cascades
+			case LightSpot:
+				need = 1
+			}
+		}
+		layers[i] = -1
+		if need > 0 && next+need <= s.layers {
+			layers[i] = next
+			next += need
+		}
+	}
+	return layers
+}
+
+func (s *ShadowMaps) cascades() int {
+	if s.Cascades < 1 {
+		return 1
+	}
+	if s.Cascades > MaxShadowCascades {
+		return MaxShadowCascades
+	}
+	return s.Cascades
+}
+
+// Render draws visible meshes of scene into shadow maps of lights casting
+// shadows, then binds shadow maps for lit programs. Lighting must be
+// updated afterwards if its lights changed.
+func (s *ShadowMaps) Render(scene *Node, camera Camera, lighting *Lighting) {
+	lights := lighting.Lights
+	if len(lights) > lighting.maxLights {
+		lights = lights[:lighting.maxLights]
+	}
+	layers := s.assignLayers(lights)
+
+	var data gpuShadows
+	cascades := s.cascades()
+	splits := s.cascadeSplits(camera, cascades)
+	data.CascadeCount = int32(cascades)
+	copy(data.Splits[:], splits)
+	s.owners = make([]shadowLayer, s.layers)
+	for i := range s.owners {
+		s.owners[i].light = -1
+	}
+	for i, light := range lights {
+		if layers[i] < 0 {
+			continue
+		}
+		if light.Type == LightSpot {
+			data.Matrices[layers[i]] = s.spotMatrix(light)
+			s.owners[layers[i]] = shadowLayer{light: i}
+			continue
+		}
+		near := float32(0)
+		for c := 0; c < cascades; c++ {
+			data.Matrices[layers[i]+c] = s.cascadeMatrix(light, camera, near, splits[c])
+			s.owners[layers[i]+c] = shadowLayer{light: i, cascade: c}
+			near = splits[c]
+		}
+	}
+
+	// Render depth of each used layer
+	var lastFramebuffer int32
+	var lastViewport [4]int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastFramebuffer)
+	gl.GetIntegerv(gl.VIEWPORT, &lastViewport[0])
+	var lastDepthMask bool
+	gl.GetBooleanv(gl.DEPTH_WRITEMASK, &lastDepthMask)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, s.fbo)
+	gl.Viewport(0, 0, s.size, s.size)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthMask(true)
+	gl.Enable(gl.POLYGON_OFFSET_FILL)
+	for layer, owner := range s.owners {
+		if owner.light < 0 {
+			continue
+		}
+		gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, s.texture, 0, int32(layer))
+		gl.Clear(gl.DEPTH_BUFFER_BIT)
+		gl.PolygonOffset(lights[owner.light].ShadowSlopeBias, 1)
+		s.drawDepth(scene, data.Matrices[layer])
+	}
+	gl.Disable(gl.POLYGON_OFFSET_FILL)
+	gl.DepthMask(lastDepthMask)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(lastFramebuffer))
+	gl.Viewport(lastViewport[0], lastViewport[1], lastViewport[2], lastViewport[3])
+	forgetBindings()
+
+	s.buffer.Upload([]gpuShadows{data})
+	s.Bind()
+}
+
+// Bind binds shadow maps and their matrices for lit programs.
+func (s *ShadowMaps) Bind() {
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, ShadowBinding, s.buffer.ID())
+	gl.ActiveTexture(gl.TEXTURE0 + ShadowTextureUnit)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, s.texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// Draw depth of visible meshes of scene
+func (s *ShadowMaps) drawDepth(scene *Node, lightMatrix mgl32.Mat4) {
+	var current uint32
+	scene.Traverse(func(node *Node) bool {
+		if !node.Visible {
+			return false
+		}
+		if node.Mesh == nil {
+			return true
+		}
+		program, err := s.depthProgram(node.Mesh.attribLocation(AttribPosition))
+		if err != nil {
+			return true
+		}
+		if program.id != current {
+			current = program.id
+			gl.UseProgram(program.id)
+			gl.UniformMatrix4fv(program.lightMatrix, 1, false, &lightMatrix[0])
+		}
+		model := node.WorldMatrix()
+		gl.UniformMatrix4fv(program.model, 1, false, &model[0])
+		node.Mesh.Draw()
+		return true
+	})
+}
+
+// Get program matching location of position attribute of mesh, VAO
+// of mesh is set up for program of its material
+func (s *ShadowMaps) depthProgram(location int32) (depthProgram, error) {
+	if location < 0 {
+		return depthProgram{}, fmt.Errorf("mesh has no position")
+	}
+	if p, ok := s.programs[location]; ok {
+		return p, nil
+	}
+	header := fmt.Sprintf("#version 430 core\n#define POSITION_LOCATION %d\n", location)
+	id, err := LoadShaders([]Shader{
+		{Source: header + shadowVertexShader + "\x00", Type: gl.VERTEX_SHADER},
+		{Source: "#version 430 core\n" + shadowFragmentShader + "\x00", Type: gl.FRAGMENT_SHADER},
+	})
+	if err != nil {
+		return depthProgram{}, err
+	}
+	p := depthProgram{
+		id:          id,
+		lightMatrix: gl.GetUniformLocation(id, gl.Str("lightMatrix\x00")),
+		model:       gl.GetUniformLocation(id, gl.Str("model\x00")),
+	}
+	s.programs[location] = p
+	return p, nil
+}
+
+// Get view depths where cascades end, blending logarithmic and uniform splits
+func (s *ShadowMaps) cascadeSplits(camera Camera, cascades int) []float32 {
+	near, far := cameraDepthRange(camera)
+	if far > s.MaxDistance {
+		far = s.MaxDistance
+	}
+	// Logarithmic splits are undefined from zero or negative depth, as of
+	// orthographic camera, which needs only uniform ones
+	lambda := float64(s.SplitLambda)
+	if near <= 0 {
+		lambda = 0
+	}
+	splits := make([]float32, cascades)
+	for i := range splits {
+		p := float64(i+1) / float64(cascades)
+		uniform := float64(near) + float64(far-near)*p
+		if lambda == 0 {
+			splits[i] = float32(uniform)
+			continue
+		}
+		log := float64(near) * math.Pow(float64(far/near), p)
+		splits[i] = float32(lambda*log + (1-lambda)*uniform)
+	}
+	return splits
+}
+
+// Get light matrix of cascade covering view depths [near, far] of camera
+func (s *ShadowMaps) cascadeMatrix(light Light, camera Camera, near, far float32) mgl32.Mat4 {
+	corners := frustumSliceCorners(camera, near, far)
+
+	// Bounding sphere keeps size of cascade stable while camera rotates
+	var center mgl32.Vec3
+	for _, c := range corners {
+		center = center.Add(c)
+	}
+	center = center.Mul(1.0 / float32(len(corners)))
+	var radius float32
+	for _, c := range corners {
+		radius = float32(math.Max(float64(radius), float64(c.Sub(center).Len())))
+	}
+	radius = float32(math.Ceil(float64(radius)*16) / 16)
+
+	dir := light.Direction.Normalize()
+	eye := center.Sub(dir.Mul(radius + s.CasterDistance))
+	view := mgl32.LookAtV(eye, center, perpendicularUp(dir))
+	projection := mgl32.Ortho(-radius, radius, -radius, radius, 0, 2*radius+s.CasterDistance)
+
+	// Snap to texels, so that edges of shadows don't shimmer while camera moves
+	m := projection.Mul4(view)
+	origin := m.Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Vec2().Mul(float32(s.size) / 2)
+	rounded := mgl32.Vec2{float32(math.Round(float64(origin[0]))), float32(math.Round(float64(origin[1])))}
+	offset := rounded.Sub(origin).Mul(2 / float32(s.size))
+	projection[12] += offset[0]
+	projection[13] += offset[1]
+	return projection.Mul4(view)
+}
+
+// Get light matrix of spot light
+func (s *ShadowMaps) spotMatrix(light Light) mgl32.Mat4 {
+	far := light.Range
+	if far <= 0 {
+		far = s.MaxDistance
+	}
+	fov := float32(math.Min(float64(2*light.OuterCone), math.Pi*0.95))
+	dir := light.Direction.Normalize()
+	view := mgl32.LookAtV(light.Position, light.Position.Add(dir), perpendicularUp(dir))
+	return mgl32.Perspective(fov, 1, far*0.001, far).Mul4(view)
+}
+
+// Dispose cleans up the resources.
+func (s *ShadowMaps) Dispose() {
+	for _, p := range s.programs {
+		gl.DeleteProgram(p.id)
+	}
+	s.programs = map[int32]depthProgram{}
+	if len(s.views) > 0 {
+		gl.DeleteTextures(int32(len(s.views)), &s.views[0])
+	}
+	s.views = nil
+	if s.texture != 0 {
+		gl.DeleteTextures(1, &s.texture)
+	}
+	s.texture = 0
+	if s.fbo != 0 {
+		gl.DeleteFramebuffers(1, &s.fbo)
+	}
+	s.fbo = 0
+	s.buffer.Dispose()
+}
+
+// Get up vector which isn't parallel to dir
+func perpendicularUp(dir mgl32.Vec3) mgl32.Vec3 {
+	if math.Abs(float64(dir[1])) > 0.99 {
+		return mgl32.Vec3{0, 0, 1}
+	}
+	return mgl32.Vec3{0, 1, 0}
+}
+
+// Get view depths of near and far planes of camera
+func cameraDepthRange(camera Camera) (float32, float32) {
+	view := camera.View()
+	inv := camera.Projection().Mul4(view).Inv()
+	depth := func(z float32) float32 {
+		p := mgl32.TransformCoordinate(mgl32.Vec3{0, 0, z}, inv)
+		return -mgl32.TransformCoordinate(p, view)[2]
+	}
+	return depth(-1), depth(1)
+}
+
+// Get world space corners of slice of camera frustum between view depths
+func frustumSliceCorners(camera Camera, near, far float32) [8]mgl32.Vec3 {
+	view := camera.View()
+	inv := camera.Projection().Mul4(view).Inv()
+	nearDepth, farDepth := cameraDepthRange(camera)
+	var corners [8]mgl32.Vec3
+	for i := 0; i < 4; i++ {
+		x, y := float32(i&1)*2-1, float32(i>>1)*2-1
+		a := mgl32.TransformCoordinate(mgl32.Vec3{x, y, -1}, inv)
+		b := mgl32.TransformCoordinate(mgl32.Vec3{x, y, 1}, inv)
+
+		// View depth changes linearly along each edge of frustum
+		corners[i] = a.Add(b.Sub(a).Mul((near - nearDepth) / (farDepth - nearDepth)))
+		corners[i+4] = a.Add(b.Sub(a).Mul((far - nearDepth) / (farDepth - nearDepth)))
+	}
+	return corners
+}
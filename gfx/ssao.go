@@ -0,0 +1,215 @@
+package gfx
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+//go:embed shaders/post/ssao.frag
+var ssaoFragmentShader string
+
+//go:embed shaders/post/ssao_sample.frag
+var ssaoSampleFragmentShader string
+
+//go:embed shaders/post/ssao_blur.frag
+var ssaoBlurFragmentShader string
+
+const (
+	// Size of kernel of hemisphere samples, must match ssao_sample.frag
+	ssaoMaxSamples = 64
+
+	// Size of tiled texture rotating kernel
+	ssaoNoiseSize = 4
+)
+
+// SSAO is a screen-space ambient occlusion pass, darkening creases and
+// corners of scene. Hemisphere around each pixel is sampled by a kernel
+// randomly rotated by a tiled noise texture, the result is smoothed by a
+// bilateral blur preserving edges. Occlusion reduces only indirect light,
+// which lit shaders write besides color. Pass reads depth, normals and
+// indirect light of scene, which must be set by SetInputs every frame, and
+// should be the first one, working on linear colors. Its resources are
+// released with Pass.
+type SSAO struct {
+	Pass *PostPass
+
+	depth      uint32
+	normals    uint32
+	indirect   uint32
+	projection mgl32.Mat4
+	view       mgl32.Mat4
+
+	occlusion [2]*Framebuffer // occlusion and view depth
+	sample    *PostPass
+	blur      *PostPass
+	noise     uint32
+}
+
+// NewSSAO creates ambient occlusion pass. Its parameters are radius of
+// hemisphere in world units, depth bias against self-occlusion, intensity
+// as exponent of the result, number of samples, sharpness of blur at
+// edges, and showOcclusion for debug view of occlusion alone.
+func NewSSAO() (*SSAO, error) {
+	p, err := NewPostPass("SSAO", ssaoFragmentShader,
+		PostParam{Name: "radius", Min: 0.05, Max: 2},
+		PostParam{Name: "bias", Min: 0, Max: 0.1},
+		PostParam{Name: "intensity", Min: 0, Max: 4},
+		PostParam{Name: "sampleCount", Min: 4, Max: ssaoMaxSamples},
+		PostParam{Name: "blur"},
+		PostParam{Name: "blurSharpness", Min: 0, Max: 32},
+		PostParam{Name: "showOcclusion"})
+	if err != nil {
+		return nil, err
+	}
+	p.Material.SetParam("radius", float32(0.5))
+	p.Material.SetParam("bias", float32(0.025))
+	p.Material.SetParam("intensity", float32(1))
+	p.Material.SetParam("sampleCount", int32(16))
+	p.Material.SetParam("blur", true)
+	p.Material.SetParam("blurSharpness", float32(8))
+	p.Material.SetParam("showOcclusion", false)
+
+	s := &SSAO{Pass: p}
+	if s.sample, err = NewPostPass("SSAO Sample", ssaoSampleFragmentShader); err != nil {
+		p.Dispose()
+		return nil, err
+	}
+	if s.blur, err = NewPostPass("SSAO Blur", ssaoBlurFragmentShader); err != nil {
+		s.dispose()
+		p.Dispose()
+		return nil, err
+	}
+	p.prepare, p.dispose = s.prepare, s.dispose
+
+	// Kernel and noise are fixed, so the pattern doesn't flicker
+	random := rand.New(rand.NewSource(1))
+	kernel := ssaoKernel(random, ssaoMaxSamples)
+	gl.ProgramUniform3fv(s.sample.Material.Program,
+		gl.GetUniformLocation(s.sample.Material.Program, gl.Str("kernel\x00")),
+		int32(len(kernel)), &kernel[0][0])
+	s.noise = ssaoNoise(random, ssaoNoiseSize)
+	s.sample.Material.SetTexture("noiseMap", s.noise)
+	return s, nil
+}
+
+// SetInputs sets depth texture of scene, texture of its world normals (0
+// to reconstruct them from depth), texture of its indirect light and camera
+// it was rendered by.
+func (s *SSAO) SetInputs(depth, normals, indirect uint32, camera Camera) {
+	s.depth, s.normals, s.indirect = depth, normals, indirect
+	s.projection, s.view = camera.Projection(), camera.View()
+}
+
+// Render blurred occlusion of scene, which is read by SSAO pass
+func (s *SSAO) prepare(source uint32, width, height int32) error {
+	if s.depth == 0 || s.indirect == 0 {
+		return fmt.Errorf("depth or indirect light of scene isn't set")
+	}
+	for i, fb := range s.occlusion {
+		if fb != nil {
+			if err := fb.Resize(width, height); err != nil {
+				return err
+			}
+			continue
+		}
+		fb, err := NewFramebuffer(width, height, FramebufferSpec{Colors: []uint32{gl.RG16F}})
+		if err != nil {
+			return err
+		}
+		s.occlusion[i] = fb
+	}
+	params := s.Pass.Material
+
+	sample := s.sample.Material
+	for _, name := range []string{"radius", "bias", "intensity", "sampleCount"} {
+		sample.SetParam(name, params.Param(name))
+	}
+	sample.SetTexture("depthMap", s.depth)
+	sample.SetTexture("normalMap", s.normals)
+	sample.SetParam("useNormalMap", s.normals != 0)
+	sample.SetParam("noiseScale", mgl32.Vec2{float32(width) / ssaoNoiseSize, float32(height) / ssaoNoiseSize})
+	sample.SetParam("projection", s.projection)
+	sample.SetParam("inverseProjection", s.projection.Inv())
+	sample.SetParam("view", s.view)
+	s.occlusion[0].Bind()
+	sample.Use()
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+
+	// Blur goes there and back between the pair of images
+	if enabled, _ := params.Param("blur").(bool); enabled {
+		blur := s.blur.Material
+		blur.SetParam("sharpness", params.Param("blurSharpness"))
+		blur.SetParam(UniformPostTexelSize, mgl32.Vec2{1 / float32(width), 1 / float32(height)})
+		for i := 0; i < 2; i++ {
+			s.occlusion[1-i].Bind()
+			blur.SetTexture(UniformPostSource, s.occlusion[i].ColorTexture(0))
+			blur.SetParam(UniformPostStep, int32(i))
+			blur.Use()
+			gl.DrawArrays(gl.TRIANGLES, 0, 3)
+		}
+	}
+
+	params.SetTexture("occlusionMap", s.occlusion[0].ColorTexture(0))
+	params.SetTexture("indirectMap", s.indirect)
+	return nil
+}
+
+func (s *SSAO) dispose() {
+	for i, fb := range s.occlusion {
+		if fb != nil {
+			fb.Dispose()
+		}
+		s.occlusion[i] = nil
+	}
+	if s.sample != nil {
+		s.sample.Dispose()
+	}
+	if s.blur != nil {
+		s.blur.Dispose()
+	}
+	if s.noise != 0 {
+		gl.DeleteTextures(1, &s.noise)
+	}
+	s.noise = 0
+}
+
+// Get samples inside hemisphere around +Z, denser near its center
+func ssaoKernel(random *rand.Rand, count int) []mgl32.Vec3 {
+	kernel := make([]mgl32.Vec3, count)
+	for i := range kernel {
+		var v mgl32.Vec3
+		for {
+			v = mgl32.Vec3{random.Float32()*2 - 1, random.Float32()*2 - 1, random.Float32()}
+			if l := v.Len(); l > 0.0001 && l <= 1 {
+				break
+			}
+		}
+		t := float32(i) / float32(count)
+		kernel[i] = v.Normalize().Mul(random.Float32() * (0.1 + 0.9*t*t))
+	}
+	return kernel
+}
+
+// Create texture of size of random rotations around Z, repeated over screen
+func ssaoNoise(random *rand.Rand, size int) uint32 {
+	data := make([]float32, 0, size*size*2)
+	for i := 0; i < size*size; i++ {
+		angle := random.Float64() * 2 * math.Pi
+		data = append(data, float32(math.Cos(angle)), float32(math.Sin(angle)))
+	}
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RG16F, int32(size), int32(size), 0, gl.RG, gl.FLOAT, gl.Ptr(data))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return texture
+}
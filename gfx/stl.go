@@ -0,0 +1,220 @@
+package gfx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// LoadStl loads mesh from STL file on disk. Vertices aren't shared between
+// triangles (use Weld to merge them), normals are per face.
+func LoadStl(file string) (*MeshData, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found on disk: %v", file, err)
+	}
+	return parseStl(data, file)
+}
+
+// LoadStlFS loads mesh from STL file in filesystem fsys.
+func LoadStlFS(fsys fs.FS, name string) (*MeshData, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("model %q not found in filesystem: %v", name, err)
+	}
+	return parseStl(data, name)
+}
+
+// ReadStl parses mesh in ascii or binary STL format from r.
+func ReadStl(r io.Reader) (*MeshData, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseStl(data, "<stl>")
+}
+
+// WriteStl writes triangles of mesh in binary or ascii STL format to w,
+// facet normals are computed from vertex positions.
+func WriteStl(w io.Writer, d *MeshData, binaryFormat bool) error {
+	if err := d.checkTriangles(); err != nil {
+		return err
+	}
+	indices := d.triangleIndices()
+	bw := bufio.NewWriter(w)
+	if binaryFormat {
+		var header [80]byte
+		copy(header[:], "generated by glapp")
+		bw.Write(header[:])
+		binary.Write(bw, binary.LittleEndian, uint32(len(indices)/3))
+	} else {
+		fmt.Fprintln(bw, "solid glapp")
+	}
+
+	format := func(v mgl32.Vec3) string {
+		return fmt.Sprintf("%s %s %s",
+			strconv.FormatFloat(float64(v[0]), 'e', -1, 32),
+			strconv.FormatFloat(float64(v[1]), 'e', -1, 32),
+			strconv.FormatFloat(float64(v[2]), 'e', -1, 32))
+	}
+	for t := 0; t+2 < len(indices); t += 3 {
+		a, b, c := d.Positions[indices[t]], d.Positions[indices[t+1]], d.Positions[indices[t+2]]
+		n := b.Sub(a).Cross(c.Sub(a))
+		if n.Len() > 0 {
+			n = n.Normalize()
+		}
+		if binaryFormat {
+			var facet [50]byte
+			for i, v := range [4]mgl32.Vec3{n, a, b, c} {
+				for j := 0; j < 3; j++ {
+					binary.LittleEndian.PutUint32(facet[i*12+j*4:], math.Float32bits(v[j]))
+				}
+			}
+			bw.Write(facet[:])
+			continue
+		}
+		fmt.Fprintf(bw, "facet normal %s\n outer loop\n", format(n))
+		for _, v := range [3]mgl32.Vec3{a, b, c} {
+			fmt.Fprintf(bw, "  vertex %s\n", format(v))
+		}
+		fmt.Fprintf(bw, " endloop\nendfacet\n")
+	}
+	if !binaryFormat {
+		fmt.Fprintln(bw, "endsolid glapp")
+	}
+	return bw.Flush()
+}
+
+func parseStl(data []byte, name string) (*MeshData, error) {
+	// Binary files may start with "solid" as well, trust size of data first
+	if len(data) >= 84 {
+		count := int(binary.LittleEndian.Uint32(data[80:]))
+		if len(data) == 84+count*50 {
+			return parseBinaryStl(data[84:], count), nil
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid")) {
+		return parseAsciiStl(data, name)
+	}
+	return nil, fmt.Errorf("%s: not a STL file", name)
+}
+
+func parseBinaryStl(data []byte, count int) *MeshData {
+	d := &MeshData{
+		Positions: make([]mgl32.Vec3, 0, count*3),
+		Normals:   make([]mgl32.Vec3, 0, count*3),
+	}
+	vec := func(b []byte) mgl32.Vec3 {
+		return mgl32.Vec3{
+			math.Float32frombits(binary.LittleEndian.Uint32(b)),
+			math.Float32frombits(binary.LittleEndian.Uint32(b[4:])),
+			math.Float32frombits(binary.LittleEndian.Uint32(b[8:])),
+		}
+	}
+	for i := 0; i < count; i++ {
+		facet := data[i*50:]
+		d.appendFacet(vec(facet), vec(facet[12:]), vec(facet[24:]), vec(facet[36:]))
+	}
+	return d
+}
+
+func parseAsciiStl(data []byte, name string) (*MeshData, error) {
+	d := &MeshData{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%s:%d: %s", name, line, fmt.Sprintf(format, args...))
+	}
+	parseVec := func(fields []string) (mgl32.Vec3, error) {
+		var v mgl32.Vec3
+		if len(fields) != 3 {
+			return v, errorf("expect 3 numbers, got %d", len(fields))
+		}
+		for i, f := range fields {
+			x, err := strconv.ParseFloat(f, 32)
+			if err != nil {
+				return v, errorf("invalid number %q", f)
+			}
+			v[i] = float32(x)
+		}
+		return v, nil
+	}
+
+	var (
+		normal   mgl32.Vec3
+		vertices []mgl32.Vec3
+		inFacet  bool
+	)
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		switch fields[0] {
+		case "solid", "endsolid", "outer", "endloop":
+		case "facet":
+			if inFacet {
+				return nil, errorf("nested facet")
+			}
+			if len(fields) < 2 || fields[1] != "normal" {
+				return nil, errorf("expect facet normal")
+			}
+			normal, err = parseVec(fields[2:])
+			vertices = vertices[:0]
+			inFacet = true
+		case "vertex":
+			if !inFacet {
+				return nil, errorf("vertex outside of facet")
+			}
+			var v mgl32.Vec3
+			if v, err = parseVec(fields[1:]); err == nil {
+				vertices = append(vertices, v)
+			}
+		case "endfacet":
+			if !inFacet {
+				return nil, errorf("endfacet without facet")
+			}
+			if len(vertices) != 3 {
+				return nil, errorf("facet needs 3 vertices, got %d", len(vertices))
+			}
+			d.appendFacet(normal, vertices[0], vertices[1], vertices[2])
+			inFacet = false
+		default:
+			return nil, errorf("unknown keyword %q", fields[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if inFacet {
+		return nil, errorf("unexpected end of file")
+	}
+	return d, nil
+}
+
+// Append triangle, normal is computed when it's missing in file
+func (d *MeshData) appendFacet(normal, a, b, c mgl32.Vec3) {
+	if normal.Len() < 1e-6 {
+		normal = b.Sub(a).Cross(c.Sub(a))
+	}
+	if normal.Len() > 0 {
+		normal = normal.Normalize()
+	}
+	d.Positions = append(d.Positions, a, b, c)
+	d.Normals = append(d.Normals, normal, normal, normal)
+}
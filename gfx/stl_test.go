@@ -0,0 +1,133 @@
+package gfx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestReadStl(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		positions []mgl32.Vec3
+		normal    mgl32.Vec3
+	}{
+		{
+			name: "facet with normal",
+			source: "solid test\nfacet normal 0 0 2\n outer loop\n  vertex 0 0 0\n  vertex 1 0 0\n  vertex 0 1 0\n" +
+				" endloop\nendfacet\nendsolid test\n",
+			positions: []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+			normal:    mgl32.Vec3{0, 0, 1},
+		},
+		{
+			name: "missing normal is computed",
+			source: "solid\nfacet normal 0 0 0\nouter loop\nvertex 0 0 0\nvertex 0 1 0\nvertex 1 0 0\n" +
+				"endloop\nendfacet\nendsolid\n",
+			positions: []mgl32.Vec3{{0, 0, 0}, {0, 1, 0}, {1, 0, 0}},
+			normal:    mgl32.Vec3{0, 0, -1},
+		},
+		{
+			name:   "empty solid",
+			source: "solid\nendsolid\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ReadStl(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Positions) != len(tt.positions) || len(d.Normals) != len(tt.positions) || len(d.Indices) != 0 {
+				t.Fatalf("got %d positions, %d normals and %d indices, want %d vertices",
+					len(d.Positions), len(d.Normals), len(d.Indices), len(tt.positions))
+			}
+			for i := range tt.positions {
+				if d.Positions[i] != tt.positions[i] || !d.Normals[i].ApproxEqual(tt.normal) {
+					t.Errorf("got vertex %v with normal %v, want %v with %v", d.Positions[i], d.Normals[i], tt.positions[i], tt.normal)
+				}
+			}
+		})
+	}
+}
+
+func TestReadStlErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{"empty", "", "not a STL file"},
+		{"binary size mismatch", string(make([]byte, 84)) + "\x01", "not a STL file"},
+		{"truncated", "solid\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\n", "unexpected end of file"},
+		{"two vertices", "solid\nfacet normal 0 0 1\nvertex 0 0 0\nvertex 1 0 0\nendfacet\n", "facet needs 3 vertices, got 2"},
+		{"vertex outside facet", "solid\nvertex 0 0 0\n", "vertex outside of facet"},
+		{"nested facet", "solid\nfacet normal 0 0 1\nfacet normal 0 0 1\n", "nested facet"},
+		{"missing normal keyword", "solid\nfacet 0 0 1\n", "expect facet normal"},
+		{"truncated vertex", "solid\nfacet normal 0 0 1\nvertex 0 0\n", "expect 3 numbers, got 2"},
+		{"invalid number", "solid\nfacet normal 0 0 1\nvertex 0 x 0\n", "invalid number"},
+		{"unknown keyword", "solid\nface\n", "unknown keyword"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadStl(strings.NewReader(tt.source))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWriteStl(t *testing.T) {
+	mesh := GenCube(1, 1)
+	for _, binaryFormat := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := WriteStl(&buf, mesh, binaryFormat); err != nil {
+			t.Fatal(err)
+		}
+		if binaryFormat && buf.Len() != 84+len(mesh.Indices)/3*50 {
+			t.Errorf("got %d bytes of binary STL", buf.Len())
+		}
+		d, err := ReadStl(&buf)
+		if err != nil {
+			t.Fatalf("binary %v: %v", binaryFormat, err)
+		}
+		if len(d.Positions) != len(mesh.Indices) {
+			t.Fatalf("binary %v: got %d vertices, want %d", binaryFormat, len(d.Positions), len(mesh.Indices))
+		}
+		for i, idx := range mesh.Indices {
+			if !d.Positions[i].ApproxEqual(mesh.Positions[idx]) {
+				t.Fatalf("binary %v: vertex %d is %v, want %v", binaryFormat, i, d.Positions[i], mesh.Positions[idx])
+			}
+			if !d.Normals[i].ApproxEqualThreshold(mesh.Normals[idx], 1e-5) {
+				t.Fatalf("binary %v: normal %d is %v, want %v", binaryFormat, i, d.Normals[i], mesh.Normals[idx])
+			}
+		}
+	}
+}
+
+func TestWriteStlErrors(t *testing.T) {
+	positions := []mgl32.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}
+	tests := []struct {
+		name string
+		mesh *MeshData
+		err  string
+	}{
+		{"partial triangle", &MeshData{Positions: positions, Indices: []uint32{0, 1}}, "isn't a multiple of 3"},
+		{"index out of range", &MeshData{Positions: positions, Indices: []uint32{0, 1, 3}}, "vertex index 3 out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteStl(&buf, tt.mesh, true)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("got %d bytes written", buf.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package gfx
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// LoadTexture loads texture from image file on disk.
+func LoadTexture(file string) (uint32, error) {
+	return loadTextureFile(file, LoadTextureFromImage)
+}
+
+// LoadTextureSRGB loads texture holding sRGB encoded colors (e.g. albedo)
+// from image file on disk, which is linearized by sampling.
+func LoadTextureSRGB(file string) (uint32, error) {
+	return loadTextureFile(file, LoadTextureFromImageSRGB)
+}
+
+func loadTextureFile(file string, upload func(img image.Image) (uint32, error)) (uint32, error) {
+	imgFile, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("texture %q not found on disk: %v", file, err)
+	}
+	defer imgFile.Close()
+
+	img, _, err := image.Decode(imgFile)
+	if err != nil {
+		return 0, fmt.Errorf("load texture %q failed: %v", file, err)
+	}
+	texture, err := upload(img)
+	if err != nil {
+		return 0, fmt.Errorf("load texture %q failed: %v", file, err)
+	}
+	return texture, nil
+}
+
+// LoadTextureFS loads texture from image file in filesystem fsys,
+// which makes it possible to ship assets using go:embed.
+func LoadTextureFS(fsys fs.FS, name string) (uint32, error) {
+	imgFile, err := fsys.Open(name)
+	if err != nil {
+		return 0, fmt.Errorf("texture %q not found in filesystem: %v", name, err)
+	}
+	defer imgFile.Close()
+
+	texture, err := LoadTextureFromReader(imgFile)
+	if err != nil {
+		return 0, fmt.Errorf("load texture %q failed: %v", name, err)
+	}
+	return texture, nil
+}
+
+// LoadTextureFromReader decodes image from r and uploads it as texture.
+// Decoders of wanted image formats must be registered by caller
+// (e.g. import _ "image/png").
+func LoadTextureFromReader(r io.Reader) (uint32, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return LoadTextureFromImage(img)
+}
+
+// LoadTextureFromImage uploads already decoded (or procedurally generated) image as texture.
+func LoadTextureFromImage(img image.Image) (uint32, error) {
+	return uploadImage(img, gl.RGBA)
+}
+
+// LoadTextureFromImageSRGB uploads image holding sRGB encoded colors as texture.
+func LoadTextureFromImageSRGB(img image.Image) (uint32, error) {
+	return uploadImage(img, gl.SRGB8_ALPHA8)
+}
+
+func uploadImage(img image.Image, internalFormat int32) (uint32, error) {
+	if img.Bounds().Empty() {
+		return 0, fmt.Errorf("empty image")
+	}
+
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Stride != rgba.Rect.Size().X*4 || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		if rgba.Stride != rgba.Rect.Size().X*4 {
+			return 0, fmt.Errorf("unsupported stride")
+		}
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		internalFormat,
+		int32(rgba.Rect.Size().X),
+		int32(rgba.Rect.Size().Y),
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(rgba.Pix))
+
+	return texture, nil
+}
@@ -0,0 +1,39 @@
+package gfx
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.5-core/gl"
+)
+
+// Get address and size in bytes of slice data
+func sliceData(data interface{}) (unsafe.Pointer, int, error) {
+	if data == nil {
+		return nil, 0, nil
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice {
+		return nil, 0, fmt.Errorf("data must be a slice, got %T", data)
+	}
+	if v.Len() == 0 {
+		return nil, 0, nil
+	}
+	return unsafe.Pointer(v.Index(0).UnsafeAddr()), v.Len() * int(v.Type().Elem().Size()), nil
+}
+
+// Get size in bytes of opengl data type, 0 if unsupported
+func glTypeSize(xtype uint32) int32 {
+	switch xtype {
+	case gl.BYTE, gl.UNSIGNED_BYTE:
+		return 1
+	case gl.SHORT, gl.UNSIGNED_SHORT, gl.HALF_FLOAT:
+		return 2
+	case gl.INT, gl.UNSIGNED_INT, gl.FLOAT:
+		return 4
+	case gl.DOUBLE:
+		return 8
+	}
+	return 0
+}
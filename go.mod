@@ -8,4 +8,5 @@ require (
 	github.com/inkyblackness/imgui-go/v4 v4.2.0
 	github.com/veandco/go-sdl2 v0.4.8
 	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -6,3 +6,9 @@ const (
 	mouseButtonTertiary  = 2
 	mouseButtonCount     = 3
 )
+
+// Initial sizes of streaming buffers, which grow when needed
+const (
+	uiVertexBufferSize = 3 << 18
+	uiIndexBufferSize  = 3 << 16
+)
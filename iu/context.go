@@ -3,6 +3,8 @@ package iu
 import (
 	_ "embed"
 
+	"glapp/gfx"
+
 	"github.com/go-gl/gl/v4.5-core/gl"
 	"github.com/inkyblackness/imgui-go/v4"
 	"github.com/veandco/go-sdl2/sdl"
@@ -31,8 +33,8 @@ type Context struct {
 	attribLocationPosition int32
 	attribLocationUV       int32
 	attribLocationColor    int32
-	vboHandle              uint32
-	elementsHandle         uint32
+	vertexBuffer           *gfx.Buffer
+	indexBuffer            *gfx.Buffer
 }
 
 // Initialize ui context.
@@ -175,6 +177,16 @@ func (ui *Context) updateKeyModifier() {
 	ui.imguiIO.KeyAlt(mapModifier(sdl.KMOD_LALT, sdl.SCANCODE_LALT, sdl.KMOD_RALT, sdl.SCANCODE_RALT))
 }
 
+// WantCaptureMouse reports whether ui is using mouse input, which shouldn't be dispatched to app.
+func (ui *Context) WantCaptureMouse() bool {
+	return ui.imguiIO.WantCaptureMouse()
+}
+
+// WantCaptureKeyboard reports whether ui is using keyboard input, which shouldn't be dispatched to app.
+func (ui *Context) WantCaptureKeyboard() bool {
+	return ui.imguiIO.WantCaptureKeyboard()
+}
+
 // Text returns the current clipboard text, if available.
 func (ui *Context) Text() (string, error) {
 	return sdl.GetClipboardText()
@@ -187,9 +199,23 @@ func (ui *Context) SetText(text string) {
 
 // Render translates the ImGui draw data to OpenGL commands.
 func (ui *Context) Render() {
+	fbWidth, fbHeight := ui.window.GLGetDrawableSize()
+	ui.render(fbWidth, fbHeight)
+}
+
+// RenderTo renders ui into framebuffer, stretched to its size.
+func (ui *Context) RenderTo(fb *gfx.Framebuffer) {
+	var lastFramebuffer int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &lastFramebuffer)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, fb.ID())
+	ui.render(fb.Size())
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, uint32(lastFramebuffer))
+}
+
+// Render draw data into bound framebuffer of size
+func (ui *Context) render(fbWidth, fbHeight int32) {
 	// Avoid rendering when minimized, scale coordinates for retina displays (screen coordinates != framebuffer coordinates)
 	displayWidth, displayHeight := ui.window.GetSize()
-	fbWidth, fbHeight := ui.window.GLGetDrawableSize()
 	if (fbWidth <= 0) || (fbHeight <= 0) {
 		return
 	}
@@ -239,6 +265,7 @@ func (ui *Context) Render() {
 	lastEnableCullFace := gl.IsEnabled(gl.CULL_FACE)
 	lastEnableDepthTest := gl.IsEnabled(gl.DEPTH_TEST)
 	lastEnableScissorTest := gl.IsEnabled(gl.SCISSOR_TEST)
+	lastEnableFramebufferSRGB := gl.IsEnabled(gl.FRAMEBUFFER_SRGB)
 
 	// Setup render state: alpha-blending enabled, no face culling, no depth testing, scissor enabled, polygon fill
 	gl.Enable(gl.BLEND)
@@ -247,6 +274,7 @@ func (ui *Context) Render() {
 	gl.Disable(gl.CULL_FACE)
 	gl.Disable(gl.DEPTH_TEST)
 	gl.Enable(gl.SCISSOR_TEST)
+	gl.Disable(gl.FRAMEBUFFER_SRGB) // ImGui colors are already in sRGB
 	gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
 
 	// Setup viewport, orthographic projection matrix
@@ -270,14 +298,10 @@ func (ui *Context) Render() {
 	var vaoHandle uint32
 	gl.GenVertexArrays(1, &vaoHandle)
 	gl.BindVertexArray(vaoHandle)
-	gl.BindBuffer(gl.ARRAY_BUFFER, ui.vboHandle)
 	gl.EnableVertexAttribArray(uint32(ui.attribLocationPosition))
 	gl.EnableVertexAttribArray(uint32(ui.attribLocationUV))
 	gl.EnableVertexAttribArray(uint32(ui.attribLocationColor))
 	vertexSize, vertexOffsetPos, vertexOffsetUv, vertexOffsetCol := imgui.VertexBufferLayout()
-	gl.VertexAttribPointerWithOffset(uint32(ui.attribLocationPosition), 2, gl.FLOAT, false, int32(vertexSize), uintptr(vertexOffsetPos))
-	gl.VertexAttribPointerWithOffset(uint32(ui.attribLocationUV), 2, gl.FLOAT, false, int32(vertexSize), uintptr(vertexOffsetUv))
-	gl.VertexAttribPointerWithOffset(uint32(ui.attribLocationColor), 4, gl.UNSIGNED_BYTE, true, int32(vertexSize), uintptr(vertexOffsetCol))
 	indexSize := imgui.IndexBufferLayout()
 	drawType := gl.UNSIGNED_SHORT
 	const bytesPerUint32 = 4
@@ -287,15 +311,17 @@ func (ui *Context) Render() {
 
 	// Draw
 	for _, list := range drawData.CommandLists() {
-		var indexBufferOffset uintptr
-
+		// Append data to streaming buffers, attributes are pointed to where vertices land
 		vertexBuffer, vertexBufferSize := list.VertexBuffer()
-		gl.BindBuffer(gl.ARRAY_BUFFER, ui.vboHandle)
-		gl.BufferData(gl.ARRAY_BUFFER, vertexBufferSize, vertexBuffer, gl.STREAM_DRAW)
-
+		vertexOffset := uintptr(ui.vertexBuffer.UploadPointer(vertexBuffer, vertexBufferSize))
 		indexBuffer, indexBufferSize := list.IndexBuffer()
-		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ui.elementsHandle)
-		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, indexBufferSize, indexBuffer, gl.STREAM_DRAW)
+		indexBufferOffset := uintptr(ui.indexBuffer.UploadPointer(indexBuffer, indexBufferSize))
+
+		ui.vertexBuffer.Bind()
+		ui.indexBuffer.Bind()
+		gl.VertexAttribPointerWithOffset(uint32(ui.attribLocationPosition), 2, gl.FLOAT, false, int32(vertexSize), vertexOffset+uintptr(vertexOffsetPos))
+		gl.VertexAttribPointerWithOffset(uint32(ui.attribLocationUV), 2, gl.FLOAT, false, int32(vertexSize), vertexOffset+uintptr(vertexOffsetUv))
+		gl.VertexAttribPointerWithOffset(uint32(ui.attribLocationColor), 4, gl.UNSIGNED_BYTE, true, int32(vertexSize), vertexOffset+uintptr(vertexOffsetCol))
 
 		for _, cmd := range list.Commands() {
 			if cmd.HasUserCallback() {
@@ -341,6 +367,11 @@ func (ui *Context) Render() {
 	} else {
 		gl.Disable(gl.SCISSOR_TEST)
 	}
+	if lastEnableFramebufferSRGB {
+		gl.Enable(gl.FRAMEBUFFER_SRGB)
+	} else {
+		gl.Disable(gl.FRAMEBUFFER_SRGB)
+	}
 	gl.PolygonMode(gl.FRONT_AND_BACK, uint32(lastPolygonMode[0]))
 	gl.Viewport(lastViewport[0], lastViewport[1], lastViewport[2], lastViewport[3])
 	gl.Scissor(lastScissorBox[0], lastScissorBox[1], lastScissorBox[2], lastScissorBox[3])
@@ -383,8 +414,8 @@ func (ui *Context) createDeviceObjects() {
 	ui.attribLocationUV = gl.GetAttribLocation(ui.shaderHandle, gl.Str("UV"+"\x00"))
 	ui.attribLocationColor = gl.GetAttribLocation(ui.shaderHandle, gl.Str("Color"+"\x00"))
 
-	gl.GenBuffers(1, &ui.vboHandle)
-	gl.GenBuffers(1, &ui.elementsHandle)
+	ui.vertexBuffer = gfx.NewBuffer(gl.ARRAY_BUFFER, gfx.BufferStreaming, uiVertexBufferSize)
+	ui.indexBuffer = gfx.NewBuffer(gl.ELEMENT_ARRAY_BUFFER, gfx.BufferStreaming, uiIndexBufferSize)
 
 	ui.createFontsTexture()
 
@@ -395,14 +426,14 @@ func (ui *Context) createDeviceObjects() {
 }
 
 func (ui *Context) invalidateDeviceObjects() {
-	if ui.vboHandle != 0 {
-		gl.DeleteBuffers(1, &ui.vboHandle)
+	if ui.vertexBuffer != nil {
+		ui.vertexBuffer.Dispose()
 	}
-	ui.vboHandle = 0
-	if ui.elementsHandle != 0 {
-		gl.DeleteBuffers(1, &ui.elementsHandle)
+	ui.vertexBuffer = nil
+	if ui.indexBuffer != nil {
+		ui.indexBuffer.Dispose()
 	}
-	ui.elementsHandle = 0
+	ui.indexBuffer = nil
 
 	if (ui.shaderHandle != 0) && (ui.vertHandle != 0) {
 		gl.DetachShader(ui.shaderHandle, ui.vertHandle)
@@ -0,0 +1,38 @@
+package iu
+
+import (
+	"fmt"
+
+	"glapp/gfx"
+
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// ShowShadowMaps shows window with layers of shadow maps and the lights
+// owning them. Window has a closing button when open isn't nil.
+func ShowShadowMaps(maps *gfx.ShadowMaps, open *bool) {
+	if !imgui.BeginV("Shadow Maps", open, 0) {
+		imgui.End()
+		return
+	}
+	const previewSize = 192
+	for layer := 0; layer < maps.Layers(); layer++ {
+		light, cascade := maps.LayerOwner(layer)
+		if light < 0 {
+			continue
+		}
+		imgui.BeginGroup()
+		imgui.Text(fmt.Sprintf("Layer %d: light %d, cascade %d", layer, light, cascade))
+
+		// Textures have origin at bottom left
+		imgui.ImageV(imgui.TextureID(maps.LayerTexture(layer)),
+			imgui.Vec2{X: previewSize, Y: previewSize},
+			imgui.Vec2{X: 0, Y: 1}, imgui.Vec2{X: 1, Y: 0},
+			imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}, imgui.Vec4{})
+		imgui.EndGroup()
+		if available := imgui.ContentRegionAvail(); available.X > previewSize*2 {
+			imgui.SameLine()
+		}
+	}
+	imgui.End()
+}
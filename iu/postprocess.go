@@ -0,0 +1,81 @@
+package iu
+
+import (
+	"glapp/gfx"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/inkyblackness/imgui-go/v4"
+)
+
+// ShowPostProcess shows window for toggling, reordering and tweaking
+// passes of post-processing. Window has a closing button when open isn't
+// nil.
+func ShowPostProcess(p *gfx.PostProcess, open *bool) {
+	if !imgui.BeginV("Post Processing", open, 0) {
+		imgui.End()
+		return
+	}
+	moveFrom, moveTo := -1, -1
+	for i, pass := range p.Passes {
+		imgui.PushIDInt(i)
+		imgui.Checkbox(pass.Name, &pass.Enabled)
+		imgui.SameLine()
+		if imgui.Button("Up") && i > 0 {
+			moveFrom, moveTo = i, i-1
+		}
+		imgui.SameLine()
+		if imgui.Button("Down") && i < len(p.Passes)-1 {
+			moveFrom, moveTo = i, i+1
+		}
+		if pass.Enabled && len(pass.Params) > 0 {
+			imgui.Indent()
+			for _, param := range pass.Params {
+				editPostParam(pass.Material, param)
+			}
+			imgui.Unindent()
+		}
+		imgui.PopID()
+	}
+	if moveFrom >= 0 {
+		p.Passes[moveFrom], p.Passes[moveTo] = p.Passes[moveTo], p.Passes[moveFrom]
+	}
+	imgui.End()
+}
+
+// Show widget matching type of parameter value
+func editPostParam(m *gfx.Material, param gfx.PostParam) {
+	switch v := m.Param(param.Name).(type) {
+	case float32:
+		if imgui.SliderFloat(param.Name, &v, param.Min, param.Max) {
+			m.SetParam(param.Name, v)
+		}
+	case int32:
+		if len(param.Options) == 0 {
+			if imgui.SliderInt(param.Name, &v, int32(param.Min), int32(param.Max)) {
+				m.SetParam(param.Name, v)
+			}
+			return
+		}
+		preview := ""
+		if v >= 0 && int(v) < len(param.Options) {
+			preview = param.Options[v]
+		}
+		if imgui.BeginCombo(param.Name, preview) {
+			for i, option := range param.Options {
+				if imgui.Selectable(option) {
+					m.SetParam(param.Name, int32(i))
+				}
+			}
+			imgui.EndCombo()
+		}
+	case bool:
+		if imgui.Checkbox(param.Name, &v) {
+			m.SetParam(param.Name, v)
+		}
+	case mgl32.Vec3:
+		color := [3]float32(v)
+		if imgui.ColorEdit3(param.Name, &color) {
+			m.SetParam(param.Name, mgl32.Vec3(color))
+		}
+	}
+}
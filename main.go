@@ -1,10 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	_ "image/png"
 	"log"
+	"os"
+	"path/filepath"
 
+	"glapp/gfx"
 	"glapp/iu"
 	"glapp/iu/demo"
 
@@ -15,6 +19,10 @@ import (
 )
 
 func main() {
+	deferred := flag.Bool("deferred", false, "render opaque meshes with deferred shading")
+	feedbackParticles := flag.Bool("tf-particles", false, "simulate particles by transform feedback instead of compute shaders")
+	flag.Parse()
+
 	const (
 		windowWidth  = 1280
 		windowHeight = 800
@@ -36,68 +44,231 @@ func main() {
 	version := gl.GoStr(gl.GetString(gl.VERSION))
 	log.Printf("OpenGL Version: %s", version)
 
-	// Configure the vertex and fragment shaders
-	program, err := LoadShaders(
-		[]Shader{
-			{
-				Source: vertexShader,
-				Type:   gl.VERTEX_SHADER,
-			},
-			{
-				Source: fragmentShader,
-				Type:   gl.FRAGMENT_SHADER,
-			},
-		})
-	if err != nil {
-		panic(err)
+	// Projection follows window size, which is updated by events
+	camera := gfx.NewPerspectiveCamera(mgl32.DegToRad(45.0), windowWidth, windowHeight, 0.1, 50.0)
+	camera.LookAt(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+	controller := gfx.NewOrbitController(&camera.CameraView, iuContext, mgl32.Vec3{0, 0, 0})
+
+	// Configure the lights
+	lighting := gfx.NewLighting(8)
+	defer lighting.Dispose()
+	if *deferred {
+		lighting.Path = gfx.RenderDeferred
+	}
+	lighting.Ambient = mgl32.Vec3{0.05, 0.05, 0.05}
+	lighting.Lights = []gfx.Light{
+		{
+			Type:             gfx.LightDirectional,
+			Direction:        mgl32.Vec3{-1, -2, -1},
+			Color:            mgl32.Vec3{1, 1, 1},
+			Intensity:        1,
+			CastShadows:      true,
+			ShadowBias:       0.0005,
+			ShadowSlopeBias:  2,
+			ShadowNormalBias: 0.02,
+		},
+		{
+			Type:      gfx.LightPoint,
+			Position:  mgl32.Vec3{2, 1, -2},
+			Color:     mgl32.Vec3{1, 0.5, 0.2},
+			Intensity: 5,
+			Range:     6,
+		},
 	}
-	gl.UseProgram(program)
 
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 10.0)
-	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
+	// Shadows of lights are rendered into layers of shadow maps
+	shadows, err := gfx.NewShadowMaps(2048, 8)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer shadows.Dispose()
+	lighting.Shadows = shadows
+
+	// Sky and image based lighting, when environment map is present
+	var environment *gfx.Environment
+	if _, err := os.Stat("environment.hdr"); err == nil {
+		cacheDir := ""
+		if dir, err := os.UserCacheDir(); err == nil {
+			cacheDir = filepath.Join(dir, "glapp")
+		}
+		environment, err = gfx.LoadEnvironment("environment.hdr", cacheDir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer environment.Dispose()
+		lighting.Environment = environment
+	}
+	lighting.Update()
 
-	camera := mgl32.LookAtV(mgl32.Vec3{3, 3, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
-	cameraUniform := gl.GetUniformLocation(program, gl.Str("camera\x00"))
-	gl.UniformMatrix4fv(cameraUniform, 1, false, &camera[0])
+	// Load the texture, which holds sRGB colors
+	texture, err := gfx.LoadTextureSRGB("square.png")
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	model := mgl32.Ident4()
-	modelUniform := gl.GetUniformLocation(program, gl.Str("model\x00"))
-	gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+	material, err := lighting.NewMaterial(gfx.ShadingBlinnPhong)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer material.Dispose()
+	material.SetTexture("diffuseMap", texture)
+	material.SetParam("useDiffuseMap", true)
 
-	textureUniform := gl.GetUniformLocation(program, gl.Str("tex\x00"))
-	gl.Uniform1i(textureUniform, 0)
+	// Configure the vertex data
+	cube, err := gfx.GenCube(2, 1).Upload(material.Program)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer cube.Dispose()
+	floor, err := gfx.GenPlane(10, 10, 1, 1).Upload(material.Program)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer floor.Dispose()
 
-	gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
+	// Compose the scene
+	scene := gfx.NewNode("scene")
+	cubeNode := gfx.NewMeshNode("cube", cube, material)
+	if err := scene.AddChild(cubeNode); err != nil {
+		log.Fatalln(err)
+	}
+	floorNode := gfx.NewMeshNode("floor", floor, material)
+	floorNode.SetPosition(mgl32.Vec3{-5, -1, 5})
+	if err := scene.AddChild(floorNode); err != nil {
+		log.Fatalln(err)
+	}
 
-	// Load the texture
-	texture, err := LoadTexture("square.png")
+	// Transparent meshes are always shaded forward
+	glass, err := lighting.NewTransparentMaterial(gfx.ShadingBlinnPhong)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer glass.Dispose()
+	glass.SetParam("diffuseColor", mgl32.Vec4{0.3, 0.6, 1, 0.4})
+	glassCube, err := gfx.GenCube(1, 1).Upload(glass.Program)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	defer glassCube.Dispose()
+	glassNode := gfx.NewMeshNode("glass", glassCube, glass)
+	glassNode.SetPosition(mgl32.Vec3{2, -0.5, 1})
+	if err := scene.AddChild(glassNode); err != nil {
+		log.Fatalln(err)
+	}
 
-	// Configure the vertex data
-	var vao uint32
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
+	// Only meshes inside view are drawn
+	bvh := gfx.NewBVH()
 
-	var vbo uint32
-	gl.GenBuffers(1, &vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(cubeVertices)*4, gl.Ptr(cubeVertices), gl.STATIC_DRAW)
+	// Sparks are simulated on gpu
+	simulation := gfx.SimulateAuto
+	if *feedbackParticles {
+		simulation = gfx.SimulateTransformFeedback
+	}
+	particles, err := gfx.NewParticleSystem(4096, simulation)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer particles.Dispose()
+	particles.Drag = 0.5
+	particles.Color = gfx.ColorCurve{
+		{Time: 0, Color: mgl32.Vec4{4, 2, 0.5, 1}},
+		{Time: 1, Color: mgl32.Vec4{1, 0.2, 0, 0}},
+	}
+	particles.Size = gfx.Curve{{Time: 0, Value: 0.05}, {Time: 1, Value: 0.02}}
+	particles.Emitters = append(particles.Emitters, &gfx.ParticleEmitter{
+		Position:          mgl32.Vec3{-2, -1, -2},
+		Direction:         mgl32.Vec3{0, 1, 0},
+		Spread:            0.4,
+		Speed:             4,
+		SpeedVariation:    0.3,
+		Rate:              300,
+		Lifetime:          1.5,
+		LifetimeVariation: 0.3,
+	})
+
+	// Helpers are drawn over the scene when enabled
+	debugDraw, err := gfx.NewDebugDraw()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer debugDraw.Dispose()
+
+	// Scene is rendered into framebuffer following window size, which is
+	// multisampled unless G-buffer is shaded into it
+	fbWidth, fbHeight := window.GLGetDrawableSize()
+	samples := int32(4)
+	if *deferred {
+		samples = 0
+	}
+	sceneTarget, err := gfx.NewFramebuffer(fbWidth, fbHeight, gfx.FramebufferSpec{
+		Colors:       []uint32{gl.RGBA16F, gl.RGBA16F, gl.R11F_G11F_B10F}, // color, normals, indirect light
+		Depth:        gl.DEPTH24_STENCIL8,
+		DepthTexture: true,
+		Samples:      samples,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer sceneTarget.Dispose()
+	var renderer *gfx.DeferredRenderer
+	if *deferred {
+		renderer, err = gfx.NewDeferredRenderer(lighting, fbWidth, fbHeight)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer renderer.Dispose()
+	}
 
-	vertAttrib := uint32(gl.GetAttribLocation(program, gl.Str("vert\x00")))
-	gl.EnableVertexAttribArray(vertAttrib)
-	gl.VertexAttribPointerWithOffset(vertAttrib, 3, gl.FLOAT, false, 5*4, 0)
+	// Clicked node is highlighted
+	var picked gfx.PickResult
+	picker, err := gfx.NewPicker(fbWidth, fbHeight, iuContext)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer picker.Dispose()
+	picker.OnPick = func(result gfx.PickResult) {
+		picked = result
+	}
 
-	texCoordAttrib := uint32(gl.GetAttribLocation(program, gl.Str("vertTexCoord\x00")))
-	gl.EnableVertexAttribArray(texCoordAttrib)
-	gl.VertexAttribPointerWithOffset(texCoordAttrib, 2, gl.FLOAT, false, 5*4, 3*4)
+	// HDR image of scene is post-processed into window
+	post, err := gfx.NewPostProcess(fbWidth, fbHeight, gl.RGBA16F)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer post.Dispose()
+	ssao, err := gfx.NewSSAO()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	post.Passes = append(post.Passes, ssao.Pass)
+	lut, err := gfx.NewIdentityLUT(16)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer gl.DeleteTextures(1, &lut)
+	for _, newPass := range []func() (*gfx.PostPass, error){
+		gfx.NewBlurPass,
+		gfx.NewBloomPass,
+		gfx.NewTonemapPass,
+		gfx.NewGammaPass,
+		func() (*gfx.PostPass, error) { return gfx.NewColorGradingPass(lut) },
+		gfx.NewFXAAPass,
+		gfx.NewVignettePass,
+	} {
+		pass, err := newPass()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		post.Passes = append(post.Passes, pass)
+	}
+	post.Find("Gaussian Blur").Enabled = false
+	post.Find("Color Grading").Enabled = false
 
 	// Configure global settings
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 	gl.ClearColor(1.0, 1.0, 1.0, 1.0)
+	gl.Enable(gl.FRAMEBUFFER_SRGB) // Lit shaders output linear colors
 
 	var (
 		previousTime      = sdl.GetTicks()
@@ -109,12 +280,32 @@ func main() {
 		f                 = float32(0)
 		counter           = 0
 		showAnotherWindow = false
+		showShadowMaps    = false
+		showPostProcess   = false
+		showDebugDraw     = false
+		showParticles     = true
 	)
 
 	for running {
 	EVENT_LOOP:
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 			iuContext.ProcessEvent(event)
+			camera.ProcessEvent(event)
+			controller.ProcessEvent(event)
+			if err := sceneTarget.ProcessEvent(event); err != nil {
+				log.Fatalln(err)
+			}
+			if err := post.ProcessEvent(event); err != nil {
+				log.Fatalln(err)
+			}
+			if err := picker.ProcessEvent(event); err != nil {
+				log.Fatalln(err)
+			}
+			if renderer != nil {
+				if err := renderer.ProcessEvent(event); err != nil {
+					log.Fatalln(err)
+				}
+			}
 
 			switch event.(type) {
 			case *sdl.QuitEvent:
@@ -124,7 +315,11 @@ func main() {
 			}
 		}
 
+		sceneTarget.Bind()
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		var zero [4]float32
+		gl.ClearBufferfv(gl.COLOR, 1, &zero[0])
+		gl.ClearBufferfv(gl.COLOR, 2, &zero[0])
 
 		// 3d scene
 		{
@@ -132,17 +327,72 @@ func main() {
 			elapsed := time - previousTime
 			previousTime = time
 			angle += float64(elapsed) / 1000
-			model = mgl32.HomogRotate3D(float32(angle), mgl32.Vec3{0, 1, 0})
+			controller.Update(float32(elapsed) / 1000)
+			particles.Update(float32(elapsed) / 1000)
+			cubeNode.SetRotation(mgl32.QuatRotate(float32(angle), mgl32.Vec3{0, 1, 0}))
 
 			// Render
-			gl.UseProgram(program)
-			gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
-			gl.BindVertexArray(vao)
+			if err := picker.Update(scene, camera); err != nil {
+				log.Fatalln(err)
+			}
+			bvh.Update(scene)
+			shadows.Render(scene, camera, lighting)
+			if renderer != nil {
+				if err := renderer.Draw(bvh, camera, sceneTarget); err != nil {
+					log.Fatalln(err)
+				}
+			} else {
+				bvh.Draw(camera)
+			}
+			if environment != nil {
+				environment.DrawSkybox(camera)
+			}
+			if showParticles {
+				// Soft particles read depth of opaque meshes
+				var depth uint32
+				if renderer != nil {
+					depth = renderer.GBuffer().DepthTexture()
+				} else {
+					sceneTarget.Resolve()
+					depth = sceneTarget.DepthTexture()
+				}
+				particles.Draw(camera, depth)
+			}
+			if showDebugDraw {
+				cubeBox := gfx.AABB{Min: mgl32.Vec3{-1, -1, -1}, Max: mgl32.Vec3{1, 1, 1}}
+				debugDraw.Grid(mgl32.Vec3{0, -0.99, 0}, 10, 10, mgl32.Vec4{0.5, 0.5, 0.5, 0.5})
+				debugDraw.AABB(cubeBox.Transform(cubeNode.WorldMatrix()), mgl32.Vec4{1, 1, 0, 1})
+				debugDraw.Arrow(mgl32.Vec3{0, 3, 0}, mgl32.Vec3{-0.4, 2.2, -0.4}, mgl32.Vec4{1, 1, 1, 1})
+				bvh.Walk(func(box gfx.AABB, depth int, leaf bool) {
+					debugDraw.AABB(box, mgl32.Vec4{0, 1, 1, 0.3})
+				})
+				debugDraw.OnTop = true
+				debugDraw.Axis(cubeNode.WorldMatrix(), 1.5)
+				debugDraw.Text3D(mgl32.Vec3{0, 1.6, 0}, cubeNode.Name, 0.2, mgl32.Vec4{1, 1, 1, 1})
+				debugDraw.OnTop = false
+			}
+			if picked.Node != nil {
+				debugDraw.OnTop = true
+				debugDraw.Sphere(gfx.Sphere{Center: picked.Position, Radius: 0.05}, mgl32.Vec4{1, 0, 1, 1})
+				debugDraw.Text3D(picked.Position.Add(mgl32.Vec3{0, 0.2, 0}), picked.Node.Name, 0.15, mgl32.Vec4{1, 0, 1, 1})
+				debugDraw.OnTop = false
+			}
+			debugDraw.Draw(camera)
+		}
 
-			gl.ActiveTexture(gl.TEXTURE0)
-			gl.BindTexture(gl.TEXTURE_2D, texture)
-			gl.DrawArrays(gl.TRIANGLES, 0, 6*2*3)
+		// Present the scene
+		sceneTarget.Resolve()
+		normals := sceneTarget.ColorTexture(1)
+		if renderer != nil {
+			normals = renderer.GBuffer().ColorTexture(gfx.GBufferNormal)
+		}
+		ssao.SetInputs(sceneTarget.DepthTexture(), normals, sceneTarget.ColorTexture(2), camera)
+		if err := post.Apply(sceneTarget.ColorTexture(0), nil); err != nil {
+			log.Fatalln(err)
 		}
+		fbWidth, fbHeight = window.GLGetDrawableSize()
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		gl.Viewport(0, 0, fbWidth, fbHeight)
 
 		// ui rendering
 		{
@@ -159,6 +409,10 @@ func main() {
 				imgui.Checkbox("Demo Window", &showDemoWindow) // Edit bools storing our window open/close state
 				imgui.Checkbox("Go Demo Window", &showGoDemoWindow)
 				imgui.Checkbox("Another Window", &showAnotherWindow)
+				imgui.Checkbox("Shadow Maps", &showShadowMaps)
+				imgui.Checkbox("Post Processing", &showPostProcess)
+				imgui.Checkbox("Debug Draw", &showDebugDraw)
+				imgui.Checkbox("Particles", &showParticles)
 
 				if imgui.Button("Button") { // Buttons return true when clicked (most widgets return true when edited/activated)
 					counter++
@@ -166,6 +420,17 @@ func main() {
 				imgui.SameLine()
 				imgui.Text(fmt.Sprintf("counter = %d", counter))
 
+				stats := bvh.Stats()
+				imgui.Text(fmt.Sprintf("Objects %d, considered %d, drawn %d", stats.Objects, stats.Considered, stats.Drawn))
+				if renderer != nil {
+					stats := renderer.Stats()
+					imgui.Text(fmt.Sprintf("Deferred: opaque %d, transparent %d, light volumes %d", stats.Opaque, stats.Transparent, stats.LightVolumes))
+				}
+				mouseX, mouseY, _ := sdl.GetMouseState()
+				if node, _, ok := bvh.Raycast(camera.ScreenRay(float32(mouseX), float32(mouseY))); ok {
+					imgui.Text("Under cursor: " + node.Name)
+				}
+
 				imgui.Text(fmt.Sprintf("Application average %.3f ms/frame (%.1f FPS)",
 					1000/imgui.CurrentIO().Framerate(), imgui.CurrentIO().Framerate()))
 			}
@@ -195,6 +460,12 @@ func main() {
 			if showGoDemoWindow {
 				demo.Show(&showGoDemoWindow)
 			}
+			if showShadowMaps {
+				iu.ShowShadowMaps(shadows, &showShadowMaps)
+			}
+			if showPostProcess {
+				iu.ShowPostProcess(post, &showPostProcess)
+			}
 
 			iuContext.Render()
 		}
@@ -203,78 +474,3 @@ func main() {
 		window.GLSwap()
 	}
 }
-
-var vertexShader = `
-#version 460 core
-uniform mat4 projection;
-uniform mat4 camera;
-uniform mat4 model;
-in vec3 vert;
-in vec2 vertTexCoord;
-out vec2 fragTexCoord;
-void main() {
-    fragTexCoord = vertTexCoord;
-    gl_Position = projection * camera * model * vec4(vert, 1);
-}
-` + "\x00"
-
-var fragmentShader = `
-#version 460 core
-uniform sampler2D tex;
-in vec2 fragTexCoord;
-out vec4 outputColor;
-void main() {
-    outputColor = texture(tex, fragTexCoord);
-}
-` + "\x00"
-
-var cubeVertices = []float32{
-	//  X, Y, Z, U, V
-	// Bottom
-	-1.0, -1.0, -1.0, 0.0, 0.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	1.0, -1.0, 1.0, 1.0, 1.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-
-	// Top
-	-1.0, 1.0, -1.0, 0.0, 0.0,
-	-1.0, 1.0, 1.0, 0.0, 1.0,
-	1.0, 1.0, -1.0, 1.0, 0.0,
-	1.0, 1.0, -1.0, 1.0, 0.0,
-	-1.0, 1.0, 1.0, 0.0, 1.0,
-	1.0, 1.0, 1.0, 1.0, 1.0,
-
-	// Front
-	-1.0, -1.0, 1.0, 1.0, 0.0,
-	1.0, -1.0, 1.0, 0.0, 0.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0,
-	1.0, -1.0, 1.0, 0.0, 0.0,
-	1.0, 1.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0,
-
-	// Back
-	-1.0, -1.0, -1.0, 0.0, 0.0,
-	-1.0, 1.0, -1.0, 0.0, 1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	-1.0, 1.0, -1.0, 0.0, 1.0,
-	1.0, 1.0, -1.0, 1.0, 1.0,
-
-	// Left
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, -1.0, 1.0, 0.0,
-	-1.0, -1.0, -1.0, 0.0, 0.0,
-	-1.0, -1.0, 1.0, 0.0, 1.0,
-	-1.0, 1.0, 1.0, 1.0, 1.0,
-	-1.0, 1.0, -1.0, 1.0, 0.0,
-
-	// Right
-	1.0, -1.0, 1.0, 1.0, 1.0,
-	1.0, -1.0, -1.0, 1.0, 0.0,
-	1.0, 1.0, -1.0, 0.0, 0.0,
-	1.0, -1.0, 1.0, 1.0, 1.0,
-	1.0, 1.0, -1.0, 0.0, 0.0,
-	1.0, 1.0, 1.0, 0.0, 1.0,
-}